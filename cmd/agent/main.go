@@ -22,7 +22,10 @@ func main() {
 
 	zlog.Info("Logger init")
 
-	agent := app.New(zlog, cfg)
+	agent, err := app.New(zlog, cfg)
+	if err != nil {
+		log.Fatalf("failed to init an app: %v", err)
+	}
 	err = agent.RunApp()
 	if err != nil {
 		log.Fatalf("failed to run an app: %v", err)
@@ -6,10 +6,21 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/VanGoghDev/practicum-metrics/internal/server/auth"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cardinality"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/graphite"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/grpcserver"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/history"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/keyring"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/logger"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/naming"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/pubsub"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/ratelimit"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/routers/chirouter"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/statsd"
 	"github.com/VanGoghDev/practicum-metrics/internal/storage"
+	"github.com/VanGoghDev/practicum-metrics/internal/util/tlsutil"
 )
 
 func main() {
@@ -43,10 +54,108 @@ func run(ctx context.Context) error {
 		return fmt.Errorf("failed to init storage %w", err)
 	}
 
+	// hub
+	hub := pubsub.NewHub(pubsub.DefaultMaxPending)
+
+	// limiter
+	limiter := cardinality.New(cfg, s)
+
+	// naming policy
+	policy, err := naming.New(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to init naming policy: %w", err)
+	}
+
+	// rate limits
+	rates := ratelimit.New(cfg)
+
+	// signature keys
+	keys, err := keyring.New(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load signature keys: %w", err)
+	}
+	go keys.WatchSIGHUP(ctx, zlog.Sugar())
+
+	// api tokens
+	authn, err := auth.FromConfig(zlog.Sugar(), cfg, s)
+	if err != nil {
+		return fmt.Errorf("failed to init api tokens: %w", err)
+	}
+
+	// history
+	h := history.New(zlog.Sugar(), cfg, s)
+	go h.Run(ctx)
+
+	// statsd
+	if cfg.StatsdAddress != "" {
+		srv, err := statsd.New(zlog.Sugar(), cfg, pubsub.Publishing(s, hub), limiter, policy)
+		if err != nil {
+			return fmt.Errorf("failed to init statsd server: %w", err)
+		}
+		if err := srv.Listen(); err != nil {
+			return fmt.Errorf("failed to start statsd server: %w", err)
+		}
+		go func() {
+			if err := srv.Serve(ctx); err != nil {
+				zlog.Sugar().Errorf("statsd server stopped: %v", err)
+			}
+		}()
+	}
+
+	// graphite
+	if cfg.GraphiteAddress != "" {
+		srv, err := graphite.New(zlog.Sugar(), cfg, pubsub.Publishing(s, hub), limiter, policy)
+		if err != nil {
+			return fmt.Errorf("failed to init graphite server: %w", err)
+		}
+		if err := srv.Listen(); err != nil {
+			return fmt.Errorf("failed to start graphite server: %w", err)
+		}
+		go func() {
+			if err := srv.Serve(ctx); err != nil {
+				zlog.Sugar().Errorf("graphite server stopped: %v", err)
+			}
+		}()
+	}
+
+	// grpc
+	if cfg.GRPCAddress != "" {
+		srv, err := grpcserver.New(zlog.Sugar(), cfg, pubsub.Publishing(s, hub), limiter, rates, policy, authn)
+		if err != nil {
+			return fmt.Errorf("failed to init grpc server: %w", err)
+		}
+		if err := srv.Listen(); err != nil {
+			return fmt.Errorf("failed to start grpc server: %w", err)
+		}
+		go func() {
+			if err := srv.Serve(ctx); err != nil {
+				zlog.Sugar().Errorf("grpc server stopped: %v", err)
+			}
+		}()
+	}
+
 	// router
-	router := chirouter.BuildRouter(s, zlog, cfg)
+	router, err := chirouter.BuildRouter(s, zlog, cfg,
+		chirouter.WithHistory(h), chirouter.WithHub(hub), chirouter.WithLimiter(limiter),
+		chirouter.WithKeyRing(keys), chirouter.WithAuth(authn), chirouter.WithPolicy(policy),
+		chirouter.WithRateLimiter(rates))
+	if err != nil {
+		return fmt.Errorf("failed to build router: %w", err)
+	}
 
-	err = http.ListenAndServe(cfg.Address, router)
+	srv := &http.Server{
+		Addr:    cfg.Address,
+		Handler: router,
+	}
+	if cfg.TLSCert != "" || cfg.TLSKey != "" {
+		srv.TLSConfig, err = tlsutil.ServerConfig(cfg.TLSCert, cfg.TLSKey, cfg.TLSMinVersion, cfg.TLSClientCA)
+		if err != nil {
+			return fmt.Errorf("failed to init tls: %w", err)
+		}
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
 	if err != nil {
 		return fmt.Errorf("failed to start http server: %w", err)
 	}
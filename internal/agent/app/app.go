@@ -12,7 +12,13 @@ import (
 	"github.com/VanGoghDev/practicum-metrics/internal/agent/services/metrics"
 	"github.com/VanGoghDev/practicum-metrics/internal/agent/services/sender"
 	"github.com/VanGoghDev/practicum-metrics/internal/agent/transport"
+	"github.com/VanGoghDev/practicum-metrics/internal/proto/metricspb"
+	"github.com/VanGoghDev/practicum-metrics/internal/util/codec"
+	"github.com/VanGoghDev/practicum-metrics/internal/util/tlsutil"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 var (
@@ -45,29 +51,84 @@ type App struct {
 	Sender          Sender
 	MetricsProvider MetricsProvider
 
+	conn *grpc.ClientConn
+
 	rateLimit      int64
 	reportInterval time.Duration
 	pollInterval   time.Duration
 }
 
-func New(log *zap.Logger, cfg *config.Config) *App {
+func New(log *zap.Logger, cfg *config.Config) (*App, error) {
 	metricsService := metrics.New(log)
-	aTripper := transport.New(cfg, http.DefaultTransport)
-	sndr := sender.New(
-		log,
-		&http.Client{
-			Transport: aTripper,
-		},
-		cfg.Address)
-
-	return &App{
+	a := &App{
 		Log:             log,
-		Sender:          sndr,
 		MetricsProvider: metricsService,
 		rateLimit:       cfg.RateLimit,
 		reportInterval:  cfg.ReportInterval,
 		pollInterval:    cfg.PollInterval,
 	}
+
+	// если задан адрес gRPC, метрики отправляются по gRPC вместо HTTP.
+	if cfg.GRPCAddress != "" {
+		creds := insecure.NewCredentials()
+		if cfg.GRPCTLS || tlsConfigured(cfg) {
+			tlsCfg, err := tlsutil.ClientConfig(cfg.TLSCA, cfg.TLSCert, cfg.TLSKey, cfg.TLSInsecure)
+			if err != nil {
+				return nil, fmt.Errorf("failed to init tls: %w", err)
+			}
+			creds = credentials.NewTLS(tlsCfg)
+		}
+		opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+		if cfg.Token != "" {
+			opts = append(opts, grpc.WithPerRPCCredentials(sender.TokenCredentials(cfg.Token)))
+		}
+		conn, err := grpc.NewClient(cfg.GRPCAddress, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create grpc client: %w", err)
+		}
+		a.conn = conn
+		a.Sender = sender.NewGRPC(log, metricspb.NewMetricsClient(conn))
+		return a, nil
+	}
+
+	c, err := codec.ByName(cfg.Serializer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select serializer: %w", err)
+	}
+
+	base := http.DefaultTransport
+	if tlsConfigured(cfg) {
+		tlsCfg, err := tlsutil.ClientConfig(cfg.TLSCA, cfg.TLSCert, cfg.TLSKey, cfg.TLSInsecure)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init tls: %w", err)
+		}
+		t, ok := http.DefaultTransport.(*http.Transport)
+		if !ok {
+			t = &http.Transport{Proxy: http.ProxyFromEnvironment}
+		}
+		t = t.Clone()
+		t.TLSClientConfig = tlsCfg
+		base = t
+	}
+
+	aTripper, err := transport.New(log, cfg, base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init transport: %w", err)
+	}
+	a.Sender = sender.New(
+		log,
+		&http.Client{
+			Transport: aTripper,
+		},
+		cfg.Address,
+		sender.WithCodec(c))
+	return a, nil
+}
+
+// tlsConfigured сообщает, что заданы параметры TLS агента: свои удостоверяющие центры,
+// клиентский сертификат или отключенная проверка сертификата сервера.
+func tlsConfigured(cfg *config.Config) bool {
+	return cfg.TLSCA != "" || cfg.TLSCert != "" || cfg.TLSKey != "" || cfg.TLSInsecure
 }
 
 func (a *App) RunApp() error {
@@ -87,6 +148,14 @@ func (a *App) Run() error {
 		return fmt.Errorf("%s: %w", op, ErrMetricsProviderNil)
 	}
 
+	if a.conn != nil {
+		defer func() {
+			if err := a.conn.Close(); err != nil {
+				a.Log.Warn(fmt.Sprintf("failed to close grpc connection: %v", err))
+			}
+		}()
+	}
+
 	var wg sync.WaitGroup
 	ctx := context.Background()
 	ctx, cancel := context.WithCancel(ctx)
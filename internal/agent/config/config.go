@@ -11,8 +11,18 @@ import (
 
 type Config struct {
 	Address        string        `env:"ADDRESS"`
+	GRPCAddress    string        `env:"GRPC_ADDRESS"`
+	GRPCTLS        bool          `env:"GRPC_TLS"`
 	Loglevel       string        `env:"LOGLVL"`
 	Key            string        `env:"KEY"`
+	KeyID          string        `env:"KEY_ID"`
+	Token          string        `env:"API_TOKEN"`
+	CryptoKey      string        `env:"CRYPTO_KEY"`
+	TLSCA          string        `env:"TLS_CA"`
+	TLSCert        string        `env:"TLS_CERT"`
+	TLSKey         string        `env:"TLS_KEY"`
+	TLSInsecure    bool          `env:"TLS_INSECURE_SKIP_VERIFY"`
+	Serializer     string        `env:"SERIALIZER"`
 	RateLimit      int64         `env:"RATE_LIMIT"`
 	ReportInterval time.Duration `env:"REPORTINTERVAL"`
 	PollInterval   time.Duration `env:"POLLINTERVAL"`
@@ -31,15 +41,31 @@ func Load() (config *Config, err error) {
 	}
 
 	var reportInteval, pollInterval, rateLimit int64
-	var logLevel, flagAddress, flagKey string
-
-	flag.StringVar(&flagAddress, "a", "localhost:8080", "address and port to run server")
+	var flagTLSCA, flagTLSCert, flagTLSKey, flagToken string
+	var flagTLSInsecure, flagGRPCTLS bool
+	var logLevel, flagAddress, flagGRPCAddress, flagKey, flagKeyID, flagCryptoKey, flagSerializer string
+
+	flag.StringVar(&flagAddress, "a", "localhost:8080",
+		"address and port of server, use https://host:port to connect over TLS")
+	flag.StringVar(&flagTLSCA, "tls-ca", "", "path to CA bundle to verify server certificate instead of system roots")
+	flag.StringVar(&flagTLSCert, "tls-cert", "", "path to client TLS certificate for mTLS")
+	flag.StringVar(&flagTLSKey, "tls-key", "", "path to client TLS private key for mTLS")
+	flag.BoolVar(&flagTLSInsecure, "tls-insecure-skip-verify", false,
+		"do not verify server certificate, for tests only")
+	flag.StringVar(&flagGRPCAddress, "grpc", "", "address of server gRPC API, if set metrics are sent over gRPC")
+	flag.BoolVar(&flagGRPCTLS, "grpc-tls", false,
+		"connect to gRPC API over TLS, also enabled by any of the -tls-* flags")
 	flag.Int64Var(&reportInteval,
 		"r", defaultReportInterval,
 		"report interval (interval of requests to consumer, in seconds)")
 	flag.Int64Var(&pollInterval, "p", defaultPollInterval, "poll interval (interval of metrics fetch, in seconds)")
 	flag.StringVar(&logLevel, "lvl", "info", "log level")
 	flag.StringVar(&flagKey, "k", "", "signature key")
+	flag.StringVar(&flagToken, "token", "", "API token with writer role, sent as bearer token")
+	flag.StringVar(&flagKeyID, "key-id", "", "id of signature key, sent to server to pick the key during rotation")
+	flag.StringVar(&flagCryptoKey, "crypto-key", "",
+		"path to server public RSA or X25519 key, if set request bodies are encrypted")
+	flag.StringVar(&flagSerializer, "serializer", "json", "format of metrics sent over HTTP: json, protobuf or msgpack")
 	flag.Int64Var(&rateLimit, "l", 1, "number of goroutines for sending metrics to server")
 
 	flag.Parse()
@@ -48,6 +74,14 @@ func Load() (config *Config, err error) {
 		cfg.Address = flagAddress
 	}
 
+	if _, present := os.LookupEnv("GRPC_ADDRESS"); !present {
+		cfg.GRPCAddress = flagGRPCAddress
+	}
+
+	if _, present := os.LookupEnv("GRPC_TLS"); !present {
+		cfg.GRPCTLS = flagGRPCTLS
+	}
+
 	if _, present := os.LookupEnv("LOGLVL"); !present {
 		cfg.Loglevel = logLevel
 	}
@@ -64,6 +98,38 @@ func Load() (config *Config, err error) {
 		cfg.Key = flagKey
 	}
 
+	if _, present := os.LookupEnv("API_TOKEN"); !present {
+		cfg.Token = flagToken
+	}
+
+	if _, present := os.LookupEnv("KEY_ID"); !present {
+		cfg.KeyID = flagKeyID
+	}
+
+	if _, present := os.LookupEnv("CRYPTO_KEY"); !present {
+		cfg.CryptoKey = flagCryptoKey
+	}
+
+	if _, present := os.LookupEnv("TLS_CA"); !present {
+		cfg.TLSCA = flagTLSCA
+	}
+
+	if _, present := os.LookupEnv("TLS_CERT"); !present {
+		cfg.TLSCert = flagTLSCert
+	}
+
+	if _, present := os.LookupEnv("TLS_KEY"); !present {
+		cfg.TLSKey = flagTLSKey
+	}
+
+	if _, present := os.LookupEnv("TLS_INSECURE_SKIP_VERIFY"); !present {
+		cfg.TLSInsecure = flagTLSInsecure
+	}
+
+	if _, present := os.LookupEnv("SERIALIZER"); !present {
+		cfg.Serializer = flagSerializer
+	}
+
 	if _, present := os.LookupEnv("RATE_LIMIT"); !present {
 		cfg.RateLimit = rateLimit
 	}
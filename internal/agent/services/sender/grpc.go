@@ -0,0 +1,91 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/agent/services/metrics"
+	"github.com/VanGoghDev/practicum-metrics/internal/proto/metricspb"
+	"go.uber.org/zap"
+)
+
+// GRPCConsumer отправляет метрики на сервер по gRPC.
+// Каждый батч отправляется отдельным вызовом UpdateMetrics, чтобы ошибка
+// относилась к конкретному батчу; соединение при этом переиспользуется.
+type GRPCConsumer struct {
+	zlog   *zap.Logger
+	Client metricspb.MetricsClient
+}
+
+func NewGRPC(zlog *zap.Logger, client metricspb.MetricsClient) *GRPCConsumer {
+	return &GRPCConsumer{
+		zlog:   zlog,
+		Client: client,
+	}
+}
+
+func (s *GRPCConsumer) SendMetrics(
+	ctx context.Context,
+	metricsCh <-chan metrics.Result,
+	resultCh chan<- Result,
+	reportInteval time.Duration,
+	wg *sync.WaitGroup,
+) {
+	wg.Add(1)
+	defer wg.Done()
+	for {
+		select {
+		case m := <-metricsCh:
+			if m.Err != nil {
+				s.zlog.Warn(fmt.Sprintf("failed to read metrics %v", m.Err))
+				continue
+			}
+
+			req := &metricspb.UpdateMetricsRequest{
+				Metrics: make([]*metricspb.Metric, 0, len(m.Metrics)),
+			}
+			for _, v := range m.Metrics {
+				if v == nil {
+					continue
+				}
+				req.Metrics = append(req.Metrics, &metricspb.Metric{
+					Id:    v.ID,
+					Type:  v.MType,
+					Delta: v.Delta,
+					Value: v.Value,
+				})
+			}
+
+			if _, err := s.Client.UpdateMetrics(ctx, req); err != nil {
+				s.zlog.Warn(fmt.Sprintf("failed to send metrics: %v", err))
+				resultCh <- Result{
+					Error: fmt.Errorf("failed to send metrics to server %w", err),
+				}
+				continue
+			}
+			resultCh <- Result{
+				Error: nil,
+			}
+		case <-ctx.Done():
+			close(resultCh)
+			return
+		}
+
+		time.Sleep(reportInteval)
+	}
+}
+
+// TokenCredentials передает API токен в метаданных authorization каждого вызова.
+type TokenCredentials string
+
+func (t TokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+// RequireTransportSecurity разрешает передавать токен без TLS, как и в HTTP API.
+// Вне доверенной сети соединение нужно шифровать (GRPC_TLS или параметры TLS агента).
+func (t TokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
@@ -0,0 +1,92 @@
+package sender_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/agent/services/metrics"
+	"github.com/VanGoghDev/practicum-metrics/internal/agent/services/sender"
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/proto/metricspb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+type mockMetricsClient struct {
+	metricspb.MetricsClient
+	err error
+	got chan *metricspb.UpdateMetricsRequest
+}
+
+func (m *mockMetricsClient) UpdateMetrics(_ context.Context, in *metricspb.UpdateMetricsRequest,
+	_ ...grpc.CallOption) (*metricspb.UpdateMetricsResponse, error) {
+	m.got <- in
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &metricspb.UpdateMetricsResponse{Accepted: int64(len(in.GetMetrics()))}, nil
+}
+
+func TestGRPCSendMetrics(t *testing.T) {
+	value := 3.1415
+	delta := int64(5)
+	batch := []*models.Metrics{
+		{ID: "Alloc", MType: "gauge", Value: &value},
+		{ID: "PollCount", MType: "counter", Delta: &delta},
+	}
+
+	tests := []struct {
+		err     error
+		name    string
+		wantErr bool
+	}{
+		{
+			name: "valid batch",
+		},
+		{
+			name:    "server error",
+			err:     errors.New("unavailable"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockMetricsClient{err: tt.err, got: make(chan *metricspb.UpdateMetricsRequest, 1)}
+			s := sender.NewGRPC(zap.NewNop(), client)
+
+			var wg sync.WaitGroup
+			metricsCh := make(chan metrics.Result, 1)
+			resultCh := make(chan sender.Result)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			metricsCh <- metrics.Result{Metrics: batch}
+			go s.SendMetrics(ctx, metricsCh, resultCh, time.Millisecond, &wg)
+
+			r := <-resultCh
+			if tt.wantErr {
+				assert.ErrorIs(t, r.Error, tt.err)
+			} else {
+				require.NoError(t, r.Error)
+			}
+
+			req := <-client.got
+			require.Len(t, req.GetMetrics(), 2)
+			assert.Equal(t, "Alloc", req.GetMetrics()[0].GetId())
+			assert.InDelta(t, value, req.GetMetrics()[0].GetValue(), 0)
+			assert.Equal(t, "counter", req.GetMetrics()[1].GetType())
+			assert.Equal(t, delta, req.GetMetrics()[1].GetDelta())
+
+			// после отмены контекста отправитель закрывает канал результатов.
+			cancel()
+			_, ok := <-resultCh
+			assert.False(t, ok)
+		})
+	}
+}
@@ -3,14 +3,15 @@ package sender
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/VanGoghDev/practicum-metrics/internal/agent/services/metrics"
+	"github.com/VanGoghDev/practicum-metrics/internal/util/codec"
 	"go.uber.org/zap"
 )
 
@@ -25,15 +26,36 @@ type Result struct {
 type ServerConsumer struct {
 	zlog   *zap.Logger
 	Client HTTPClient
+	codec  codec.Codec
 	url    string
 }
 
-func New(zlog *zap.Logger, client HTTPClient, url string) *ServerConsumer {
-	return &ServerConsumer{
+// Option настраивает ServerConsumer.
+type Option func(s *ServerConsumer)
+
+// WithCodec задает формат, в котором батч метрик отправляется на сервер. По умолчанию JSON.
+func WithCodec(c codec.Codec) Option {
+	return func(s *ServerConsumer) {
+		s.codec = c
+	}
+}
+
+// New создает отправителя метрик на сервер по адресу url: host:port, http://host:port или https://host:port.
+// Адрес без схемы означает http.
+func New(zlog *zap.Logger, client HTTPClient, url string, opts ...Option) *ServerConsumer {
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
+	s := &ServerConsumer{
 		zlog:   zlog,
 		Client: client,
-		url:    url,
+		codec:  codec.JSON,
+		url:    strings.TrimSuffix(url, "/"),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *ServerConsumer) SendMetrics(
@@ -53,7 +75,11 @@ func (s *ServerConsumer) SendMetrics(
 				continue
 			}
 
-			mJ, err := json.Marshal(m.Metrics)
+			c := s.codec
+			if c == nil {
+				c = codec.JSON
+			}
+			mJ, err := c.MarshalMetrics(m.Metrics)
 			if err != nil {
 				s.zlog.Warn(fmt.Sprintf("failed to serialize gauge: %v", err))
 				resultCh <- Result{
@@ -66,9 +92,8 @@ func (s *ServerConsumer) SendMetrics(
 
 			request, err := http.NewRequest(
 				http.MethodPost,
-				fmt.Sprintf("http://%s/updates/", s.url),
+				s.url+"/updates/",
 				buf)
-			request.Close = true
 			if err != nil {
 				s.zlog.Warn(fmt.Sprintf("failed to create request for metrics update: %v", err))
 				resultCh <- Result{
@@ -76,6 +101,8 @@ func (s *ServerConsumer) SendMetrics(
 				}
 				continue
 			}
+			request.Close = true
+			request.Header.Set("Content-Type", c.ContentType())
 
 			err = s.sendRequest(request)
 			if err != nil {
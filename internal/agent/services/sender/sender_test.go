@@ -14,6 +14,7 @@ import (
 	"github.com/VanGoghDev/practicum-metrics/internal/agent/services/sender"
 	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
 )
 
 type args struct {
@@ -134,3 +135,43 @@ func TestSendGauge(t *testing.T) {
 		})
 	}
 }
+
+func TestSendMetricsURL(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{address: "localhost:8080", want: "http://localhost:8080/updates/"},
+		{address: "http://localhost:8080/", want: "http://localhost:8080/updates/"},
+		{address: "https://metrics.example:8443", want: "https://metrics.example:8443/updates/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			got := make(chan string, 1)
+			client := &mocks.MockClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					got <- req.URL.String()
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(bytes.NewReader(nil)),
+					}, nil
+				},
+			}
+			s := sender.New(zap.NewNop(), client, tt.address)
+
+			var wg sync.WaitGroup
+			value := 1.0
+			metricsCh := make(chan metrics.Result, 1)
+			resultCh := make(chan sender.Result)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			metricsCh <- metrics.Result{Metrics: []*models.Metrics{{ID: "Alloc", MType: "gauge", Value: &value}}}
+			go s.SendMetrics(ctx, metricsCh, resultCh, time.Millisecond, &wg)
+
+			assert.NoError(t, (<-resultCh).Error)
+			assert.Equal(t, tt.want, <-got)
+		})
+	}
+}
@@ -6,38 +6,79 @@ import (
 
 	"github.com/VanGoghDev/practicum-metrics/internal/agent/config"
 	"github.com/VanGoghDev/practicum-metrics/internal/agent/transport/compressor"
+	"github.com/VanGoghDev/practicum-metrics/internal/agent/transport/encryptor"
 	"github.com/VanGoghDev/practicum-metrics/internal/agent/transport/signer"
+	"github.com/VanGoghDev/practicum-metrics/internal/util/encryption"
+	"go.uber.org/zap"
 )
 
 // AgentTripper инкапсулирует в себе логику
-// транспорта сжатия (CompressionTripper)
+// транспорта сжатия (CompressionTripper),
+// транспорта шифрования (EncryptionTripper)
 // и транспорта подписи (SignTripper).
+// Тело сначала сжимается, затем шифруется, и подписываются уже зашифрованные данные.
+// В заголовке X-Real-IP передается адрес исходящего интерфейса агента (если его удалось определить),
+// а если задан API токен - он передается в заголовке Authorization.
+// Если задан ключ, подпись ответа сервера проверяется, и неверная подпись возвращается как ошибка.
 type AgentTripper struct {
 	Proxied http.RoundTripper
 
 	compressor.CompressionTripper
+	encryptor.EncryptionTripper
 	signer.SignerTripper
 
-	useCompression, useSigning bool
+	zlog        *zap.Logger
+	outboundIPs outboundIPs
+
+	token string
+
+	useCompression, useEncryption, useSigning bool
 }
 
-func New(cfg *config.Config, proxy http.RoundTripper) *AgentTripper {
+func New(log *zap.Logger, cfg *config.Config, proxy http.RoundTripper) (*AgentTripper, error) {
 	var useSigning bool
 	if cfg.Key != "" {
 		useSigning = true
 	}
 	sgnr := signer.New(cfg)
-	return &AgentTripper{
+	a := &AgentTripper{
 		SignerTripper:  *sgnr,
 		useCompression: true,
 		useSigning:     useSigning,
 		Proxied:        proxy,
+		zlog:           log,
+		token:          cfg.Token,
+	}
+
+	if cfg.CryptoKey != "" {
+		key, err := encryption.LoadPublicKey(cfg.CryptoKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load crypto key: %w", err)
+		}
+		a.EncryptionTripper = *encryptor.New(key)
+		a.useEncryption = true
 	}
+	return a, nil
 }
 
 func (a *AgentTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	var err error
 
+	if a.token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	}
+
+	// адрес агента нужен серверу для проверки доверенной подсети. Если его не удалось определить,
+	// запрос отправляется без заголовка, и сервер проверит адрес соединения.
+	if req.Header.Get(realIPHeader) == "" {
+		ip, err := a.outboundIPs.get(req)
+		if err != nil {
+			a.zlog.Warn(fmt.Sprintf("failed to detect agent address: %v", err))
+		} else {
+			req.Header.Set(realIPHeader, ip)
+		}
+	}
+
 	if a.useCompression {
 		req, err = a.CompressionTripper.CompressBody(req)
 		if err != nil {
@@ -45,6 +86,13 @@ func (a *AgentTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 		}
 	}
 
+	if a.useEncryption {
+		req, err = a.EncryptionTripper.EncryptBody(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt request body: %w", err)
+		}
+	}
+
 	if a.useSigning {
 		req, err = a.SignerTripper.SignBody(req)
 		if err != nil {
@@ -57,5 +105,12 @@ func (a *AgentTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 		return nil, fmt.Errorf("failed to round trip from agent tripper: %w", err)
 	}
 
+	if a.useSigning {
+		if err := a.SignerTripper.VerifyResponse(req, res); err != nil {
+			_ = res.Body.Close()
+			return nil, fmt.Errorf("failed to verify response: %w", err)
+		}
+	}
+
 	return res, nil
 }
@@ -0,0 +1,56 @@
+package encryptor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/util/encryption"
+)
+
+// EncryptionTripper шифрует тела запросов открытым ключом сервера.
+type EncryptionTripper struct {
+	Proxied http.RoundTripper
+	key     *encryption.PublicKey
+}
+
+func New(key *encryption.PublicKey) *EncryptionTripper {
+	return &EncryptionTripper{
+		key: key,
+	}
+}
+
+// EncryptBody шифрует body запроса.
+// Возвращает *http.Request с зашифрованным body и HTTP заголовком X-Encryption,
+// в котором передается схема шифрования. Заголовки сжатия не меняются:
+// сервер сначала расшифровывает тело, затем распаковывает его.
+func (et *EncryptionTripper) EncryptBody(req *http.Request) (*http.Request, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	sealed, err := et.key.Encrypt(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt request body: %w", err)
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(sealed))
+	req.ContentLength = int64(len(sealed))
+	req.Header.Set(encryption.HeaderScheme, et.key.Scheme())
+	return req, nil
+}
+
+func (et *EncryptionTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req, err := et.EncryptBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := et.Proxied.RoundTrip(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to round trip from encryption tripper: %w", err)
+	}
+	return res, nil
+}
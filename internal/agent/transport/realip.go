@@ -0,0 +1,67 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// realIPHeader заголовок с адресом агента, по которому сервер проверяет доверенную подсеть.
+const realIPHeader = "X-Real-IP"
+
+var errUnexpectedAddr = errors.New("unexpected local address")
+
+// outboundIPs запоминает адреса исходящих интерфейсов агента по адресам серверов,
+// чтобы не выбирать маршрут заново для каждого запроса.
+type outboundIPs struct {
+	ips map[string]string
+	mu  sync.Mutex
+}
+
+// get возвращает адрес интерфейса, через который агент отправляет запрос req.
+// Адрес запоминается только после успешного определения, после ошибки он определяется заново.
+func (o *outboundIPs) get(req *http.Request) (string, error) {
+	host := req.URL.Host
+	if req.URL.Port() == "" {
+		port := "80"
+		if req.URL.Scheme == "https" {
+			port = "443"
+		}
+		host = net.JoinHostPort(req.URL.Hostname(), port)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if ip, ok := o.ips[host]; ok {
+		return ip, nil
+	}
+	ip, err := outboundIP(host)
+	if err != nil {
+		return "", err
+	}
+	if o.ips == nil {
+		o.ips = make(map[string]string)
+	}
+	o.ips[host] = ip
+	return ip, nil
+}
+
+// outboundIP возвращает адрес интерфейса, через который идет маршрут до host.
+// UDP соединение ничего не отправляет, а только выбирает маршрут до сервера.
+func outboundIP(host string) (string, error) {
+	conn, err := net.Dial("udp", host)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve outbound address: %w", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", errUnexpectedAddr, conn.LocalAddr())
+	}
+	return addr.IP.String(), nil
+}
@@ -0,0 +1,55 @@
+package transport
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/agent/config"
+)
+
+// recorder запоминает заголовок X-Real-IP отправленного запроса.
+type recorder struct {
+	realIP string
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.realIP = req.Header.Get(realIPHeader)
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+}
+
+func TestAgentTripper_RealIP(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		realIP string
+		cached int
+	}{
+		{name: "address is detected and cached", url: "http://127.0.0.1:8080/updates/", realIP: "127.0.0.1", cached: 1},
+		// порт вне допустимого диапазона: маршрут не выбрать, но запрос все равно отправляется.
+		{name: "detection error does not fail request", url: "http://127.0.0.1:99999/updates/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := &recorder{}
+			a, err := New(zap.NewNop(), &config.Config{}, proxy)
+			require.NoError(t, err)
+
+			for range 2 {
+				req, err := http.NewRequest(http.MethodPost, tt.url, bytes.NewBufferString("[]"))
+				require.NoError(t, err)
+				resp, err := a.RoundTrip(req)
+				require.NoError(t, err)
+				_ = resp.Body.Close()
+				assert.Equal(t, tt.realIP, proxy.realIP)
+			}
+			assert.Len(t, a.outboundIPs.ips, tt.cached)
+		})
+	}
+}
@@ -3,25 +3,36 @@ package signer
 import (
 	"bytes"
 	"crypto/hmac"
-	"crypto/sha256"
+	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/VanGoghDev/practicum-metrics/internal/agent/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/util/signing"
 )
 
+// nonceLen длина случайной части nonce в байтах.
+const nonceLen = 16
+
+var ErrResponseSignature = errors.New("invalid response signature")
+
 // SignerTripper Подписывает запросы алгоритмом sha256.
 type SignerTripper struct {
 	Proxied http.RoundTripper
 	key     string
+	keyID   string
 }
 
 func New(cfg *config.Config) *SignerTripper {
 	return &SignerTripper{
-		key: cfg.Key,
+		key:   cfg.Key,
+		keyID: cfg.KeyID,
 	}
 }
 
@@ -29,7 +40,15 @@ func New(cfg *config.Config) *SignerTripper {
 // Возвращает *http.Request, в котором проставлен
 // HTTP заголовок (header) HashSHA256, значение которого - hash от тела запроса.
 // Хэш считается с учетом ключа, передаваемом через config.
+// В подпись входят время подписи и случайный nonce из заголовков
+// X-Signature-Timestamp и X-Signature-Nonce, по ним сервер отклоняет повторы запроса.
+// Если задан идентификатор ключа, он передается в заголовке X-Signature-Key-Id.
 func (st *SignerTripper) SignBody(req *http.Request) (*http.Request, error) {
+	nonce := make([]byte, nonceLen)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate signature nonce: %w", err)
+	}
+
 	body, err := io.ReadAll(req.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read request body: %w", err)
@@ -39,15 +58,54 @@ func (st *SignerTripper) SignBody(req *http.Request) (*http.Request, error) {
 		req.Body = io.NopCloser(bytes.NewBuffer(body))
 	}()
 
-	h := hmac.New(sha256.New, []byte(st.key))
-	h.Write(body)
-	dst := h.Sum(nil)
-	v := base64.StdEncoding.EncodeToString(dst)
-	req.Header.Set("HashSHA256", v)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	n := hex.EncodeToString(nonce)
+	dst := signing.Sign([]byte(st.key), ts, n, body)
+	req.Header.Set(signing.HeaderTimestamp, ts)
+	req.Header.Set(signing.HeaderNonce, n)
+	if st.keyID != "" {
+		req.Header.Set(signing.HeaderKeyID, st.keyID)
+	}
+	req.Header.Set(signing.HeaderSignature, base64.StdEncoding.EncodeToString(dst))
 
 	return req, nil
 }
 
+// VerifyResponse проверяет подпись HashSHA256 ответа на подписанный запрос req:
+// HMAC-SHA256 от тела ответа в том виде, в котором оно пришло по сети,
+// с тем же ключом, временем и nonce, что и запрос.
+// Успешный ответ без подписи тоже считается ошибкой. Ответ с ошибкой сервер
+// может не подписать, если отклонил запрос до проверки подписи.
+func (st *SignerTripper) VerifyResponse(req *http.Request, res *http.Response) error {
+	sign := res.Header.Get(signing.HeaderSignature)
+	if sign == "" {
+		if res.StatusCode >= http.StatusBadRequest {
+			return nil
+		}
+		return fmt.Errorf("%w: signature is missing", ErrResponseSignature)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if err := res.Body.Close(); err != nil {
+		return fmt.Errorf("failed to close response body: %w", err)
+	}
+	res.Body = io.NopCloser(bytes.NewBuffer(body))
+
+	hV, err := base64.StdEncoding.DecodeString(sign)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrResponseSignature, err)
+	}
+	ts, nonce := req.Header.Get(signing.HeaderTimestamp), req.Header.Get(signing.HeaderNonce)
+	dst := signing.Sign([]byte(st.key), ts, nonce, body)
+	if !hmac.Equal(dst, hV) {
+		return fmt.Errorf("%w: signature does not match", ErrResponseSignature)
+	}
+	return nil
+}
+
 func (st *SignerTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	req, err := st.SignBody(req)
 	if err != nil {
@@ -58,5 +116,9 @@ func (st *SignerTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to round trip from signer tripper: %w", err)
 	}
+	if err := st.VerifyResponse(req, res); err != nil {
+		_ = res.Body.Close()
+		return nil, err
+	}
 	return res, nil
 }
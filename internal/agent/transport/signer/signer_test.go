@@ -10,12 +10,15 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/util/signing"
 )
 
 func TestSignerTripper_SignBody(t *testing.T) {
 	type fields struct {
 		Proxied http.RoundTripper
 		key     string
+		keyID   string
 	}
 	type args struct {
 		reqBody string
@@ -38,12 +41,24 @@ func TestSignerTripper_SignBody(t *testing.T) {
 			},
 			want: "ddd",
 		},
+		{
+			name: "valid sign with key id",
+			fields: fields{
+				key:   "secret",
+				keyID: "v2",
+			},
+			args: args{
+				reqBody: "ddd",
+			},
+			want: "ddd",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			st := &SignerTripper{
 				Proxied: tt.fields.Proxied,
 				key:     tt.fields.key,
+				keyID:   tt.fields.keyID,
 			}
 			buf := bytes.NewBufferString(tt.args.reqBody)
 			req, err := http.NewRequest(http.MethodPost, "/test", buf)
@@ -61,6 +76,9 @@ func TestSignerTripper_SignBody(t *testing.T) {
 			hV := got.Header.Get("HashSHA256")
 			should, _ := base64.StdEncoding.DecodeString(hV)
 			assert.NotEmpty(t, hV)
+			assert.NotEmpty(t, got.Header.Get("X-Signature-Timestamp"))
+			assert.Equal(t, tt.fields.keyID, got.Header.Get("X-Signature-Key-Id"))
+			assert.Len(t, got.Header.Get("X-Signature-Nonce"), 2*nonceLen)
 			assert.True(t, hmac.Equal(sign, should))
 		})
 	}
@@ -69,7 +87,56 @@ func TestSignerTripper_SignBody(t *testing.T) {
 func getSignature(req *http.Request, secretkey string) []byte {
 	body, _ := io.ReadAll(req.Body)
 	h := hmac.New(sha256.New, []byte(secretkey))
+	h.Write([]byte(req.Header.Get("X-Signature-Timestamp") + "\n" + req.Header.Get("X-Signature-Nonce") + "\n"))
 	h.Write(body)
 	sign := h.Sum(nil)
 	return sign
 }
+
+func TestSignerTripper_VerifyResponse(t *testing.T) {
+	st := &SignerTripper{key: "secret"}
+	req, err := http.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString("ddd"))
+	if err != nil {
+		t.Fatal("failed to create request")
+	}
+	req, err = st.SignBody(req)
+	assert.NoError(t, err)
+
+	ts, nonce := req.Header.Get("X-Signature-Timestamp"), req.Header.Get("X-Signature-Nonce")
+	valid := base64.StdEncoding.EncodeToString(signing.Sign([]byte("secret"), ts, nonce, []byte("ok")))
+
+	tests := []struct {
+		name    string
+		sign    string
+		body    string
+		status  int
+		wantErr bool
+	}{
+		{name: "valid", sign: valid, body: "ok", status: http.StatusOK},
+		{name: "tampered body", sign: valid, body: "ko", status: http.StatusOK, wantErr: true},
+		{name: "missing on success", body: "ok", status: http.StatusOK, wantErr: true},
+		{name: "missing on rejected request", body: "unauthorized", status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &http.Response{
+				StatusCode: tt.status,
+				Header:     http.Header{},
+				Body:       io.NopCloser(bytes.NewBufferString(tt.body)),
+			}
+			if tt.sign != "" {
+				res.Header.Set("HashSHA256", tt.sign)
+			}
+
+			err := st.VerifyResponse(req, res)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrResponseSignature)
+				return
+			}
+			assert.NoError(t, err)
+			// тело ответа остается доступным после проверки.
+			body, _ := io.ReadAll(res.Body)
+			assert.Equal(t, tt.body, string(body))
+		})
+	}
+}
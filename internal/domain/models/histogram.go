@@ -0,0 +1,82 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+var ErrInvalidHistogram = errors.New("invalid histogram")
+
+// Histogram гистограмма с явными границами бакетов, как в OpenTelemetry.
+// Counts[i] - количество значений в интервале (Bounds[i-1], Bounds[i]],
+// последний элемент Counts - количество значений больше последней границы.
+// Хранится как счетчик: записанные гистограммы складываются (см. Merge).
+type Histogram struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []uint64  `json:"counts"`
+	Count  uint64    `json:"count"` // общее количество значений
+	Sum    float64   `json:"sum"`   // сумма значений
+}
+
+// Validate проверяет, что количество бакетов соответствует границам,
+// границы конечны и возрастают, а сумма конечна.
+func (h *Histogram) Validate() error {
+	if len(h.Counts) != len(h.Bounds)+1 {
+		return fmt.Errorf("%w: %d bucket counts for %d bounds", ErrInvalidHistogram, len(h.Counts), len(h.Bounds))
+	}
+	for i, b := range h.Bounds {
+		if math.IsNaN(b) || math.IsInf(b, 0) || (i > 0 && b <= h.Bounds[i-1]) {
+			return fmt.Errorf("%w: bounds must be finite and increasing", ErrInvalidHistogram)
+		}
+	}
+	if math.IsNaN(h.Sum) || math.IsInf(h.Sum, 0) {
+		return fmt.Errorf("%w: sum is not finite", ErrInvalidHistogram)
+	}
+	return nil
+}
+
+// Merge возвращает сумму гистограмм h и d. Если границы или количество бакетов разные
+// (в том числе h пустая), накопленные значения несопоставимы, и результатом становится d.
+func (h Histogram) Merge(d Histogram) Histogram {
+	if !slices.Equal(h.Bounds, d.Bounds) || len(h.Counts) != len(d.Counts) {
+		return d.Clone()
+	}
+	res := h.Clone()
+	for i, c := range d.Counts {
+		res.Counts[i] += c
+	}
+	res.Count += d.Count
+	res.Sum += d.Sum
+	return res
+}
+
+// Sub возвращает приращение накопительной гистограммы h с момента prev.
+// Если границы изменились или количество значений уменьшилось, считается, что источник
+// сбросил гистограмму, и приращением становится h целиком.
+func (h Histogram) Sub(prev Histogram) Histogram {
+	if !slices.Equal(h.Bounds, prev.Bounds) || h.Count < prev.Count {
+		return h.Clone()
+	}
+	res := h.Clone()
+	for i, c := range prev.Counts {
+		if res.Counts[i] < c {
+			return h.Clone()
+		}
+		res.Counts[i] -= c
+	}
+	res.Count -= prev.Count
+	res.Sum -= prev.Sum
+	return res
+}
+
+// Clone возвращает копию гистограммы, не разделяющую с ней срезы.
+func (h Histogram) Clone() Histogram {
+	return Histogram{
+		Bounds: slices.Clone(h.Bounds),
+		Counts: slices.Clone(h.Counts),
+		Count:  h.Count,
+		Sum:    h.Sum,
+	}
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogram_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		h       Histogram
+		wantErr bool
+	}{
+		{name: "valid", h: Histogram{Bounds: []float64{1, 5}, Counts: []uint64{1, 2, 3}, Count: 6, Sum: 10}},
+		{name: "single bucket", h: Histogram{Counts: []uint64{4}, Count: 4, Sum: 2}},
+		{
+			name:    "counts do not match bounds",
+			h:       Histogram{Bounds: []float64{1, 5}, Counts: []uint64{1, 2}},
+			wantErr: true,
+		},
+		{
+			name:    "bounds not increasing",
+			h:       Histogram{Bounds: []float64{5, 1}, Counts: []uint64{0, 0, 0}},
+			wantErr: true,
+		},
+		{
+			name:    "infinite bound",
+			h:       Histogram{Bounds: []float64{1, math.Inf(1)}, Counts: []uint64{0, 0, 0}},
+			wantErr: true,
+		},
+		{name: "sum is NaN", h: Histogram{Counts: []uint64{1}, Count: 1, Sum: math.NaN()}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.h.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidHistogram)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestHistogram_Merge(t *testing.T) {
+	tests := []struct {
+		name string
+		h    Histogram
+		d    Histogram
+		want Histogram
+	}{
+		{
+			name: "same bounds are added",
+			h:    Histogram{Bounds: []float64{1}, Counts: []uint64{1, 2}, Count: 3, Sum: 5},
+			d:    Histogram{Bounds: []float64{1}, Counts: []uint64{2, 0}, Count: 2, Sum: 1},
+			want: Histogram{Bounds: []float64{1}, Counts: []uint64{3, 2}, Count: 5, Sum: 6},
+		},
+		{
+			name: "different bounds are replaced",
+			h:    Histogram{Bounds: []float64{1}, Counts: []uint64{1, 2}, Count: 3, Sum: 5},
+			d:    Histogram{Bounds: []float64{2}, Counts: []uint64{1, 0}, Count: 1, Sum: 1},
+			want: Histogram{Bounds: []float64{2}, Counts: []uint64{1, 0}, Count: 1, Sum: 1},
+		},
+		{
+			name: "empty histogram takes single bucket",
+			d:    Histogram{Counts: []uint64{2}, Count: 2, Sum: 3},
+			want: Histogram{Counts: []uint64{2}, Count: 2, Sum: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := tt.h.Clone()
+			assert.Equal(t, tt.want, tt.h.Merge(tt.d))
+			assert.Equal(t, before, tt.h, "merge must not modify the receiver")
+		})
+	}
+}
+
+func TestHistogram_Sub(t *testing.T) {
+	tests := []struct {
+		name string
+		h    Histogram
+		prev Histogram
+		want Histogram
+	}{
+		{
+			name: "increment",
+			h:    Histogram{Bounds: []float64{1}, Counts: []uint64{3, 2}, Count: 5, Sum: 6},
+			prev: Histogram{Bounds: []float64{1}, Counts: []uint64{1, 2}, Count: 3, Sum: 5},
+			want: Histogram{Bounds: []float64{1}, Counts: []uint64{2, 0}, Count: 2, Sum: 1},
+		},
+		{
+			name: "count decreased",
+			h:    Histogram{Bounds: []float64{1}, Counts: []uint64{1, 0}, Count: 1, Sum: 1},
+			prev: Histogram{Bounds: []float64{1}, Counts: []uint64{1, 2}, Count: 3, Sum: 5},
+			want: Histogram{Bounds: []float64{1}, Counts: []uint64{1, 0}, Count: 1, Sum: 1},
+		},
+		{
+			name: "bucket decreased",
+			h:    Histogram{Bounds: []float64{1}, Counts: []uint64{0, 4}, Count: 4, Sum: 9},
+			prev: Histogram{Bounds: []float64{1}, Counts: []uint64{1, 2}, Count: 3, Sum: 5},
+			want: Histogram{Bounds: []float64{1}, Counts: []uint64{0, 4}, Count: 4, Sum: 9},
+		},
+		{
+			name: "bounds changed",
+			h:    Histogram{Bounds: []float64{2}, Counts: []uint64{3, 2}, Count: 5, Sum: 6},
+			prev: Histogram{Bounds: []float64{1}, Counts: []uint64{1, 2}, Count: 3, Sum: 5},
+			want: Histogram{Bounds: []float64{2}, Counts: []uint64{3, 2}, Count: 5, Sum: 6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.h.Sub(tt.prev))
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"slices"
+	"strings"
+)
+
+// SeriesID строит имя серии из имени метрики и меток в формате name{key="value",...}.
+// Метки сортируются по ключу, поэтому один и тот же набор меток всегда дает одно имя.
+// Ключи приводятся к виду [a-zA-Z_][a-zA-Z0-9_]* (см. LabelKey), значения экранируются.
+// Если после приведения ключи совпали, остается метка с меньшим исходным ключом.
+// Без меток возвращается имя метрики как есть.
+func SeriesID(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	sanitized := make(map[string]string, len(keys))
+	for _, k := range keys {
+		key := LabelKey(k)
+		if _, ok := sanitized[key]; !ok {
+			sanitized[key] = labels[k]
+		}
+	}
+	keys = keys[:0]
+	for k := range sanitized {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(sanitized[k]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// LabelKey приводит ключ метки к виду [a-zA-Z_][a-zA-Z0-9_]*: недопустимые символы заменяются на _,
+// перед ключом, начинающимся с цифры, добавляется _. Иначе ключ мог бы содержать =, кавычки
+// или запятую и менять разбор имени серии.
+func LabelKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+
+	var b strings.Builder
+	if key[0] >= '0' && key[0] <= '9' {
+		b.WriteByte('_')
+	}
+	for _, r := range key {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			continue
+		}
+		b.WriteByte('_')
+	}
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
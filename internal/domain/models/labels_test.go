@@ -0,0 +1,48 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeriesID(t *testing.T) {
+	tests := []struct {
+		name   string
+		metric string
+		labels map[string]string
+		want   string
+	}{
+		{name: "no labels", metric: "requests", want: "requests"},
+		{
+			name:   "labels are sorted",
+			metric: "requests",
+			labels: map[string]string{"path": "/api", "code": "200"},
+			want:   `requests{code="200",path="/api"}`,
+		},
+		{
+			name:   "values are escaped",
+			metric: "requests",
+			labels: map[string]string{"path": "a\"b\\c\nd"},
+			want:   `requests{path="a\"b\\c\nd"}`,
+		},
+		{
+			name:   "invalid keys are sanitized",
+			metric: "requests",
+			labels: map[string]string{`a",b="x`: "1", "1st": "2", "": "3", "host.name": "4"},
+			want:   `requests{_="3",_1st="2",a__b__x="1",host_name="4"}`,
+		},
+		{
+			name:   "colliding keys keep the smallest original key",
+			metric: "requests",
+			labels: map[string]string{"host-name": "a", "host.name": "b"},
+			want:   `requests{host_name="a"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SeriesID(tt.metric, tt.labels))
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package models
+
+// Варианты сортировки списка метрик.
+const (
+	SortByName     = "name"  // по имени, затем по типу
+	SortByNameDesc = "-name" // по имени в обратном порядке
+	SortByType     = "type"  // по типу, затем по имени
+	SortByTypeDesc = "-type" // по типу и имени в обратном порядке
+)
+
+// MetricKey однозначно определяет серию: имя и тип метрики.
+type MetricKey struct {
+	ID    string `json:"id"`
+	MType string `json:"type"`
+}
+
+// ListQuery параметры выборки метрик из хранилища.
+type ListQuery struct {
+	After  *MetricKey // ключ последней метрики предыдущей страницы, nil - с начала
+	MType  string     // тип метрики, пусто - все типы
+	Prefix string     // префикс имени
+	Match  string     // шаблон имени, поддерживаются * (любая подстрока) и ? (любой символ)
+	Sort   string     // порядок сортировки, по умолчанию SortByName
+	Limit  int        // максимальное количество метрик, 0 - без ограничения
+}
+
+// Compare сравнивает ключи метрик в порядке сортировки запроса.
+// Возвращает отрицательное число, если a идет раньше b, положительное - если позже, 0 - если ключи равны.
+func (q *ListQuery) Compare(a, b MetricKey) int {
+	var c int
+	switch q.Sort {
+	case SortByType, SortByTypeDesc:
+		c = compareStrings(a.MType, b.MType)
+		if c == 0 {
+			c = compareStrings(a.ID, b.ID)
+		}
+	default:
+		c = compareStrings(a.ID, b.ID)
+		if c == 0 {
+			c = compareStrings(a.MType, b.MType)
+		}
+	}
+
+	if q.Sort == SortByNameDesc || q.Sort == SortByTypeDesc {
+		return -c
+	}
+	return c
+}
+
+func compareStrings(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,255 @@
+// Package auth проверяет API токены клиентов и их роли.
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cardinality"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
+	"github.com/VanGoghDev/practicum-metrics/internal/storage/serrors"
+	"go.uber.org/zap"
+)
+
+// Role роль токена. Администратору разрешено все, остальным ролям - только свои маршруты.
+type Role string
+
+const (
+	RoleReader Role = "reader" // чтение метрик
+	RoleWriter Role = "writer" // запись метрик
+	RoleAdmin  Role = "admin"  // удаление метрик и служебные маршруты
+)
+
+// hashPrefix помечает в файле токен, записанный в виде SHA-256, а не открытым текстом.
+const hashPrefix = "sha256:"
+
+var (
+	ErrInvalidToken   = errors.New("invalid api token entry")
+	ErrUnknownToken   = errors.New("unknown api token")
+	ErrMissingToken   = errors.New("api token is missing")
+	ErrForbidden      = errors.New("api token role is not allowed")
+	ErrNoTokenStorage = errors.New("api tokens table requires database storage")
+)
+
+// TokenSource возвращает роли токена по его хэшу SHA-256 в hex.
+// Для неизвестного токена возвращается serrors.ErrNotFound.
+type TokenSource interface {
+	TokenRoles(ctx context.Context, tokenHash string) (roles []string, err error)
+}
+
+// HashToken хэш токена, по которому он ищется в источниках. Сами токены сервер не хранит.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Identity идентификатор клиента с токеном token для лимитов: префикс хэша, чтобы токен не попадал в логи.
+func Identity(token string) string {
+	return "token:" + HashToken(token)[:16]
+}
+
+// ParseRoles разбирает список ролей через запятую.
+func ParseRoles(s string) ([]Role, error) {
+	var roles []Role
+	for _, r := range strings.Split(s, ",") {
+		role := Role(strings.TrimSpace(r))
+		switch role {
+		case RoleReader, RoleWriter, RoleAdmin:
+			roles = append(roles, role)
+		default:
+			return nil, fmt.Errorf("%w: unknown role %q", ErrInvalidToken, r)
+		}
+	}
+	return roles, nil
+}
+
+// FileSource токены из файла.
+type FileSource struct {
+	roles map[string][]string
+}
+
+// ParseTokens читает токены, по одному в строке: <токен> <роль>[,<роль>...].
+// Токен можно указать хэшем в виде sha256:<hex>. Пустые строки и строки, начинающиеся с #, пропускаются.
+func ParseTokens(r io.Reader) (*FileSource, error) {
+	fs := &FileSource{roles: make(map[string][]string)}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%w on line %d: expected token and roles", ErrInvalidToken, n)
+		}
+		if _, err := ParseRoles(fields[1]); err != nil {
+			return nil, fmt.Errorf("%w on line %d", err, n)
+		}
+
+		hash, ok := strings.CutPrefix(fields[0], hashPrefix)
+		if !ok {
+			hash = HashToken(fields[0])
+		}
+		hash = strings.ToLower(hash)
+		if _, dup := fs.roles[hash]; dup {
+			return nil, fmt.Errorf("%w on line %d: duplicate token", ErrInvalidToken, n)
+		}
+		fs.roles[hash] = strings.Split(fields[1], ",")
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read api tokens: %w", err)
+	}
+	return fs, nil
+}
+
+// LoadTokens читает токены из файла.
+func LoadTokens(path string) (*FileSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open api tokens: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	return ParseTokens(f)
+}
+
+func (fs *FileSource) TokenRoles(_ context.Context, tokenHash string) ([]string, error) {
+	roles, ok := fs.roles[tokenHash]
+	if !ok {
+		return nil, serrors.ErrNotFound
+	}
+	return roles, nil
+}
+
+// Authenticator проверяет токены по источникам в порядке их перечисления.
+// Без источников проверка отключена и разрешено все.
+type Authenticator struct {
+	zlog    *zap.SugaredLogger
+	sources []TokenSource
+}
+
+func New(zlog *zap.SugaredLogger, sources ...TokenSource) *Authenticator {
+	return &Authenticator{zlog: zlog, sources: sources}
+}
+
+// FromConfig создает проверку токенов из файла cfg.AuthTokensFile и, если включено cfg.AuthTokensDB,
+// из таблицы api_tokens хранилища db.
+func FromConfig(zlog *zap.SugaredLogger, cfg *config.Config, db any) (*Authenticator, error) {
+	var sources []TokenSource
+	if cfg.AuthTokensFile != "" {
+		fs, err := LoadTokens(cfg.AuthTokensFile)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, fs)
+	}
+	if cfg.AuthTokensDB {
+		ts, ok := db.(TokenSource)
+		if !ok {
+			return nil, ErrNoTokenStorage
+		}
+		sources = append(sources, ts)
+	}
+	return New(zlog, sources...), nil
+}
+
+// Enabled сообщает, что токены проверяются.
+func (a *Authenticator) Enabled() bool {
+	return a != nil && len(a.sources) > 0
+}
+
+// Authorize проверяет, что токену разрешена роль role.
+// Возвращает ErrMissingToken, ErrUnknownToken или ErrForbidden.
+func (a *Authenticator) Authorize(ctx context.Context, token string, role Role) error {
+	if !a.Enabled() {
+		return nil
+	}
+	if token == "" {
+		return ErrMissingToken
+	}
+
+	hash := HashToken(token)
+	for _, s := range a.sources {
+		roles, err := s.TokenRoles(ctx, hash)
+		if errors.Is(err, serrors.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to look up api token: %w", err)
+		}
+		if slices.Contains(roles, string(role)) || slices.Contains(roles, string(RoleAdmin)) {
+			return nil
+		}
+		return ErrForbidden
+	}
+	return ErrUnknownToken
+}
+
+// Token достает токен из заголовка Authorization: Bearer <токен>, либо из пароля
+// Basic авторизации, чтобы дашборд можно было открыть в браузере.
+func Token(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	if token, ok := strings.CutPrefix(h, "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	if enc, ok := strings.CutPrefix(h, "Basic "); ok {
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(enc))
+		if err != nil {
+			return ""
+		}
+		if _, password, ok := strings.Cut(string(data), ":"); ok {
+			return password
+		}
+	}
+	return ""
+}
+
+// Require пропускает только запросы с токеном, которому разрешена роль role.
+// Без токена или с неизвестным токеном отвечает 401, с токеном другой роли - 403.
+func (a *Authenticator) Require(role Role) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			token := Token(r)
+			err := a.Authorize(r.Context(), token, role)
+			if err == nil {
+				if a.Enabled() {
+					// лимиты на источник считаются по проверенному токену, а не по адресу клиента.
+					r = r.WithContext(cardinality.WithSource(r.Context(), Identity(token)))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			switch {
+			case errors.Is(err, ErrMissingToken), errors.Is(err, ErrUnknownToken):
+				a.zlog.Warnf("rejected request %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+				w.Header().Set("WWW-Authenticate", `Bearer, Basic realm="metrics"`)
+				handlers.WriteError(w, handlers.NewError(http.StatusUnauthorized, handlers.CodeUnauthorized, err.Error(), ""))
+			case errors.Is(err, ErrForbidden):
+				a.zlog.Warnf("rejected request %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+				handlers.WriteError(w, handlers.NewError(http.StatusForbidden, handlers.CodeForbidden,
+					fmt.Sprintf("%s role is required", role), ""))
+			default:
+				a.zlog.Errorf("failed to authorize request %s %s: %v", r.Method, r.URL.Path, err)
+				handlers.WriteError(w, handlers.InternalError(""))
+			}
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
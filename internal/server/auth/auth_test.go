@@ -0,0 +1,189 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/server/auth"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cardinality"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/logger"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers/chirouter"
+	"github.com/VanGoghDev/practicum-metrics/internal/storage/memstorage"
+)
+
+const tokens = `# agents
+writer-token writer
+reader-token reader
+both-token reader,writer
+admin-token admin
+`
+
+func TestParseTokens(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "plain tokens", input: tokens},
+		{name: "hashed token", input: "sha256:" + auth.HashToken("secret") + " reader\n"},
+		{name: "unknown role", input: "token superuser\n", wantErr: true},
+		{name: "missing roles", input: "token\n", wantErr: true},
+		{name: "duplicate token", input: "token reader\ntoken writer\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := auth.ParseTokens(strings.NewReader(tt.input))
+			if tt.wantErr {
+				require.ErrorIs(t, err, auth.ErrInvalidToken)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	log, _ := logger.New("Info")
+	fs, err := auth.ParseTokens(strings.NewReader(tokens + "sha256:" + auth.HashToken("hashed") + " writer\n"))
+	require.NoError(t, err)
+	a := auth.New(log.Sugar(), fs)
+
+	tests := []struct {
+		want  error
+		name  string
+		token string
+		role  auth.Role
+	}{
+		{name: "writer writes", token: "writer-token", role: auth.RoleWriter},
+		{name: "writer reads", token: "writer-token", role: auth.RoleReader, want: auth.ErrForbidden},
+		{name: "reader reads", token: "reader-token", role: auth.RoleReader},
+		{name: "reader writes", token: "reader-token", role: auth.RoleWriter, want: auth.ErrForbidden},
+		{name: "several roles", token: "both-token", role: auth.RoleWriter},
+		{name: "several roles without admin", token: "both-token", role: auth.RoleAdmin, want: auth.ErrForbidden},
+		{name: "admin deletes", token: "admin-token", role: auth.RoleAdmin},
+		{name: "admin writes", token: "admin-token", role: auth.RoleWriter},
+		{name: "hashed token", token: "hashed", role: auth.RoleWriter},
+		{name: "unknown token", token: "other", role: auth.RoleReader, want: auth.ErrUnknownToken},
+		{name: "missing token", role: auth.RoleReader, want: auth.ErrMissingToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := a.Authorize(context.Background(), tt.token, tt.role)
+			if tt.want == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tt.want)
+		})
+	}
+
+	// без источников токенов проверка отключена.
+	require.NoError(t, auth.New(log.Sugar()).Authorize(context.Background(), "", auth.RoleAdmin))
+}
+
+func TestRequire_Source(t *testing.T) {
+	log, _ := logger.New("Info")
+	fs, err := auth.ParseTokens(strings.NewReader(tokens))
+	require.NoError(t, err)
+
+	var source string
+	h := auth.New(log.Sugar(), fs).Require(auth.RoleWriter)(http.HandlerFunc(func(_ http.ResponseWriter,
+		r *http.Request) {
+		source = cardinality.Source(r)
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+	r.Header.Set("Authorization", "Bearer writer-token")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, auth.Identity("writer-token"), source)
+}
+
+func TestFromConfig_NoTokenStorage(t *testing.T) {
+	log, _ := logger.New("Info")
+	memstrg, _ := memstorage.New(log)
+	_, err := auth.FromConfig(log.Sugar(), &config.Config{AuthTokensDB: true}, memstrg)
+	require.ErrorIs(t, err, auth.ErrNoTokenStorage)
+}
+
+func TestRoutes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	require.NoError(t, os.WriteFile(path, []byte(tokens), 0o600))
+
+	log, _ := logger.New("Info")
+	memstrg, _ := memstorage.New(log)
+	r, err := chirouter.BuildRouter(memstrg, log, &config.Config{AuthTokensFile: path})
+	require.NoError(t, err)
+
+	basic := func(token string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte("user:"+token))
+	}
+
+	// запросы выполняются по порядку: метрика сначала записывается, затем читается и удаляется.
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		authz  string
+		status int
+	}{
+		{name: "write without token", method: http.MethodPost, target: "/update/gauge/Alloc/1",
+			status: http.StatusUnauthorized},
+		{name: "write with unknown token", method: http.MethodPost, target: "/update/gauge/Alloc/1",
+			authz: "Bearer other", status: http.StatusUnauthorized},
+		{name: "write with reader token", method: http.MethodPost, target: "/update/gauge/Alloc/1",
+			authz: "Bearer reader-token", status: http.StatusForbidden},
+		{name: "write with writer token", method: http.MethodPost, target: "/update/gauge/Alloc/1",
+			authz: "Bearer writer-token", status: http.StatusOK},
+		{name: "batch write with writer token", method: http.MethodPost, target: "/updates/",
+			body: `[{"id":"PollCount","type":"counter","delta":1}]`, authz: "Bearer writer-token", status: http.StatusOK},
+		{name: "read with writer token", method: http.MethodGet, target: "/value/gauge/Alloc",
+			authz: "Bearer writer-token", status: http.StatusForbidden},
+		{name: "read with reader token", method: http.MethodGet, target: "/value/gauge/Alloc",
+			authz: "Bearer reader-token", status: http.StatusOK},
+		{name: "list without token", method: http.MethodGet, target: "/api/v1/metrics/", status: http.StatusUnauthorized},
+		{name: "list with reader token", method: http.MethodGet, target: "/api/v1/metrics/",
+			authz: "Bearer reader-token", status: http.StatusOK},
+		{name: "dashboard with basic auth", method: http.MethodGet, target: "/",
+			authz: basic("reader-token"), status: http.StatusOK},
+		{name: "delete with writer token", method: http.MethodDelete, target: "/api/v1/metrics/gauge/Alloc",
+			authz: "Bearer writer-token", status: http.StatusForbidden},
+		{name: "delete with admin token", method: http.MethodDelete, target: "/api/v1/metrics/gauge/Alloc",
+			authz: "Bearer admin-token", status: http.StatusNoContent},
+		{name: "limits with reader token", method: http.MethodGet, target: "/limits/",
+			authz: "Bearer reader-token", status: http.StatusForbidden},
+		{name: "ping without token", method: http.MethodGet, target: "/ping/", status: http.StatusOK},
+		{name: "openapi without token", method: http.MethodGet, target: "/api/v1/openapi.json", status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if tt.authz != "" {
+				req.Header.Set("Authorization", tt.authz)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			if tt.status == http.StatusUnauthorized {
+				assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package cardinality
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers"
+)
+
+type sourceKey struct{}
+
+var (
+	ErrSeriesLimit = errors.New("series limit exceeded")
+	ErrSourceLimit = errors.New("series limit per source exceeded")
+)
+
+// LimitError ошибка превышения лимита.
+// ID - имя метрики, которая создала бы новую серию сверх лимита.
+type LimitError struct {
+	Err    error
+	ID     string
+	Limit  int64
+	Source string
+}
+
+func (e *LimitError) Error() string {
+	if errors.Is(e.Err, ErrSourceLimit) {
+		return fmt.Sprintf("%v: metric %q would exceed %d series for source %s", e.Err, e.ID, e.Limit, e.Source)
+	}
+	return fmt.Sprintf("%v: metric %q would exceed %d series", e.Err, e.ID, e.Limit)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// Stats счетчики отклоненных записей.
+type Stats struct {
+	Series              int   `json:"series"`
+	RejectedSeriesLimit int64 `json:"rejected_series_limit"`
+	RejectedSourceLimit int64 `json:"rejected_source_limit"`
+	RejectedWritesTotal int64 `json:"rejected_writes_total"`
+	MaxSeries           int64 `json:"max_series"`
+	MaxSeriesPerSource  int64 `json:"max_series_per_source"`
+}
+
+// Limiter ограничивает количество серий, которые могут быть созданы на сервере целиком
+// и каждым источником отдельно. Серия - пара тип и имя метрики, поэтому gauge и counter
+// с одним именем - разные серии. Нулевое значение любого лимита означает отсутствие ограничения.
+//
+// Запись проверяется в два шага: Check перед сохранением и Commit после успешного сохранения,
+// чтобы неудачная запись не занимала место в лимите. Одновременные записи новых серий
+// могут превысить лимит на размер этих записей.
+type Limiter struct {
+	storage   routers.Storage
+	series    map[models.MetricKey]struct{}
+	perSource map[string]map[models.MetricKey]struct{}
+
+	maxSeries          int64
+	maxSeriesPerSource int64
+
+	rejectedSeries atomic.Int64
+	rejectedSource atomic.Int64
+
+	mu     sync.Mutex
+	seeded bool
+}
+
+func New(cfg *config.Config, s routers.Storage) *Limiter {
+	return &Limiter{
+		storage:            s,
+		series:             make(map[models.MetricKey]struct{}),
+		perSource:          make(map[string]map[models.MetricKey]struct{}),
+		maxSeries:          cfg.MaxSeries,
+		maxSeriesPerSource: cfg.MaxSeriesPerSource,
+	}
+}
+
+// WithSource сохраняет в контексте проверенный идентификатор клиента, например по API токену.
+// Только такой идентификатор используется как источник вместо IP адреса.
+func WithSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// SourceFromContext возвращает идентификатор, сохраненный через WithSource.
+func SourceFromContext(ctx context.Context) (string, bool) {
+	source, ok := ctx.Value(sourceKey{}).(string)
+	return source, ok && source != ""
+}
+
+// Source возвращает идентификатор источника запроса: проверенный идентификатор клиента
+// из контекста (см. WithSource), либо IP адрес соединения. Заголовкам, которые клиент
+// выбирает сам, источник не доверяет, иначе лимит на источник обходился бы их сменой.
+func Source(r *http.Request) string {
+	if source, ok := SourceFromContext(r.Context()); ok {
+		return source
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Check проверяет, можно ли записать серии keys от источника source. Серии при этом не регистрируются,
+// после успешного сохранения их нужно зарегистрировать через Commit.
+// Батч либо принимается целиком, либо отклоняется целиком.
+func (l *Limiter) Check(ctx context.Context, source string, keys ...models.MetricKey) error {
+	if l == nil || (l.maxSeries <= 0 && l.maxSeriesPerSource <= 0) {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.seed(ctx); err != nil {
+		return err
+	}
+
+	newSeries := l.newSeries(keys)
+	if len(newSeries) == 0 {
+		return nil
+	}
+
+	if l.maxSeries > 0 && int64(len(l.series)+len(newSeries)) > l.maxSeries {
+		l.rejectedSeries.Add(1)
+		return &LimitError{Err: ErrSeriesLimit, ID: newSeries[0].ID, Limit: l.maxSeries, Source: source}
+	}
+
+	if l.maxSeriesPerSource > 0 && int64(len(l.perSource[source])+len(newSeries)) > l.maxSeriesPerSource {
+		l.rejectedSource.Add(1)
+		return &LimitError{Err: ErrSourceLimit, ID: newSeries[0].ID, Limit: l.maxSeriesPerSource, Source: source}
+	}
+	return nil
+}
+
+// Commit регистрирует записанные источником source серии. Вызывается после успешного сохранения.
+func (l *Limiter) Commit(source string, keys ...models.MetricKey) {
+	if l == nil || (l.maxSeries <= 0 && l.maxSeriesPerSource <= 0) {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	newSeries := l.newSeries(keys)
+	for _, key := range newSeries {
+		l.series[key] = struct{}{}
+	}
+	// серии по источникам нужны только для лимита на источник. Каждая серия закрепляется
+	// только за источником, который создал ее первым, поэтому perSource не больше series.
+	if l.maxSeriesPerSource <= 0 || len(newSeries) == 0 {
+		return
+	}
+	owned := l.perSource[source]
+	if owned == nil {
+		owned = make(map[models.MetricKey]struct{}, len(newSeries))
+		l.perSource[source] = owned
+	}
+	for _, key := range newSeries {
+		owned[key] = struct{}{}
+	}
+}
+
+// Forget освобождает место в лимитах, занятое удаленной серией key.
+// Вызывается после успешного удаления метрики из хранилища.
+func (l *Limiter) Forget(key models.MetricKey) {
+	if l == nil || (l.maxSeries <= 0 && l.maxSeriesPerSource <= 0) {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.series, key)
+	for source, owned := range l.perSource {
+		delete(owned, key)
+		if len(owned) == 0 {
+			delete(l.perSource, source)
+		}
+	}
+}
+
+// Filter оставляет из metrics только метрики, которые источник source может записать не превышая лимиты,
+// и возвращает первую ошибку превышения лимита. В отличие от Check батч не отклоняется целиком:
+// так работают приемники построчных протоколов (StatsD, Graphite), где одна лишняя серия
+// не должна мешать записи остальных. Новые серии учитываются в лимите по мере прохода по батчу.
+// Принятые серии после успешного сохранения нужно зарегистрировать через Commit.
+func (l *Limiter) Filter(ctx context.Context, source string, metrics []*models.Metrics) ([]*models.Metrics, error) {
+	if l == nil || (l.maxSeries <= 0 && l.maxSeriesPerSource <= 0) {
+		return metrics, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.seed(ctx); err != nil {
+		return nil, err
+	}
+
+	admitted := make([]*models.Metrics, 0, len(metrics))
+	pending := make(map[models.MetricKey]struct{})
+	var limitErr error
+	for _, m := range metrics {
+		key := models.MetricKey{ID: m.ID, MType: m.MType}
+		_, known := l.series[key]
+		_, seen := pending[key]
+		if known || seen {
+			admitted = append(admitted, m)
+			continue
+		}
+
+		var err error
+		switch {
+		case l.maxSeries > 0 && int64(len(l.series)+len(pending)) >= l.maxSeries:
+			l.rejectedSeries.Add(1)
+			err = &LimitError{Err: ErrSeriesLimit, ID: m.ID, Limit: l.maxSeries, Source: source}
+		case l.maxSeriesPerSource > 0 && int64(len(l.perSource[source])+len(pending)) >= l.maxSeriesPerSource:
+			l.rejectedSource.Add(1)
+			err = &LimitError{Err: ErrSourceLimit, ID: m.ID, Limit: l.maxSeriesPerSource, Source: source}
+		}
+		if err != nil {
+			if limitErr == nil {
+				limitErr = err
+			}
+			continue
+		}
+		pending[key] = struct{}{}
+		admitted = append(admitted, m)
+	}
+	return admitted, limitErr
+}
+
+// Keys возвращает ключи серий переданных метрик.
+func Keys(metrics []*models.Metrics) []models.MetricKey {
+	keys := make([]models.MetricKey, 0, len(metrics))
+	for _, m := range metrics {
+		keys = append(keys, models.MetricKey{ID: m.ID, MType: m.MType})
+	}
+	return keys
+}
+
+// newSeries возвращает еще не зарегистрированные серии без повторов. Вызывается под мьютексом.
+func (l *Limiter) newSeries(keys []models.MetricKey) []models.MetricKey {
+	newSeries := make([]models.MetricKey, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := l.series[key]; ok {
+			continue
+		}
+		if slices.Contains(newSeries, key) {
+			continue
+		}
+		newSeries = append(newSeries, key)
+	}
+	return newSeries
+}
+
+// Stats возвращает текущее количество серий и счетчики отклоненных записей.
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	series := len(l.series)
+	l.mu.Unlock()
+
+	rejectedSeries := l.rejectedSeries.Load()
+	rejectedSource := l.rejectedSource.Load()
+
+	return Stats{
+		Series:              series,
+		RejectedSeriesLimit: rejectedSeries,
+		RejectedSourceLimit: rejectedSource,
+		RejectedWritesTotal: rejectedSeries + rejectedSource,
+		MaxSeries:           l.maxSeries,
+		MaxSeriesPerSource:  l.maxSeriesPerSource,
+	}
+}
+
+// seed при первом обращении загружает из хранилища уже существующие серии,
+// чтобы после рестарта сервера лимит учитывал восстановленные метрики.
+// Вызывается под мьютексом.
+func (l *Limiter) seed(ctx context.Context) error {
+	if l.seeded || l.storage == nil {
+		return nil
+	}
+
+	gauges, err := l.storage.Gauges(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load gauges: %w", err)
+	}
+	counters, err := l.storage.Counters(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load counters: %w", err)
+	}
+	histograms, err := l.storage.ListMetrics(ctx, &models.ListQuery{MType: "histogram"})
+	if err != nil {
+		return fmt.Errorf("failed to load histograms: %w", err)
+	}
+
+	for _, g := range gauges {
+		l.series[models.MetricKey{ID: g.Name, MType: "gauge"}] = struct{}{}
+	}
+	for _, c := range counters {
+		l.series[models.MetricKey{ID: c.Name, MType: "counter"}] = struct{}{}
+	}
+	for _, h := range histograms {
+		l.series[models.MetricKey{ID: h.ID, MType: h.MType}] = struct{}{}
+	}
+	l.seeded = true
+	return nil
+}
@@ -0,0 +1,200 @@
+package cardinality
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/logger"
+	"github.com/VanGoghDev/practicum-metrics/internal/storage/memstorage"
+	"github.com/stretchr/testify/assert"
+)
+
+type write struct {
+	source string
+	names  []string
+	err    error
+	// failed запись проверена, но не сохранена, поэтому не регистрируется через Commit.
+	failed bool
+	// forget серии names удалены, запись не проверяется.
+	forget bool
+}
+
+// keys возвращает ключи серий: имя с суффиксом ":counter" - счетчик, иначе gauge.
+func keys(names []string) []models.MetricKey {
+	res := make([]models.MetricKey, 0, len(names))
+	for _, n := range names {
+		if id, ok := strings.CutSuffix(n, ":counter"); ok {
+			res = append(res, models.MetricKey{ID: id, MType: "counter"})
+			continue
+		}
+		res = append(res, models.MetricKey{ID: n, MType: "gauge"})
+	}
+	return res
+}
+
+func TestLimiter_Admit(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		gauges  map[string]float64
+		writes  []write
+		wantErr int64
+	}{
+		{
+			name: "unlimited",
+			cfg:  config.Config{},
+			writes: []write{
+				{source: "a", names: []string{"m1", "m2", "m3"}},
+			},
+		},
+		{
+			name: "total series limit",
+			cfg:  config.Config{MaxSeries: 2},
+			writes: []write{
+				{source: "a", names: []string{"m1"}},
+				{source: "b", names: []string{"m2"}},
+				{source: "b", names: []string{"m2"}},
+				{source: "a", names: []string{"m3"}, err: ErrSeriesLimit},
+			},
+			wantErr: 1,
+		},
+		{
+			name:   "restored series count towards limit",
+			cfg:    config.Config{MaxSeries: 2},
+			gauges: map[string]float64{"m1": 1, "m2": 2},
+			writes: []write{
+				{source: "a", names: []string{"m1", "m2"}},
+				{source: "a", names: []string{"m3"}, err: ErrSeriesLimit},
+			},
+			wantErr: 1,
+		},
+		{
+			name: "per source limit",
+			cfg:  config.Config{MaxSeriesPerSource: 2},
+			writes: []write{
+				{source: "a", names: []string{"m1", "m2"}},
+				{source: "a", names: []string{"m3"}, err: ErrSourceLimit},
+				{source: "b", names: []string{"m1", "m3", "m4"}},
+			},
+			wantErr: 1,
+		},
+		{
+			name: "failed write does not take series",
+			cfg:  config.Config{MaxSeries: 1, MaxSeriesPerSource: 1},
+			writes: []write{
+				{source: "a", names: []string{"m1"}, failed: true},
+				{source: "a", names: []string{"m2"}},
+				{source: "a", names: []string{"m1"}, err: ErrSeriesLimit},
+			},
+			wantErr: 1,
+		},
+		{
+			name:   "gauge and counter with the same name are different series",
+			cfg:    config.Config{MaxSeries: 2},
+			gauges: map[string]float64{"m1": 1},
+			writes: []write{
+				{source: "a", names: []string{"m1:counter"}},
+				{source: "a", names: []string{"m1"}},
+				{source: "a", names: []string{"m2"}, err: ErrSeriesLimit},
+			},
+			wantErr: 1,
+		},
+		{
+			name: "deleted series frees the limits",
+			cfg:  config.Config{MaxSeries: 2, MaxSeriesPerSource: 1},
+			writes: []write{
+				{source: "a", names: []string{"m1"}},
+				{source: "b", names: []string{"m2"}},
+				{source: "a", names: []string{"m3"}, err: ErrSeriesLimit},
+				{names: []string{"m1"}, forget: true},
+				{source: "a", names: []string{"m3"}},
+			},
+			wantErr: 1,
+		},
+		{
+			name: "batch is rejected as a whole",
+			cfg:  config.Config{MaxSeries: 2},
+			writes: []write{
+				{source: "a", names: []string{"m1", "m2", "m3"}, err: ErrSeriesLimit},
+				{source: "a", names: []string{"m1", "m2"}},
+			},
+			wantErr: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, _ := logger.New("Info")
+			s, _ := memstorage.New(log)
+			if tt.gauges != nil {
+				s.GaugesM = tt.gauges
+			}
+			l := New(&tt.cfg, s)
+
+			for _, w := range tt.writes {
+				if w.forget {
+					for _, key := range keys(w.names) {
+						l.Forget(key)
+					}
+					continue
+				}
+				err := l.Check(context.Background(), w.source, keys(w.names)...)
+				assert.ErrorIs(t, err, w.err)
+				if err == nil && !w.failed {
+					l.Commit(w.source, keys(w.names)...)
+				}
+			}
+			assert.Equal(t, tt.wantErr, l.Stats().RejectedWritesTotal)
+		})
+	}
+}
+
+func TestSource(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+	r.RemoteAddr = "10.1.2.3:5000"
+	// заголовок, который клиент выбирает сам, не меняет источник.
+	r.Header.Set("X-Tenant-ID", "other")
+	assert.Equal(t, "10.1.2.3", Source(r))
+
+	r = r.WithContext(WithSource(r.Context(), "token:abc"))
+	assert.Equal(t, "token:abc", Source(r))
+}
+
+func TestLimiter_Filter(t *testing.T) {
+	gauge := func(id string) *models.Metrics {
+		v := 1.0
+		return &models.Metrics{ID: id, MType: "gauge", Value: &v}
+	}
+
+	log, _ := logger.New("Info")
+	s, _ := memstorage.New(log)
+	l := New(&config.Config{MaxSeries: 3, MaxSeriesPerSource: 2}, s)
+
+	// повторы серии в батче занимают место в лимите один раз.
+	metrics, err := l.Filter(context.Background(), "a", []*models.Metrics{gauge("m1"), gauge("m1"), gauge("m2"),
+		gauge("m3")})
+	assert.ErrorIs(t, err, ErrSourceLimit)
+	assert.Equal(t, []string{"m1", "m1", "m2"}, ids(metrics))
+	l.Commit("a", Keys(metrics)...)
+
+	metrics, err = l.Filter(context.Background(), "b", []*models.Metrics{gauge("m1"), gauge("m3"), gauge("m4")})
+	assert.ErrorIs(t, err, ErrSeriesLimit)
+	assert.Equal(t, []string{"m1", "m3"}, ids(metrics))
+
+	stats := l.Stats()
+	assert.Equal(t, int64(1), stats.RejectedSeriesLimit)
+	assert.Equal(t, int64(1), stats.RejectedSourceLimit)
+}
+
+func ids(metrics []*models.Metrics) []string {
+	res := make([]string, 0, len(metrics))
+	for _, m := range metrics {
+		res = append(res, m.ID)
+	}
+	return res
+}
@@ -11,17 +11,63 @@ import (
 )
 
 type Config struct {
-	Address            string `env:"ADDRESS"`
-	Loglevel           string `env:"LOGLVL"`
-	FileStoragePath    string `env:"FILE_STORAGE_PATH"`
-	DBConnectionString string `env:"DATABASE_DSN"`
-	Key                string `env:"KEY"`
-	Restore            bool   `env:"RESTORE"`
-	StoreInterval      time.Duration
+	Address              string `env:"ADDRESS"`
+	Loglevel             string `env:"LOGLVL"`
+	FileStoragePath      string `env:"FILE_STORAGE_PATH"`
+	DBConnectionString   string `env:"DATABASE_DSN"`
+	Key                  string `env:"KEY"`
+	KeysFile             string `env:"KEYS_FILE"`
+	CryptoKey            string `env:"CRYPTO_KEY"`
+	TrustedSubnet        string `env:"TRUSTED_SUBNET"`
+	AuthTokensFile       string `env:"AUTH_TOKENS_FILE"`
+	TLSCert              string `env:"TLS_CERT"`
+	TLSKey               string `env:"TLS_KEY"`
+	TLSMinVersion        string `env:"TLS_MIN_VERSION"`
+	TLSClientCA          string `env:"TLS_CLIENT_CA"`
+	NameAllowedChars     string `env:"NAME_ALLOWED_CHARS"`
+	NameReservedPrefixes string `env:"NAME_RESERVED_PREFIXES"`
+	NameReplaceInvalid   string `env:"NAME_REPLACE_INVALID"`
+	StatsdAddress        string `env:"STATSD_ADDRESS"`
+	GraphiteAddress      string `env:"GRAPHITE_ADDRESS"`
+	GraphiteRules        string `env:"GRAPHITE_RULES"`
+	GRPCAddress          string `env:"GRPC_ADDRESS"`
+	CounterSuffixes      string `env:"COUNTER_SUFFIXES"`
+	NameLowercase        bool   `env:"NAME_LOWERCASE"`
+	Restore              bool   `env:"RESTORE"`
+	SignatureStrict      bool   `env:"SIGNATURE_STRICT"`
+	AuthTokensDB         bool   `env:"AUTH_TOKENS_DB"`
+	MaxSeries            int64  `env:"MAX_SERIES"`
+	MaxSeriesPerSource   int64  `env:"MAX_SERIES_PER_SOURCE"`
+	MaxNameLength        int64  `env:"MAX_NAME_LENGTH"`
+	HistorySize          int64  `env:"HISTORY_SIZE"`
+	StoreInterval        time.Duration
+	HistoryInterval      time.Duration
+	StatsdFlushInterval  time.Duration
+	SignatureMaxSkew     time.Duration
+
+	// лимиты на клиента: запросов и записываемых метрик в секунду, 0 - без ограничения.
+	RateLimitRequests      int64 `env:"RATE_LIMIT_REQUESTS"`
+	RateLimitRequestsBurst int64 `env:"RATE_LIMIT_REQUESTS_BURST"`
+	RateLimitMetrics       int64 `env:"RATE_LIMIT_METRICS"`
+	RateLimitMetricsBurst  int64 `env:"RATE_LIMIT_METRICS_BURST"`
+
+	// ограничения размера запроса, 0 - значение по умолчанию.
+	MaxBodySize         int64 `env:"MAX_BODY_SIZE"`
+	MaxDecompressedSize int64 `env:"MAX_DECOMPRESSED_SIZE"`
+	MaxBatchSize        int64 `env:"MAX_BATCH_SIZE"`
 }
 
 const (
-	defaultStoreInterval int64 = 300
+	defaultStoreInterval   int64 = 300
+	defaultHistoryInterval int64 = 10
+	defaultHistorySize     int64 = 60
+	defaultStatsdFlush     int64 = 10
+	defaultSignatureSkew   int64 = 300
+	defaultMaxBodySize     int64 = 10 << 20
+	defaultMaxDecompressed int64 = 32 << 20
+	defaultMaxBatchSize    int64 = 10000
+
+	defaultCounterSuffixes = "_total,_count"
 )
 
 func Load() (config *Config, err error) {
@@ -30,16 +76,75 @@ func Load() (config *Config, err error) {
 		return nil, fmt.Errorf("failed to parse environment variables %w", err)
 	}
 
-	var flagStoreInterval int64
-	var flagAddress, flagFileStoragePath, flagLoglevel, flagDBConnection, flagKey string
-	var flagRestore bool
+	var flagHistoryInterval, flagHistorySize, flagStatsdFlush, flagSignatureSkew int64
+	var flagStatsdAddress, flagGraphiteAddress, flagGraphiteRules, flagCounterSuffixes, flagGRPCAddress string
+	var flagStoreInterval, flagMaxSeries, flagMaxSeriesPerSource, flagMaxNameLength int64
+	var flagAddress, flagFileStoragePath, flagLoglevel, flagDBConnection, flagKey, flagKeysFile, flagCryptoKey string
+	var flagNameAllowedChars, flagNameReservedPrefixes, flagNameReplaceInvalid string
+	var flagTLSCert, flagTLSKey, flagTLSMinVersion, flagTLSClientCA, flagTrustedSubnet string
+	var flagAuthTokensFile string
+	var flagRateRequests, flagRateRequestsBurst, flagRateMetrics, flagRateMetricsBurst int64
+	var flagMaxBodySize, flagMaxDecompressedSize, flagMaxBatchSize int64
+	var flagRestore, flagNameLowercase, flagSignatureStrict, flagAuthTokensDB bool
 	flag.StringVar(&flagAddress, "a", "localhost:8080", "address and port to run server")
 	flag.StringVar(&flagLoglevel, "lvl", "info", "log level")
+	flag.StringVar(&flagTrustedSubnet, "t", "",
+		"trusted subnet in CIDR notation, metrics from other addresses are rejected")
+	flag.StringVar(&flagAuthTokensFile, "auth-tokens", "",
+		"path to API tokens, one \"<token> <role>[,<role>]\" per line, roles: reader, writer, admin")
+	flag.BoolVar(&flagAuthTokensDB, "auth-tokens-db", false, "check API tokens against the api_tokens database table")
+	flag.StringVar(&flagTLSCert, "tls-cert", "", "path to TLS certificate, if set server accepts HTTPS and gRPC over TLS")
+	flag.StringVar(&flagTLSKey, "tls-key", "", "path to TLS private key")
+	flag.StringVar(&flagTLSMinVersion, "tls-min-version", "1.2", "minimum TLS version: 1.2 or 1.3")
+	flag.StringVar(&flagTLSClientCA, "tls-client-ca", "",
+		"path to CA bundle, if set clients must present a certificate signed by it (mTLS)")
 	flag.StringVar(&flagKey, "k", "", "signature key")
+	flag.StringVar(&flagKeysFile, "keys-file", "",
+		"path to signature keys, one \"<key id> <key>\" per line, reloaded on SIGHUP")
+	flag.StringVar(&flagCryptoKey, "crypto-key", "", "path to private RSA or X25519 key to decrypt agent requests")
+	flag.BoolVar(&flagSignatureStrict, "signature-strict", true,
+		"reject agent writes without a valid signature when the signature key is set")
+	flag.Int64Var(&flagSignatureSkew, "signature-skew", defaultSignatureSkew,
+		"max allowed difference between signed request timestamp and server time in seconds")
 	flag.Int64Var(&flagStoreInterval, "i", defaultStoreInterval, "store interval in seconds")
 	flag.StringVar(&flagFileStoragePath, "f", "", "path to file storage")
 	flag.StringVar(&flagDBConnection, "d", "", "db connection string")
 	flag.BoolVar(&flagRestore, "r", true, "restore previous state or not")
+	flag.Int64Var(&flagMaxSeries, "max-series", 0, "max total number of series (0 - unlimited)")
+	flag.Int64Var(&flagMaxSeriesPerSource, "max-series-per-source", 0,
+		"max number of series per API token or source ip (0 - unlimited)")
+	flag.Int64Var(&flagRateRequests, "rate-requests", 0, "max requests per second per client (0 - unlimited)")
+	flag.Int64Var(&flagRateRequestsBurst, "rate-requests-burst", 0,
+		"number of requests a client may send at once above the rate (default equals the rate)")
+	flag.Int64Var(&flagRateMetrics, "rate-metrics", 0,
+		"max metrics per second per client, a batch counts as its size (0 - unlimited)")
+	flag.Int64Var(&flagRateMetricsBurst, "rate-metrics-burst", 0,
+		"number of metrics a client may write at once above the rate (default equals the rate)")
+	flag.Int64Var(&flagMaxBodySize, "max-body-size", defaultMaxBodySize,
+		"max request body size in bytes as received, before decompression")
+	flag.Int64Var(&flagMaxDecompressedSize, "max-decompressed-size", defaultMaxDecompressed,
+		"max request body size in bytes after gzip or snappy decompression")
+	flag.Int64Var(&flagMaxBatchSize, "max-batch-size", defaultMaxBatchSize,
+		"max number of metrics in a /updates or gRPC batch")
+	flag.Int64Var(&flagMaxNameLength, "max-name-length", 0, "max metric name length (0 - unlimited)")
+	flag.StringVar(&flagNameAllowedChars, "name-chars", "",
+		"characters allowed in metric names, regexp character class contents without [, ] and ^ (default a-zA-Z0-9_.:-)")
+	flag.StringVar(&flagNameReservedPrefixes, "name-reserved-prefixes", "",
+		"comma separated list of reserved metric name prefixes")
+	flag.StringVar(&flagNameReplaceInvalid, "name-replace-invalid", "",
+		"replace invalid characters in metric names with given string instead of rejecting")
+	flag.BoolVar(&flagNameLowercase, "name-lowercase", false, "lowercase metric names")
+	flag.Int64Var(&flagHistoryInterval, "history-interval", defaultHistoryInterval,
+		"dashboard history sampling interval in seconds")
+	flag.Int64Var(&flagHistorySize, "history-size", defaultHistorySize, "number of history points kept per series")
+	flag.StringVar(&flagStatsdAddress, "statsd", "", "address to accept StatsD over UDP and TCP (empty - disabled)")
+	flag.Int64Var(&flagStatsdFlush, "statsd-flush", defaultStatsdFlush, "StatsD flush interval in seconds")
+	flag.StringVar(&flagGraphiteAddress, "graphite", "",
+		"address to accept Graphite plaintext protocol over TCP (empty - disabled)")
+	flag.StringVar(&flagGraphiteRules, "graphite-rules", "", "path to Graphite path mapping rules")
+	flag.StringVar(&flagGRPCAddress, "grpc", "", "address to run gRPC server (empty - disabled)")
+	flag.StringVar(&flagCounterSuffixes, "counter-suffixes", defaultCounterSuffixes,
+		"comma separated metric name suffixes of cumulative counters, used when the source does not send metric type")
 	flag.Parse()
 
 	if _, present := os.LookupEnv("ADDRESS"); !present {
@@ -50,6 +155,34 @@ func Load() (config *Config, err error) {
 		cfg.Loglevel = flagLoglevel
 	}
 
+	if _, present := os.LookupEnv("TRUSTED_SUBNET"); !present {
+		cfg.TrustedSubnet = flagTrustedSubnet
+	}
+
+	if _, present := os.LookupEnv("AUTH_TOKENS_FILE"); !present {
+		cfg.AuthTokensFile = flagAuthTokensFile
+	}
+
+	if _, present := os.LookupEnv("AUTH_TOKENS_DB"); !present {
+		cfg.AuthTokensDB = flagAuthTokensDB
+	}
+
+	if _, present := os.LookupEnv("TLS_CERT"); !present {
+		cfg.TLSCert = flagTLSCert
+	}
+
+	if _, present := os.LookupEnv("TLS_KEY"); !present {
+		cfg.TLSKey = flagTLSKey
+	}
+
+	if _, present := os.LookupEnv("TLS_MIN_VERSION"); !present {
+		cfg.TLSMinVersion = flagTLSMinVersion
+	}
+
+	if _, present := os.LookupEnv("TLS_CLIENT_CA"); !present {
+		cfg.TLSClientCA = flagTLSClientCA
+	}
+
 	if _, present := os.LookupEnv("FILE_STORAGE_PATH"); !present {
 		cfg.FileStoragePath = flagFileStoragePath
 	}
@@ -62,6 +195,20 @@ func Load() (config *Config, err error) {
 		cfg.Restore = flagRestore
 	}
 
+	if _, present := os.LookupEnv("SIGNATURE_STRICT"); !present {
+		cfg.SignatureStrict = flagSignatureStrict
+	}
+
+	if v, present := os.LookupEnv("SIGNATURE_MAX_SKEW"); !present {
+		cfg.SignatureMaxSkew = time.Duration(flagSignatureSkew) * time.Second
+	} else {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("unable to set signatureMaxSkew value: %w", err)
+		}
+		cfg.SignatureMaxSkew = time.Duration(i) * time.Second
+	}
+
 	if v, present := os.LookupEnv("STORE_INTERVAL"); !present {
 		cfg.StoreInterval = time.Duration(flagStoreInterval) * time.Second
 	} else {
@@ -76,5 +223,113 @@ func Load() (config *Config, err error) {
 		cfg.Key = flagKey
 	}
 
+	if _, present := os.LookupEnv("KEYS_FILE"); !present {
+		cfg.KeysFile = flagKeysFile
+	}
+
+	if _, present := os.LookupEnv("CRYPTO_KEY"); !present {
+		cfg.CryptoKey = flagCryptoKey
+	}
+
+	if _, present := os.LookupEnv("MAX_SERIES"); !present {
+		cfg.MaxSeries = flagMaxSeries
+	}
+
+	if _, present := os.LookupEnv("MAX_SERIES_PER_SOURCE"); !present {
+		cfg.MaxSeriesPerSource = flagMaxSeriesPerSource
+	}
+
+	if _, present := os.LookupEnv("RATE_LIMIT_REQUESTS"); !present {
+		cfg.RateLimitRequests = flagRateRequests
+	}
+
+	if _, present := os.LookupEnv("RATE_LIMIT_REQUESTS_BURST"); !present {
+		cfg.RateLimitRequestsBurst = flagRateRequestsBurst
+	}
+
+	if _, present := os.LookupEnv("RATE_LIMIT_METRICS"); !present {
+		cfg.RateLimitMetrics = flagRateMetrics
+	}
+
+	if _, present := os.LookupEnv("RATE_LIMIT_METRICS_BURST"); !present {
+		cfg.RateLimitMetricsBurst = flagRateMetricsBurst
+	}
+
+	if _, present := os.LookupEnv("MAX_BODY_SIZE"); !present {
+		cfg.MaxBodySize = flagMaxBodySize
+	}
+
+	if _, present := os.LookupEnv("MAX_DECOMPRESSED_SIZE"); !present {
+		cfg.MaxDecompressedSize = flagMaxDecompressedSize
+	}
+
+	if _, present := os.LookupEnv("MAX_BATCH_SIZE"); !present {
+		cfg.MaxBatchSize = flagMaxBatchSize
+	}
+
+	if _, present := os.LookupEnv("MAX_NAME_LENGTH"); !present {
+		cfg.MaxNameLength = flagMaxNameLength
+	}
+
+	if _, present := os.LookupEnv("NAME_ALLOWED_CHARS"); !present {
+		cfg.NameAllowedChars = flagNameAllowedChars
+	}
+
+	if _, present := os.LookupEnv("NAME_RESERVED_PREFIXES"); !present {
+		cfg.NameReservedPrefixes = flagNameReservedPrefixes
+	}
+
+	if _, present := os.LookupEnv("NAME_REPLACE_INVALID"); !present {
+		cfg.NameReplaceInvalid = flagNameReplaceInvalid
+	}
+
+	if _, present := os.LookupEnv("NAME_LOWERCASE"); !present {
+		cfg.NameLowercase = flagNameLowercase
+	}
+
+	if v, present := os.LookupEnv("HISTORY_INTERVAL"); !present {
+		cfg.HistoryInterval = time.Duration(flagHistoryInterval) * time.Second
+	} else {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("unable to set historyInterval value: %w", err)
+		}
+		cfg.HistoryInterval = time.Duration(i) * time.Second
+	}
+
+	if _, present := os.LookupEnv("HISTORY_SIZE"); !present {
+		cfg.HistorySize = flagHistorySize
+	}
+
+	if _, present := os.LookupEnv("STATSD_ADDRESS"); !present {
+		cfg.StatsdAddress = flagStatsdAddress
+	}
+
+	if _, present := os.LookupEnv("GRAPHITE_ADDRESS"); !present {
+		cfg.GraphiteAddress = flagGraphiteAddress
+	}
+
+	if _, present := os.LookupEnv("GRAPHITE_RULES"); !present {
+		cfg.GraphiteRules = flagGraphiteRules
+	}
+
+	if _, present := os.LookupEnv("GRPC_ADDRESS"); !present {
+		cfg.GRPCAddress = flagGRPCAddress
+	}
+
+	if _, present := os.LookupEnv("COUNTER_SUFFIXES"); !present {
+		cfg.CounterSuffixes = flagCounterSuffixes
+	}
+
+	if v, present := os.LookupEnv("STATSD_FLUSH_INTERVAL"); !present {
+		cfg.StatsdFlushInterval = time.Duration(flagStatsdFlush) * time.Second
+	} else {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("unable to set statsdFlushInterval value: %w", err)
+		}
+		cfg.StatsdFlushInterval = time.Duration(i) * time.Second
+	}
+
 	return &cfg, nil
 }
@@ -0,0 +1,194 @@
+package cumulative
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+)
+
+const (
+	// idleTTL сколько хранится серия или семейство счетчиков, которые клиенты больше не присылают.
+	idleTTL = time.Hour
+	// pruneInterval как часто из памяти удаляются такие записи.
+	pruneInterval = time.Minute
+	// defaultMaxEntries сколько записей каждого вида хранится в памяти одновременно.
+	defaultMaxEntries = 100000
+)
+
+// Tracker переводит накопительные значения счетчиков, которые присылают
+// Prometheus, OpenTelemetry и InfluxDB, в приращения, которые хранит сервер.
+// Первое значение серии только запоминается: неизвестно, какая его часть уже была учтена.
+// Значение меньше предыдущего считается сбросом счетчика у источника.
+//
+// Имена серий выбирают клиенты, поэтому память ограничена: серия, которую не присылали дольше idleTTL,
+// и самая давняя серия при заполнении памяти забываются. Следующее значение забытой серии
+// снова только запоминается.
+type Tracker struct {
+	last       entries[int64]
+	histograms entries[models.Histogram]
+	families   entries[struct{}]
+	suffixes   []string
+	lastPrune  time.Time
+	now        func() time.Time
+	mu         sync.Mutex
+}
+
+func New(cfg *config.Config) *Tracker {
+	suffixes := make([]string, 0)
+	for _, suffix := range strings.Split(cfg.CounterSuffixes, ",") {
+		if suffix = strings.TrimSpace(suffix); suffix != "" {
+			suffixes = append(suffixes, suffix)
+		}
+	}
+	return &Tracker{
+		last:       newEntries[int64](defaultMaxEntries),
+		histograms: newEntries[models.Histogram](defaultMaxEntries),
+		families:   newEntries[struct{}](defaultMaxEntries),
+		suffixes:   suffixes,
+		now:        time.Now,
+	}
+}
+
+// MarkCounter запоминает, что метрики с именем family - накопительные счетчики,
+// например по метаданным Prometheus, которые приходят отдельно от значений.
+func (t *Tracker) MarkCounter(family string) {
+	now := t.now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune(now)
+	t.families.swap(family, struct{}{}, now)
+}
+
+// IsCounter сообщает, считается ли метрика с таким именем накопительным счетчиком:
+// имя отмечено через MarkCounter или подходит под соглашение об именовании (cfg.CounterSuffixes),
+// когда источник не передает тип метрики.
+func (t *Tracker) IsCounter(name string) bool {
+	for _, suffix := range t.suffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, ok := t.families.items[name]
+	return ok
+}
+
+// Delta возвращает приращение серии id с момента предыдущего значения.
+// Дробные значения округляются, ошибка округления не накапливается.
+func (t *Tracker) Delta(id string, total float64) int64 {
+	cur := int64(math.Round(total))
+	now := t.now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune(now)
+	prev, ok := t.last.swap(id, cur, now)
+	switch {
+	case !ok:
+		return 0
+	case cur < prev:
+		return cur
+	default:
+		return cur - prev
+	}
+}
+
+// HistogramDelta возвращает приращение накопительной гистограммы серии id с момента предыдущего значения
+// (см. models.Histogram.Sub). Как и для счетчиков, первое значение только запоминается:
+// приращение пустое, с теми же границами бакетов.
+func (t *Tracker) HistogramDelta(id string, total models.Histogram) models.Histogram {
+	now := t.now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune(now)
+	prev, ok := t.histograms.swap(id, total.Clone(), now)
+	if !ok {
+		return total.Sub(total)
+	}
+	return total.Sub(prev)
+}
+
+// Forget забывает удаленную серию key, чтобы ее значения не занимали память.
+func (t *Tracker) Forget(key models.MetricKey) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch key.MType {
+	case "counter":
+		delete(t.last.items, key.ID)
+	case "histogram":
+		delete(t.histograms.items, key.ID)
+	}
+}
+
+// prune раз в pruneInterval удаляет записи, которые не обновлялись дольше idleTTL.
+// Вызывается под мьютексом.
+func (t *Tracker) prune(now time.Time) {
+	if now.Sub(t.lastPrune) < pruneInterval {
+		return
+	}
+	t.lastPrune = now
+	t.last.removeIdle(now)
+	t.histograms.removeIdle(now)
+	t.families.removeIdle(now)
+}
+
+type entry[V any] struct {
+	value V
+	seen  time.Time
+}
+
+// entries значения по именам, не больше max записей.
+type entries[V any] struct {
+	items map[string]entry[V]
+	max   int
+}
+
+func newEntries[V any](maxEntries int) entries[V] {
+	return entries[V]{items: make(map[string]entry[V]), max: maxEntries}
+}
+
+// swap сохраняет значение v и возвращает предыдущее. Если памяти для новой записи нет,
+// сначала удаляются записи старше idleTTL, а если таких нет - самая давняя запись.
+func (e *entries[V]) swap(id string, v V, now time.Time) (V, bool) {
+	prev, ok := e.items[id]
+	if !ok && len(e.items) >= e.max {
+		e.removeIdle(now)
+		if len(e.items) >= e.max {
+			e.removeOldest()
+		}
+	}
+	e.items[id] = entry[V]{value: v, seen: now}
+	return prev.value, ok
+}
+
+func (e *entries[V]) removeIdle(now time.Time) {
+	for id, it := range e.items {
+		if now.Sub(it.seen) > idleTTL {
+			delete(e.items, id)
+		}
+	}
+}
+
+func (e *entries[V]) removeOldest() {
+	var oldest string
+	var seen time.Time
+	for id, it := range e.items {
+		if oldest == "" || it.seen.Before(seen) {
+			oldest, seen = id, it.seen
+		}
+	}
+	delete(e.items, oldest)
+}
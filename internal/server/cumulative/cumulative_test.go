@@ -0,0 +1,110 @@
+package cumulative
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+)
+
+func TestTracker_Delta(t *testing.T) {
+	tr := New(&config.Config{})
+	steps := []struct {
+		id    string
+		total float64
+		want  int64
+	}{
+		{id: "a", total: 10, want: 0},
+		{id: "a", total: 15, want: 5},
+		{id: "b", total: 3, want: 0},
+		{id: "a", total: 15.4, want: 0},
+		{id: "a", total: 16.6, want: 2},
+		{id: "a", total: 4, want: 4},
+		{id: "b", total: 4, want: 1},
+	}
+
+	for _, st := range steps {
+		assert.Equal(t, st.want, tr.Delta(st.id, st.total), "%s=%v", st.id, st.total)
+	}
+}
+
+func TestTracker_IsCounter(t *testing.T) {
+	tr := New(&config.Config{CounterSuffixes: "_total, _count,"})
+	assert.True(t, tr.IsCounter("http_requests_total"))
+	assert.True(t, tr.IsCounter("rpc_duration_seconds_count"))
+	assert.False(t, tr.IsCounter("mem_used"))
+	assert.False(t, New(&config.Config{}).IsCounter("http_requests_total"))
+
+	tr.MarkCounter("jobs_done")
+	assert.True(t, tr.IsCounter("jobs_done"))
+}
+
+func TestTracker_Bounds(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tr := New(&config.Config{})
+	tr.now = func() time.Time { return now }
+
+	t.Run("idle series are forgotten", func(t *testing.T) {
+		tr.Delta("a", 10)
+		tr.MarkCounter("jobs_done")
+		now = now.Add(idleTTL + pruneInterval)
+		tr.Delta("b", 1)
+
+		assert.Equal(t, int64(0), tr.Delta("a", 15), "forgotten series starts over")
+		assert.False(t, tr.IsCounter("jobs_done"))
+	})
+
+	t.Run("oldest series is evicted when full", func(t *testing.T) {
+		tr.last.max = 2
+		now = now.Add(time.Second)
+		tr.Delta("a", 20)
+		now = now.Add(time.Second)
+		tr.Delta("c", 1)
+
+		assert.Len(t, tr.last.items, 2)
+		assert.NotContains(t, tr.last.items, "b")
+		assert.Equal(t, int64(5), tr.Delta("a", 25))
+	})
+
+	t.Run("deleted series are forgotten", func(t *testing.T) {
+		tr.HistogramDelta("latency", models.Histogram{Counts: []uint64{1}, Count: 1})
+		tr.Forget(models.MetricKey{ID: "a", MType: "counter"})
+		tr.Forget(models.MetricKey{ID: "latency", MType: "histogram"})
+
+		assert.NotContains(t, tr.last.items, "a")
+		assert.Empty(t, tr.histograms.items)
+	})
+}
+
+func TestTracker_HistogramDelta(t *testing.T) {
+	tr := New(&config.Config{})
+	bounds := []float64{1, 5}
+	steps := []struct {
+		name  string
+		total models.Histogram
+		want  models.Histogram
+	}{
+		{
+			name:  "first value is remembered",
+			total: models.Histogram{Bounds: bounds, Counts: []uint64{2, 1, 0}, Count: 3, Sum: 4},
+			want:  models.Histogram{Bounds: bounds, Counts: []uint64{0, 0, 0}},
+		},
+		{
+			name:  "increment",
+			total: models.Histogram{Bounds: bounds, Counts: []uint64{3, 2, 1}, Count: 6, Sum: 14},
+			want:  models.Histogram{Bounds: bounds, Counts: []uint64{1, 1, 1}, Count: 3, Sum: 10},
+		},
+		{
+			name:  "reset",
+			total: models.Histogram{Bounds: bounds, Counts: []uint64{1, 0, 0}, Count: 1, Sum: 0.5},
+			want:  models.Histogram{Bounds: bounds, Counts: []uint64{1, 0, 0}, Count: 1, Sum: 0.5},
+		},
+	}
+
+	for _, st := range steps {
+		assert.Equal(t, st.want, tr.HistogramDelta("latency", st.total), st.name)
+	}
+}
@@ -0,0 +1,112 @@
+package graphite
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/util/glob"
+)
+
+var ErrInvalidRule = errors.New("invalid graphite rule")
+
+// Rule правило преобразования пути Graphite в метрику.
+// Шаблон состоит из сегментов, разделенных точкой: сегмент {label} переносит сегмент пути в метку label
+// и исключает его из имени, остальные сегменты сравниваются с сегментами пути как шаблоны glob (* и ?).
+// Шаблон совпадает только с путем из того же количества сегментов.
+type Rule struct {
+	segments []string
+	counter  bool // значения записываются как приращения счетчика, а не как gauge
+}
+
+// Mapper применяет к пути первое совпавшее правило.
+// Путь, не подошедший ни под одно правило, записывается как gauge с именем, равным пути.
+type Mapper struct {
+	rules []Rule
+}
+
+// ParseRules читает правила, по одному в строке: <шаблон> [counter].
+// Пустые строки и строки, начинающиеся с #, пропускаются.
+func ParseRules(r io.Reader) (*Mapper, error) {
+	m := &Mapper{}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		rule := Rule{segments: strings.Split(fields[0], ".")}
+		for _, opt := range fields[1:] {
+			if opt != "counter" {
+				return nil, fmt.Errorf("%w on line %d: unknown option %q", ErrInvalidRule, n, opt)
+			}
+			rule.counter = true
+		}
+		for _, seg := range rule.segments {
+			if seg == "" || seg == "{}" {
+				return nil, fmt.Errorf("%w on line %d: empty segment in %q", ErrInvalidRule, n, fields[0])
+			}
+		}
+		m.rules = append(m.rules, rule)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read graphite rules: %w", err)
+	}
+	return m, nil
+}
+
+// LoadRules читает правила из файла, пустой путь - правил нет.
+func LoadRules(path string) (*Mapper, error) {
+	if path == "" {
+		return &Mapper{}, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open graphite rules: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	return ParseRules(f)
+}
+
+// Map возвращает имя метрики, метки и признак счетчика для пути Graphite.
+func (m *Mapper) Map(path string) (name string, labels map[string]string, counter bool) {
+	segments := strings.Split(path, ".")
+	for _, rule := range m.rules {
+		if name, labels, ok := rule.apply(segments); ok {
+			return name, labels, rule.counter
+		}
+	}
+	return path, nil, false
+}
+
+func (r Rule) apply(segments []string) (string, map[string]string, bool) {
+	if len(segments) != len(r.segments) {
+		return "", nil, false
+	}
+
+	var labels map[string]string
+	name := make([]string, 0, len(segments))
+	for i, pattern := range r.segments {
+		if label, ok := strings.CutPrefix(pattern, "{"); ok && strings.HasSuffix(label, "}") {
+			if labels == nil {
+				labels = make(map[string]string)
+			}
+			labels[strings.TrimSuffix(label, "}")] = segments[i]
+			continue
+		}
+		if !glob.Match(pattern, segments[i]) {
+			return "", nil, false
+		}
+		name = append(name, segments[i])
+	}
+	return strings.Join(name, "."), labels, true
+}
@@ -0,0 +1,85 @@
+package graphite
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapper_Map(t *testing.T) {
+	m, err := ParseRules(strings.NewReader(`
+# hosts
+servers.{host}.cpu.*
+jobs.{job}.runs counter
+apps.{app}.requests.{code} counter
+`))
+	require.NoError(t, err)
+
+	tests := []struct {
+		labels  map[string]string
+		path    string
+		name    string
+		counter bool
+	}{
+		{
+			path:   "servers.web1.cpu.load",
+			name:   "servers.cpu.load",
+			labels: map[string]string{"host": "web1"},
+		},
+		{
+			path:    "jobs.backup.runs",
+			name:    "jobs.runs",
+			labels:  map[string]string{"job": "backup"},
+			counter: true,
+		},
+		{
+			path:    "apps.billing.requests.500",
+			name:    "apps.requests",
+			labels:  map[string]string{"app": "billing", "code": "500"},
+			counter: true,
+		},
+		{
+			path: "servers.web1.mem.used",
+			name: "servers.web1.mem.used",
+		},
+		{
+			path: "servers.web1.cpu",
+			name: "servers.web1.cpu",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			name, labels, counter := m.Map(tt.path)
+			assert.Equal(t, tt.name, name)
+			assert.Equal(t, tt.labels, labels)
+			assert.Equal(t, tt.counter, counter)
+		})
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   string
+		wantErr bool
+	}{
+		{name: "empty", rules: ""},
+		{name: "unknown option", rules: "jobs.* gauge", wantErr: true},
+		{name: "empty segment", rules: "jobs..runs", wantErr: true},
+		{name: "empty label", rules: "jobs.{}.runs", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRules(strings.NewReader(tt.rules))
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidRule)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package graphite
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cardinality"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/middleware/subnet"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/naming"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers"
+	"go.uber.org/zap"
+)
+
+const (
+	maxLineSize  = 64 * 1024
+	maxBatchSize = 1000
+)
+
+var ErrInvalidLine = errors.New("invalid graphite line")
+
+// Server принимает метрики в текстовом протоколе Graphite по TCP: <path> <value> [timestamp].
+// Время метрики не хранится, поэтому timestamp не используется.
+type Server struct {
+	zlog    *zap.SugaredLogger
+	storage routers.Storage
+	limiter *cardinality.Limiter
+	policy  *naming.Policy
+	mapper  *Mapper
+	trusted *subnet.Filter
+	tcp     net.Listener
+	address string
+}
+
+// New создает сервер. Ограничитель кардинальности и политика именования общие с HTTP API.
+// Как и в HTTP API, соединения принимаются только из доверенной подсети cfg.TrustedSubnet.
+func New(zlog *zap.SugaredLogger, cfg *config.Config, s routers.Storage, limiter *cardinality.Limiter,
+	policy *naming.Policy) (*Server, error) {
+	mapper, err := LoadRules(cfg.GraphiteRules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load graphite rules: %w", err)
+	}
+	trusted, err := subnet.NewFilter(cfg.TrustedSubnet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		zlog:    zlog,
+		storage: s,
+		limiter: limiter,
+		policy:  policy,
+		mapper:  mapper,
+		trusted: trusted,
+		address: cfg.GraphiteAddress,
+	}, nil
+}
+
+func (srv *Server) Listen() error {
+	tcp, err := net.Listen("tcp", srv.address)
+	if err != nil {
+		return fmt.Errorf("failed to listen tcp %s: %w", srv.address, err)
+	}
+	srv.tcp = tcp
+	return nil
+}
+
+// Addr адрес, на котором принимаются метрики. Доступен после Listen.
+func (srv *Server) Addr() string {
+	return srv.tcp.Addr().String()
+}
+
+// Serve принимает соединения до отмены контекста.
+func (srv *Server) Serve(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		_ = srv.tcp.Close()
+	})
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		conn, err := srv.tcp.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("failed to accept graphite connection: %w", err)
+		}
+		if !srv.trusted.Allows(conn.RemoteAddr().String()) {
+			srv.zlog.Warnf("rejected graphite connection from %s: outside trusted subnet %s",
+				conn.RemoteAddr(), srv.trusted)
+			_ = conn.Close()
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			srv.serveConn(ctx, conn)
+		}()
+	}
+}
+
+func (srv *Server) ListenAndServe(ctx context.Context) error {
+	if err := srv.Listen(); err != nil {
+		return err
+	}
+	return srv.Serve(ctx)
+}
+
+// serveConn читает строки соединения и записывает их пачками: пачка сохраняется,
+// когда набралось maxBatchSize метрик или клиент перестал присылать данные.
+// Строка длиннее maxLineSize не накапливается в памяти: соединение закрывается.
+func (srv *Server) serveConn(ctx context.Context, conn net.Conn) {
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	defer func() {
+		if stop() {
+			_ = conn.Close()
+		}
+	}()
+
+	source := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(source); err == nil {
+		source = host
+	}
+
+	r := bufio.NewReaderSize(conn, maxLineSize)
+	batch := make([]*models.Metrics, 0, maxBatchSize)
+	for {
+		data, err := r.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			srv.zlog.Warnf("closing graphite connection %s: line exceeds %d bytes", conn.RemoteAddr(), maxLineSize)
+			data = nil
+		}
+		if line := string(data); strings.TrimSpace(line) != "" {
+			m, perr := srv.parseLine(line)
+			if perr != nil {
+				srv.zlog.Debugf("skip graphite line: %v", perr)
+			} else {
+				batch = append(batch, m)
+			}
+		}
+
+		if len(batch) > 0 && (err != nil || len(batch) >= maxBatchSize || r.Buffered() == 0) {
+			srv.save(context.WithoutCancel(ctx), source, batch)
+			batch = batch[:0]
+		}
+
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+				srv.zlog.Debugf("graphite connection %s closed: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+	}
+}
+
+// save записывает пачку метрик, отбрасывая серии сверх лимитов кардинальности.
+func (srv *Server) save(ctx context.Context, source string, batch []*models.Metrics) {
+	metrics, err := srv.limiter.Filter(ctx, source, batch)
+	if err != nil {
+		srv.zlog.Warnf("dropped graphite metrics from %s: %v", source, err)
+	}
+	if len(metrics) == 0 {
+		return
+	}
+	if err := srv.storage.SaveMetrics(ctx, metrics); err != nil {
+		srv.zlog.Warnf("failed to save graphite metrics: %v", err)
+		return
+	}
+	srv.limiter.Commit(source, cardinality.Keys(metrics)...)
+}
+
+// parseLine разбирает строку и применяет к пути правила.
+func (srv *Server) parseLine(line string) (*models.Metrics, error) {
+	fields := strings.Fields(line)
+	if len(fields) > 3 || len(fields) < 2 {
+		return nil, fmt.Errorf("%w %q: expected <path> <value> [timestamp]", ErrInvalidLine, line)
+	}
+
+	v, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil, fmt.Errorf("%w %q: invalid value %q", ErrInvalidLine, line, fields[1])
+	}
+
+	name, labels, counter := srv.mapper.Map(fields[0])
+	id, err := srv.policy.AdmitSeries(name, labels)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidLine, line, err)
+	}
+
+	m := &models.Metrics{ID: id}
+	if counter {
+		d := int64(math.Round(v))
+		m.MType = "counter"
+		m.Delta = &d
+	} else {
+		m.MType = "gauge"
+		m.Value = &v
+	}
+	return m, nil
+}
@@ -0,0 +1,141 @@
+package graphite_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cardinality"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/graphite"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/logger"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/naming"
+	"github.com/VanGoghDev/practicum-metrics/internal/storage/memstorage"
+)
+
+func TestServer(t *testing.T) {
+	rules := filepath.Join(t.TempDir(), "rules")
+	require.NoError(t, os.WriteFile(rules, []byte("jobs.{job}.runs counter\n"), 0o600))
+
+	log, _ := logger.New("Info")
+	memstrg, _ := memstorage.New(log)
+	cfg := &config.Config{GraphiteAddress: "127.0.0.1:0", GraphiteRules: rules, MaxSeries: 2}
+	limiter := cardinality.New(cfg, memstrg)
+	policy, err := naming.New(cfg)
+	require.NoError(t, err)
+	srv, err := graphite.New(log.Sugar(), cfg, memstrg, limiter, policy)
+	require.NoError(t, err)
+	require.NoError(t, srv.Listen())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- srv.Serve(ctx)
+	}()
+
+	for range 2 {
+		conn, err := net.Dial("tcp", srv.Addr())
+		require.NoError(t, err)
+		_, err = conn.Write([]byte("jobs.backup.runs 1 1718000000\nservers.web1.load 0.75 -1\n\nbroken\n" +
+			"servers.web2.load 1\n"))
+		require.NoError(t, err)
+		require.NoError(t, conn.Close())
+	}
+
+	require.Eventually(t, func() bool {
+		c, err := memstrg.Counter(context.Background(), `jobs.runs{job="backup"}`)
+		return err == nil && c.Value == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	g, err := memstrg.Gauge(context.Background(), "servers.web1.load")
+	require.NoError(t, err)
+	assert.InDelta(t, 0.75, g.Value, 0)
+
+	// третья серия не помещается в MAX_SERIES.
+	_, err = memstrg.Gauge(context.Background(), "servers.web2.load")
+	require.Error(t, err)
+	require.Eventually(t, func() bool {
+		return limiter.Stats().RejectedSeriesLimit == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestServer_TrustedSubnet(t *testing.T) {
+	log, _ := logger.New("Info")
+	memstrg, _ := memstorage.New(log)
+	cfg := &config.Config{GraphiteAddress: "127.0.0.1:0", TrustedSubnet: "10.0.0.0/8"}
+	policy, err := naming.New(cfg)
+	require.NoError(t, err)
+	srv, err := graphite.New(log.Sugar(), cfg, memstrg, cardinality.New(cfg, memstrg), policy)
+	require.NoError(t, err)
+	require.NoError(t, srv.Listen())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- srv.Serve(ctx)
+	}()
+
+	conn, err := net.Dial("tcp", srv.Addr())
+	require.NoError(t, err)
+	_, _ = conn.Write([]byte("servers.web1.load 0.75\n"))
+	// сервер закрывает соединение не из доверенной подсети, не читая его.
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = conn.Read(make([]byte, 1))
+	require.Error(t, err)
+	require.NoError(t, conn.Close())
+
+	cancel()
+	require.NoError(t, <-done)
+
+	gs, err := memstrg.Gauges(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, gs)
+}
+
+func TestServer_LongLine(t *testing.T) {
+	log, _ := logger.New("Info")
+	memstrg, _ := memstorage.New(log)
+	cfg := &config.Config{GraphiteAddress: "127.0.0.1:0"}
+	policy, err := naming.New(cfg)
+	require.NoError(t, err)
+	srv, err := graphite.New(log.Sugar(), cfg, memstrg, cardinality.New(cfg, memstrg), policy)
+	require.NoError(t, err)
+	require.NoError(t, srv.Listen())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- srv.Serve(ctx)
+	}()
+
+	conn, err := net.Dial("tcp", srv.Addr())
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("servers.web1.load 0.75\n"))
+	require.NoError(t, err)
+	// строка без перевода строки длиннее буфера: сервер закрывает соединение, не дожидаясь ее конца.
+	_, _ = conn.Write([]byte(strings.Repeat("a", 128*1024)))
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = conn.Read(make([]byte, 1))
+	var netErr net.Error
+	require.False(t, errors.As(err, &netErr) && netErr.Timeout(), "connection is not closed: %v", err)
+	require.NoError(t, conn.Close())
+
+	require.Eventually(t, func() bool {
+		g, err := memstrg.Gauge(context.Background(), "servers.web1.load")
+		return err == nil && g.Value == 0.75
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,125 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/proto/metricspb"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/auth"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cardinality"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// realIPKey ключ метаданных с адресом исходящего интерфейса агента.
+const realIPKey = "x-real-ip"
+
+// methodRoles роли, необходимые для вызова методов, те же, что у соответствующих маршрутов HTTP API.
+var methodRoles = map[string]auth.Role{
+	metricspb.Metrics_UpdateMetrics_FullMethodName: auth.RoleWriter,
+	metricspb.Metrics_StreamMetrics_FullMethodName: auth.RoleWriter,
+	metricspb.Metrics_GetMetric_FullMethodName:     auth.RoleReader,
+	metricspb.Metrics_ListMetrics_FullMethodName:   auth.RoleReader,
+}
+
+// authorize проверяет токен из метаданных authorization: Bearer <токен>
+// и возвращает контекст с идентификатором клиента для лимитов кардинальности.
+func (srv *Server) authorize(ctx context.Context, method string) (context.Context, error) {
+	if !srv.auth.Enabled() {
+		return ctx, nil
+	}
+	role, ok := methodRoles[method]
+	if !ok {
+		role = auth.RoleAdmin
+	}
+
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("authorization"); len(v) > 0 {
+			token, _ = strings.CutPrefix(v[0], "Bearer ")
+		}
+	}
+
+	err := srv.auth.Authorize(ctx, token, role)
+	switch {
+	case err == nil:
+		return cardinality.WithSource(ctx, auth.Identity(token)), nil
+	case errors.Is(err, auth.ErrMissingToken), errors.Is(err, auth.ErrUnknownToken):
+		srv.zlog.Warnf("rejected call %s from %s: %v", method, source(ctx), err)
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, auth.ErrForbidden):
+		srv.zlog.Warnf("rejected call %s from %s: %v", method, source(ctx), err)
+		return nil, status.Errorf(codes.PermissionDenied, "%s role is required", role)
+	default:
+		srv.zlog.Errorf("failed to authorize call %s: %v", method, err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+}
+
+func (srv *Server) unaryAuth(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (any, error) {
+	ctx, err := srv.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (srv *Server) streamAuth(s any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	ctx, err := srv.authorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(s, &serverStream{ServerStream: ss, ctx: ctx})
+}
+
+// checkSubnet пропускает вызовы записи только из доверенной подсети. Адрес клиента берется
+// из метаданных x-real-ip, как заголовок X-Real-IP в HTTP API, а без них - из адреса соединения.
+func (srv *Server) checkSubnet(ctx context.Context, method string) error {
+	if methodRoles[method] != auth.RoleWriter {
+		return nil
+	}
+
+	addr := peerAddr(ctx)
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(realIPKey); len(v) > 0 && v[0] != "" {
+			addr = v[0]
+		}
+	}
+	if !srv.trusted.Allows(addr) {
+		srv.zlog.Warnf("rejected call %s from %q (remote %s): outside trusted subnet %s",
+			method, addr, peerAddr(ctx), srv.trusted)
+		return status.Error(codes.PermissionDenied, "client address is outside trusted subnet")
+	}
+	return nil
+}
+
+func (srv *Server) unarySubnet(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (any, error) {
+	if err := srv.checkSubnet(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (srv *Server) streamSubnet(s any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	if err := srv.checkSubnet(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(s, ss)
+}
+
+// serverStream подменяет контекст потока.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
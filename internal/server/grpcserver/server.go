@@ -0,0 +1,343 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/proto/metricspb"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/auth"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cardinality"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/middleware/subnet"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/naming"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/ratelimit"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers"
+	"github.com/VanGoghDev/practicum-metrics/internal/storage/serrors"
+	"github.com/VanGoghDev/practicum-metrics/internal/util/tlsutil"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// defaultMaxBatchSize количество метрик в батче по умолчанию, если лимит не задан (как в /updates).
+const defaultMaxBatchSize = 10000
+
+// Server gRPC сервис metrics.Metrics. Запись и чтение выполняются через то же хранилище,
+// что и у HTTP API, с теми же проверками метрик, политикой именования и лимитами кардинальности.
+type Server struct {
+	metricspb.UnimplementedMetricsServer
+
+	zlog     *zap.SugaredLogger
+	storage  routers.Storage
+	limiter  *cardinality.Limiter
+	rates    *ratelimit.Limiter
+	auth     *auth.Authenticator
+	policy   *naming.Policy
+	trusted  *subnet.Filter
+	grpc     *grpc.Server
+	lis      net.Listener
+	address  string
+	maxBatch int64
+}
+
+// New создает сервер. Ограничители кардинальности и частоты запросов и политика именования общие с HTTP API,
+// чтобы лимиты и счетчики отказов учитывали оба API.
+// Если authn задан, вызовы проверяются по API токенам с теми же ролями, что и в HTTP API.
+// Если заданы сертификат и ключ TLS, сервер, как и HTTP API, принимает только TLS соединения
+// с теми же минимальной версией и проверкой клиентских сертификатов (mTLS).
+// Запись, как и в HTTP API, принимается только из доверенной подсети cfg.TrustedSubnet,
+// а батч больше cfg.MaxBatchSize метрик отклоняется целиком.
+func New(zlog *zap.SugaredLogger, cfg *config.Config, s routers.Storage, limiter *cardinality.Limiter,
+	rates *ratelimit.Limiter, policy *naming.Policy, authn *auth.Authenticator) (*Server, error) {
+	trusted, err := subnet.NewFilter(cfg.TrustedSubnet)
+	if err != nil {
+		return nil, err
+	}
+	srv := &Server{
+		zlog:     zlog,
+		storage:  s,
+		limiter:  limiter,
+		rates:    rates,
+		auth:     authn,
+		policy:   policy,
+		trusted:  trusted,
+		address:  cfg.GRPCAddress,
+		maxBatch: cfg.MaxBatchSize,
+	}
+	if srv.maxBatch <= 0 {
+		srv.maxBatch = defaultMaxBatchSize
+	}
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(srv.unaryThrottle, srv.unarySubnet, srv.unaryAuth),
+		grpc.ChainStreamInterceptor(srv.streamThrottle, srv.streamSubnet, srv.streamAuth),
+	}
+	if cfg.TLSCert != "" || cfg.TLSKey != "" {
+		tlsCfg, err := tlsutil.ServerConfig(cfg.TLSCert, cfg.TLSKey, cfg.TLSMinVersion, cfg.TLSClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init tls: %w", err)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+	}
+	srv.grpc = grpc.NewServer(opts...)
+	metricspb.RegisterMetricsServer(srv.grpc, srv)
+	return srv, nil
+}
+
+// Listen открывает TCP сокет.
+func (srv *Server) Listen() error {
+	lis, err := net.Listen("tcp", srv.address)
+	if err != nil {
+		return fmt.Errorf("failed to listen tcp %s: %w", srv.address, err)
+	}
+	srv.lis = lis
+	return nil
+}
+
+// Addr адрес, на котором принимаются запросы. Доступен после Listen.
+func (srv *Server) Addr() string {
+	return srv.lis.Addr().String()
+}
+
+// Serve обслуживает запросы до отмены контекста, после чего дожидается завершения начатых вызовов.
+func (srv *Server) Serve(ctx context.Context) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			srv.grpc.GracefulStop()
+		case <-done:
+		}
+	}()
+
+	if err := srv.grpc.Serve(srv.lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return fmt.Errorf("failed to serve grpc: %w", err)
+	}
+	return nil
+}
+
+func (srv *Server) ListenAndServe(ctx context.Context) error {
+	if err := srv.Listen(); err != nil {
+		return err
+	}
+	return srv.Serve(ctx)
+}
+
+// UpdateMetrics записывает батч метрик.
+func (srv *Server) UpdateMetrics(ctx context.Context,
+	req *metricspb.UpdateMetricsRequest) (*metricspb.UpdateMetricsResponse, error) {
+	n, err := srv.save(ctx, req.GetMetrics())
+	if err != nil {
+		return nil, err
+	}
+	return &metricspb.UpdateMetricsResponse{Accepted: n}, nil
+}
+
+// StreamMetrics записывает батчи по мере поступления. Ошибка в батче завершает поток,
+// батчи, принятые до нее, остаются записанными.
+func (srv *Server) StreamMetrics(stream metricspb.Metrics_StreamMetricsServer) error {
+	var total int64
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&metricspb.UpdateMetricsResponse{Accepted: total})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to receive metrics: %w", err)
+		}
+
+		n, err := srv.save(stream.Context(), req.GetMetrics())
+		if err != nil {
+			return err
+		}
+		total += n
+	}
+}
+
+// GetMetric возвращает текущее значение метрики.
+func (srv *Server) GetMetric(ctx context.Context, req *metricspb.GetMetricRequest) (*metricspb.Metric, error) {
+	if apiErr := handlers.ValidateType(req.GetType(), req.GetId()); apiErr != nil {
+		return nil, toStatus(apiErr)
+	}
+	id, apiErr := handlers.NormalizeName(srv.policy, req.GetId())
+	if apiErr != nil {
+		return nil, toStatus(apiErr)
+	}
+
+	m := &metricspb.Metric{Id: id, Type: req.GetType()}
+	var err error
+	switch req.GetType() {
+	case handlers.Counter:
+		var counter models.Counter
+		if counter, err = srv.storage.Counter(ctx, id); err == nil {
+			m.Delta = &counter.Value
+		}
+	case handlers.Gauge:
+		var gauge models.Gauge
+		if gauge, err = srv.storage.Gauge(ctx, id); err == nil {
+			m.Value = &gauge.Value
+		}
+	}
+	if errors.Is(err, serrors.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "metric %q not found", id)
+	}
+	if err != nil {
+		srv.zlog.Errorf("failed to fetch metric %s: %v", id, err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return m, nil
+}
+
+// ListMetrics возвращает страницу списка метрик, параметры и курсор те же, что у GET /api/v1/metrics.
+func (srv *Server) ListMetrics(ctx context.Context,
+	req *metricspb.ListMetricsRequest) (*metricspb.ListMetricsResponse, error) {
+	q := &models.ListQuery{
+		MType:  req.GetType(),
+		Prefix: req.GetPrefix(),
+		Match:  req.GetMatch(),
+		Limit:  handlers.DefaultListLimit,
+	}
+	if q.MType != "" {
+		if apiErr := handlers.ValidateType(q.MType, ""); apiErr != nil {
+			return nil, toStatus(apiErr)
+		}
+	}
+	sort, apiErr := handlers.ValidateSort(req.GetSort())
+	if apiErr != nil {
+		return nil, toStatus(apiErr)
+	}
+	q.Sort = sort
+
+	if limit := req.GetLimit(); limit != 0 {
+		if limit < 1 || limit > handlers.MaxListLimit {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"invalid limit %d, expected number from 1 to %d", limit, handlers.MaxListLimit)
+		}
+		q.Limit = int(limit)
+	}
+	if cursor := req.GetCursor(); cursor != "" {
+		after, err := handlers.DecodeCursor(cursor)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid cursor %q", cursor)
+		}
+		q.After = after
+	}
+
+	// запрашиваем на одну метрику больше, чтобы понять, есть ли следующая страница.
+	limit := q.Limit
+	q.Limit++
+	metrics, err := srv.storage.ListMetrics(ctx, q)
+	if err != nil {
+		srv.zlog.Warnf("failed to list metrics: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	resp := &metricspb.ListMetricsResponse{}
+	if len(metrics) > limit {
+		metrics = metrics[:limit]
+		last := metrics[limit-1]
+		resp.NextCursor = handlers.EncodeCursor(models.MetricKey{ID: last.ID, MType: last.MType})
+	}
+	resp.Metrics = make([]*metricspb.Metric, 0, len(metrics))
+	for _, m := range metrics {
+		// гистограммы в схеме gRPC не описаны, они доступны через HTTP API.
+		if m.MType == handlers.Histogram {
+			continue
+		}
+		resp.Metrics = append(resp.Metrics, &metricspb.Metric{Id: m.ID, Type: m.MType, Delta: m.Delta, Value: m.Value})
+	}
+	return resp, nil
+}
+
+// save проверяет батч и записывает его целиком.
+func (srv *Server) save(ctx context.Context, batch []*metricspb.Metric) (int64, error) {
+	if int64(len(batch)) > srv.maxBatch {
+		srv.zlog.Warnf("rejected batch of %d metrics: limit is %d", len(batch), srv.maxBatch)
+		return 0, toStatus(handlers.NewError(http.StatusRequestEntityTooLarge, handlers.CodeTooLarge,
+			fmt.Sprintf("batch of %d metrics exceeds %d metrics", len(batch), srv.maxBatch), ""))
+	}
+
+	metrics := make([]*models.Metrics, 0, len(batch))
+	for _, pm := range batch {
+		m := &models.Metrics{ID: pm.GetId(), MType: pm.GetType(), Delta: pm.Delta, Value: pm.Value}
+		if apiErr := handlers.ValidateMetric(m); apiErr != nil {
+			return 0, toStatus(apiErr)
+		}
+		id, apiErr := handlers.AdmitName(srv.policy, m.ID)
+		if apiErr != nil {
+			return 0, toStatus(apiErr)
+		}
+		m.ID = id
+		metrics = append(metrics, m)
+	}
+
+	src := source(ctx)
+	if err := srv.rates.AllowMetrics(src, len(metrics)); err != nil {
+		srv.zlog.Warnf("rejected write of %d metrics: %v", len(metrics), err)
+		return 0, status.Error(codes.ResourceExhausted, err.Error())
+	}
+
+	keys := cardinality.Keys(metrics)
+	err := srv.limiter.Check(ctx, src, keys...)
+	switch {
+	case err == nil:
+	case errors.Is(err, cardinality.ErrSeriesLimit), errors.Is(err, cardinality.ErrSourceLimit):
+		srv.zlog.Warnf("rejected write from %s: %v", src, err)
+		return 0, status.Error(codes.ResourceExhausted, err.Error())
+	default:
+		srv.zlog.Warnf("failed to check series limits: %v", err)
+		return 0, status.Error(codes.Internal, "internal error")
+	}
+
+	if err := srv.storage.SaveMetrics(ctx, metrics); err != nil {
+		srv.zlog.Warnf("failed to save metrics: %v", err)
+		return 0, status.Error(codes.Internal, "internal error")
+	}
+	srv.limiter.Commit(src, keys...)
+	return int64(len(metrics)), nil
+}
+
+// source возвращает идентификатор источника для лимитов кардинальности и лимита метрик:
+// проверенный идентификатор клиента по API токену, либо IP адрес соединения.
+func source(ctx context.Context) string {
+	if src, ok := cardinality.SourceFromContext(ctx); ok {
+		return src
+	}
+	return peerAddr(ctx)
+}
+
+// peerAddr IP адрес соединения клиента.
+func peerAddr(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// toStatus переводит ошибку HTTP API в статус gRPC с тем же сообщением.
+func toStatus(e *handlers.Error) error {
+	code := codes.Internal
+	switch e.Status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		code = codes.InvalidArgument
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	}
+	return status.Error(code, e.Message)
+}
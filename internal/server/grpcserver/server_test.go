@@ -0,0 +1,383 @@
+package grpcserver_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/agent/services/sender"
+	"github.com/VanGoghDev/practicum-metrics/internal/proto/metricspb"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/auth"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cardinality"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/grpcserver"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/logger"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/naming"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/ratelimit"
+	"github.com/VanGoghDev/practicum-metrics/internal/storage/memstorage"
+	"github.com/VanGoghDev/practicum-metrics/internal/util/tlsutil"
+)
+
+func gauge(id string, v float64) *metricspb.Metric {
+	return &metricspb.Metric{Id: id, Type: "gauge", Value: &v}
+}
+
+func counter(id string, d int64) *metricspb.Metric {
+	return &metricspb.Metric{Id: id, Type: "counter", Delta: &d}
+}
+
+func newClient(t *testing.T, cfg *config.Config, opts ...grpc.DialOption) metricspb.MetricsClient {
+	t.Helper()
+	log, _ := logger.New("Info")
+	memstrg, _ := memstorage.New(log)
+
+	authn, err := auth.FromConfig(log.Sugar(), cfg, memstrg)
+	require.NoError(t, err)
+	policy, err := naming.New(cfg)
+	require.NoError(t, err)
+	cfg.GRPCAddress = "127.0.0.1:0"
+	srv, err := grpcserver.New(log.Sugar(), cfg, memstrg, cardinality.New(cfg, memstrg),
+		ratelimit.New(cfg), policy, authn)
+	require.NoError(t, err)
+	require.NoError(t, srv.Listen())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Serve(ctx)
+	}()
+
+	// переданные в opts параметры соединения заменяют соединение без TLS.
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+	conn, err := grpc.NewClient(srv.Addr(), opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+		cancel()
+		assert.NoError(t, <-done)
+	})
+	return metricspb.NewMetricsClient(conn)
+}
+
+func TestUpdateMetrics(t *testing.T) {
+	client := newClient(t, &config.Config{MaxSeries: 3, MaxNameLength: 16, MaxBatchSize: 3})
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		metrics  []*metricspb.Metric
+		code     codes.Code
+		accepted int64
+	}{
+		{
+			name:     "valid batch",
+			metrics:  []*metricspb.Metric{gauge("Alloc", 1.5), counter("PollCount", 2), counter("PollCount", 3)},
+			accepted: 3,
+		},
+		{
+			name:    "invalid type",
+			metrics: []*metricspb.Metric{{Id: "Alloc", Type: "histogram"}},
+			code:    codes.InvalidArgument,
+		},
+		{
+			name:    "missing value",
+			metrics: []*metricspb.Metric{{Id: "Alloc", Type: "gauge"}},
+			code:    codes.InvalidArgument,
+		},
+		{
+			name:    "name too long",
+			metrics: []*metricspb.Metric{gauge("VeryLongMetricName", 1)},
+			code:    codes.InvalidArgument,
+		},
+		{
+			name:    "batch too large",
+			metrics: []*metricspb.Metric{gauge("Alloc", 1), gauge("Alloc", 2), gauge("Alloc", 3), gauge("Alloc", 4)},
+			code:    codes.ResourceExhausted,
+		},
+		{
+			name:    "series limit",
+			metrics: []*metricspb.Metric{gauge("HeapAlloc", 1), gauge("HeapSys", 2)},
+			code:    codes.ResourceExhausted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.UpdateMetrics(ctx, &metricspb.UpdateMetricsRequest{Metrics: tt.metrics})
+			require.Equal(t, tt.code, status.Code(err), err)
+			if tt.code == codes.OK {
+				assert.Equal(t, tt.accepted, resp.GetAccepted())
+			}
+		})
+	}
+
+	m, err := client.GetMetric(ctx, &metricspb.GetMetricRequest{Id: "PollCount", Type: "counter"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), m.GetDelta())
+}
+
+func TestStreamMetrics(t *testing.T) {
+	client := newClient(t, &config.Config{})
+	ctx := context.Background()
+
+	stream, err := client.StreamMetrics(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&metricspb.UpdateMetricsRequest{
+		Metrics: []*metricspb.Metric{gauge("Alloc", 1), counter("PollCount", 1)},
+	}))
+	require.NoError(t, stream.Send(&metricspb.UpdateMetricsRequest{
+		Metrics: []*metricspb.Metric{gauge("Alloc", 2), counter("PollCount", 1)},
+	}))
+	resp, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), resp.GetAccepted())
+
+	tests := []struct {
+		want *metricspb.Metric
+		req  *metricspb.GetMetricRequest
+		name string
+		code codes.Code
+	}{
+		{
+			name: "gauge",
+			req:  &metricspb.GetMetricRequest{Id: "Alloc", Type: "gauge"},
+			want: gauge("Alloc", 2),
+		},
+		{
+			name: "counter",
+			req:  &metricspb.GetMetricRequest{Id: "PollCount", Type: "counter"},
+			want: counter("PollCount", 2),
+		},
+		{
+			name: "not found",
+			req:  &metricspb.GetMetricRequest{Id: "Unknown", Type: "gauge"},
+			code: codes.NotFound,
+		},
+		{
+			name: "invalid type",
+			req:  &metricspb.GetMetricRequest{Id: "Alloc", Type: "histogram"},
+			code: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := client.GetMetric(ctx, tt.req)
+			require.Equal(t, tt.code, status.Code(err), err)
+			if tt.code == codes.OK {
+				assert.True(t, proto.Equal(tt.want, got), got)
+			}
+		})
+	}
+}
+
+func TestListMetrics(t *testing.T) {
+	client := newClient(t, &config.Config{})
+	ctx := context.Background()
+
+	_, err := client.UpdateMetrics(ctx, &metricspb.UpdateMetricsRequest{
+		Metrics: []*metricspb.Metric{gauge("Alloc", 1), gauge("HeapAlloc", 2), counter("PollCount", 3)},
+	})
+	require.NoError(t, err)
+
+	var ids []string
+	req := &metricspb.ListMetricsRequest{Limit: 2}
+	for {
+		resp, err := client.ListMetrics(ctx, req)
+		require.NoError(t, err)
+		for _, m := range resp.GetMetrics() {
+			ids = append(ids, m.GetId())
+		}
+		if resp.GetNextCursor() == "" {
+			break
+		}
+		req.Cursor = resp.GetNextCursor()
+	}
+	assert.Equal(t, []string{"Alloc", "HeapAlloc", "PollCount"}, ids)
+
+	_, err = client.ListMetrics(ctx, &metricspb.ListMetricsRequest{Sort: "value"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestAuth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	require.NoError(t, os.WriteFile(path, []byte("writer-token writer\nreader-token reader\n"), 0o600))
+	ctx := context.Background()
+	req := &metricspb.UpdateMetricsRequest{Metrics: []*metricspb.Metric{gauge("Alloc", 1)}}
+
+	tests := []struct {
+		name   string
+		token  string
+		update codes.Code
+		get    codes.Code
+	}{
+		{name: "without token", update: codes.Unauthenticated, get: codes.Unauthenticated},
+		{name: "unknown token", token: "other", update: codes.Unauthenticated, get: codes.Unauthenticated},
+		{name: "writer", token: "writer-token", update: codes.OK, get: codes.PermissionDenied},
+		{name: "reader", token: "reader-token", update: codes.PermissionDenied, get: codes.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []grpc.DialOption
+			if tt.token != "" {
+				opts = append(opts, grpc.WithPerRPCCredentials(sender.TokenCredentials(tt.token)))
+			}
+			client := newClient(t, &config.Config{AuthTokensFile: path}, opts...)
+
+			_, err := client.UpdateMetrics(ctx, req)
+			assert.Equal(t, tt.update, status.Code(err), err)
+			_, err = client.GetMetric(ctx, &metricspb.GetMetricRequest{Id: "Alloc", Type: "gauge"})
+			assert.Equal(t, tt.get, status.Code(err), err)
+		})
+	}
+}
+
+// selfSigned выпускает самоподписанный сертификат сервера для 127.0.0.1 и возвращает пути к сертификату и ключу.
+func selfSigned(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "metrics"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestTLS(t *testing.T) {
+	certFile, keyFile := selfSigned(t)
+	trusted, err := tlsutil.ClientConfig(certFile, "", "", false)
+	require.NoError(t, err)
+	untrusted, err := tlsutil.ClientConfig("", "", "", false)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		creds credentials.TransportCredentials
+		code  codes.Code
+	}{
+		{name: "trusted certificate", creds: credentials.NewTLS(trusted), code: codes.OK},
+		{name: "untrusted certificate", creds: credentials.NewTLS(untrusted), code: codes.Unavailable},
+		{name: "plaintext", creds: insecure.NewCredentials(), code: codes.Unavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newClient(t, &config.Config{TLSCert: certFile, TLSKey: keyFile},
+				grpc.WithTransportCredentials(tt.creds))
+			_, err := client.UpdateMetrics(context.Background(),
+				&metricspb.UpdateMetricsRequest{Metrics: []*metricspb.Metric{gauge("Alloc", 1)}})
+			assert.Equal(t, tt.code, status.Code(err), err)
+		})
+	}
+}
+
+func TestTrustedSubnet(t *testing.T) {
+	ctx := context.Background()
+	update := &metricspb.UpdateMetricsRequest{Metrics: []*metricspb.Metric{gauge("Alloc", 1)}}
+
+	tests := []struct {
+		name    string
+		trusted string
+		realIP  string
+		code    codes.Code
+	}{
+		{name: "peer inside trusted subnet", trusted: "127.0.0.0/8", code: codes.OK},
+		{name: "peer outside trusted subnet", trusted: "10.0.0.0/8", code: codes.PermissionDenied},
+		{name: "real ip inside trusted subnet", trusted: "10.0.0.0/8", realIP: "10.1.2.3", code: codes.OK},
+		{name: "real ip outside trusted subnet", trusted: "127.0.0.0/8", realIP: "192.168.1.1",
+			code: codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newClient(t, &config.Config{TrustedSubnet: tt.trusted})
+			callCtx := ctx
+			if tt.realIP != "" {
+				callCtx = metadata.AppendToOutgoingContext(ctx, "x-real-ip", tt.realIP)
+			}
+
+			_, err := client.UpdateMetrics(callCtx, update)
+			assert.Equal(t, tt.code, status.Code(err), err)
+
+			stream, err := client.StreamMetrics(callCtx)
+			require.NoError(t, err)
+			_ = stream.Send(update)
+			_, err = stream.CloseAndRecv()
+			assert.Equal(t, tt.code, status.Code(err), err)
+
+			// чтение не ограничивается подсетью.
+			_, err = client.ListMetrics(ctx, &metricspb.ListMetricsRequest{})
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	ctx := context.Background()
+	update := &metricspb.UpdateMetricsRequest{Metrics: []*metricspb.Metric{gauge("Alloc", 1), gauge("HeapAlloc", 2)}}
+
+	tests := []struct {
+		cfg   *config.Config
+		name  string
+		codes []codes.Code
+	}{
+		{
+			name:  "requests per second",
+			cfg:   &config.Config{RateLimitRequests: 1, RateLimitRequestsBurst: 2},
+			codes: []codes.Code{codes.OK, codes.OK, codes.ResourceExhausted},
+		},
+		{
+			name:  "metrics per second count batch size",
+			cfg:   &config.Config{RateLimitMetrics: 3},
+			codes: []codes.Code{codes.OK, codes.ResourceExhausted},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newClient(t, tt.cfg)
+			for i, code := range tt.codes {
+				_, err := client.UpdateMetrics(ctx, update)
+				require.Equal(t, code, status.Code(err), "call %d: %v", i, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package grpcserver
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// throttle учитывает вызов в лимите запросов клиента, как middleware throttle в HTTP API.
+// Вызов проверяется до проверки API токена, поэтому клиент определяется по IP адресу соединения.
+// Поток учитывается как один запрос, а его батчи - в лимите метрик (см. save).
+func (srv *Server) throttle(ctx context.Context, method string) error {
+	if err := srv.rates.AllowRequest(peerAddr(ctx)); err != nil {
+		srv.zlog.Warnf("rejected call %s: %v", method, err)
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return nil
+}
+
+func (srv *Server) unaryThrottle(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (any, error) {
+	if err := srv.throttle(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (srv *Server) streamThrottle(s any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	if err := srv.throttle(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(s, ss)
+}
@@ -0,0 +1,157 @@
+package dashboard
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/history"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers"
+	"github.com/VanGoghDev/practicum-metrics/internal/util/converter"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultRefresh = 10
+
+	sparklineWidth  = 120
+	sparklineHeight = 24
+)
+
+//go:embed templates/*.html
+var templates embed.FS
+
+//go:embed static
+var static embed.FS
+
+var page = template.Must(template.ParseFS(templates, "templates/index.html"))
+
+// row строка таблицы метрик.
+type row struct {
+	Name      string
+	Value     string
+	Sort      float64 // значение для сортировки на клиенте
+	Sparkline string  // точки polyline для SVG графика, пусто - истории нет
+}
+
+// table таблица метрик одного типа.
+type table struct {
+	Title string
+	ID    string
+	Rows  []row
+}
+
+type view struct {
+	Tables  []table
+	Refresh int
+}
+
+// Handler отдает HTML страницу со списком метрик и графиками их истории: GET /.
+// Период автообновления страницы в секундах задается параметром refresh, 0 - отключить.
+func Handler(zlog *zap.SugaredLogger, s routers.Storage, h *history.Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+		refresh := defaultRefresh
+		if v := r.URL.Query().Get("refresh"); v != "" {
+			i, err := strconv.Atoi(v)
+			if err != nil || i < 0 {
+				handlers.WriteError(w, handlers.NewError(http.StatusBadRequest, handlers.CodeInvalidRequest,
+					fmt.Sprintf("invalid refresh %q", v), ""))
+				return
+			}
+			refresh = i
+		}
+
+		metrics, err := s.ListMetrics(r.Context(), &models.ListQuery{Sort: models.SortByName})
+		if err != nil {
+			zlog.Warnf("failed to list metrics: %v", err)
+			handlers.WriteError(w, handlers.InternalError(""))
+			return
+		}
+
+		gauges := table{Title: "Gauges", ID: "gauges"}
+		counters := table{Title: "Counters", ID: "counters"}
+		histograms := table{Title: "Histograms", ID: "histograms"}
+		for _, m := range metrics {
+			rw := row{Name: m.ID}
+			switch {
+			case m.Value != nil:
+				rw.Sort = *m.Value
+				rw.Value, err = converter.Str(*m.Value)
+			case m.Delta != nil:
+				rw.Sort = float64(*m.Delta)
+				rw.Value, err = converter.Str(*m.Delta)
+			case m.Histogram != nil:
+				// для гистограммы показываются количество и сумма значений, сортировка по количеству.
+				rw.Sort = float64(m.Histogram.Count)
+				var sum string
+				sum, err = converter.Str(m.Histogram.Sum)
+				rw.Value = fmt.Sprintf("count %d, sum %s", m.Histogram.Count, sum)
+			}
+			if err != nil {
+				zlog.Warnf("failed to convert metric value to string: %v", err)
+				handlers.WriteError(w, handlers.InternalError(m.ID))
+				return
+			}
+			rw.Sparkline = sparkline(h.Points(models.MetricKey{ID: m.ID, MType: m.MType}))
+
+			switch m.MType {
+			case handlers.Counter:
+				counters.Rows = append(counters.Rows, rw)
+			case handlers.Histogram:
+				histograms.Rows = append(histograms.Rows, rw)
+			default:
+				gauges.Rows = append(gauges.Rows, rw)
+			}
+		}
+
+		data := view{Tables: []table{gauges, counters, histograms}, Refresh: refresh}
+
+		if err := page.Execute(w, data); err != nil {
+			zlog.Warnf("failed to render dashboard: %v", err)
+			return
+		}
+	}
+}
+
+// StaticHandler отдает встроенные в бинарник статические файлы дашборда.
+func StaticHandler() http.Handler {
+	sub, _ := fs.Sub(static, "static")
+	return http.FileServer(http.FS(sub))
+}
+
+// sparkline переводит историю значений в координаты точек SVG polyline.
+// Для рисования линии нужны хотя бы две точки.
+func sparkline(points []history.Point) string {
+	if len(points) < 2 {
+		return ""
+	}
+
+	lo, hi := points[0].Value, points[0].Value
+	for _, p := range points[1:] {
+		lo = min(lo, p.Value)
+		hi = max(hi, p.Value)
+	}
+
+	var b strings.Builder
+	step := float64(sparklineWidth) / float64(len(points)-1)
+	for i, p := range points {
+		y := float64(sparklineHeight) / 2
+		if hi > lo {
+			// ось Y в SVG направлена вниз.
+			y = float64(sparklineHeight) - (p.Value-lo)/(hi-lo)*float64(sparklineHeight)
+		}
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%.1f,%.1f", float64(i)*step, y)
+	}
+	return b.String()
+}
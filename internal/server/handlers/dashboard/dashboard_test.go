@@ -0,0 +1,101 @@
+package dashboard_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/history"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/logger"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers/chirouter"
+	"github.com/VanGoghDev/practicum-metrics/internal/storage/memstorage"
+)
+
+func TestDashboard(t *testing.T) {
+	log, _ := logger.New("Info")
+	memstrg, _ := memstorage.New(log)
+	memstrg.GaugesM = map[string]float64{"Alloc": 1, "<b>x</b>": 2}
+	memstrg.CountersM = map[string]int64{"PollCount": 3}
+	memstrg.HistogramsM = map[string]models.Histogram{
+		"latency": {Bounds: []float64{1}, Counts: []uint64{2, 4}, Count: 6, Sum: 7.5},
+	}
+
+	cfg := &config.Config{}
+	h := history.New(log.Sugar(), cfg, memstrg)
+	require.NoError(t, h.Sample(context.Background()))
+	memstrg.GaugesM["Alloc"] = 5
+	require.NoError(t, h.Sample(context.Background()))
+
+	r, err := chirouter.BuildRouter(memstrg, log, cfg, chirouter.WithHistory(h))
+	require.NoError(t, err)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		url         string
+		status      int
+		contentType string
+		contains    []string
+		notContains []string
+	}{
+		{
+			name:        "page",
+			url:         "/",
+			status:      http.StatusOK,
+			contentType: "text/html",
+			contains: []string{
+				`id="gauges"`, `id="counters"`, "PollCount", "&lt;b&gt;x&lt;/b&gt;",
+				`<polyline points="0.0,24.0 120.0,0.0"/>`, `data-refresh="10"`,
+				`id="histograms"`, "count 6, sum 7.5",
+			},
+			notContains: []string{"<b>x</b>"},
+		},
+		{
+			name:     "refresh disabled",
+			url:      "/?refresh=0",
+			status:   http.StatusOK,
+			contains: []string{`data-refresh="0"`},
+		},
+		{
+			name:   "invalid refresh",
+			url:    "/?refresh=soon",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:     "static script",
+			url:      "/static/dashboard.js",
+			status:   http.StatusOK,
+			contains: []string{"location.reload"},
+		},
+		{
+			name:   "missing static",
+			url:    "/static/missing.js",
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := resty.New().R().Get(srv.URL + tt.url)
+			require.NoError(t, err)
+			require.Equal(t, tt.status, resp.StatusCode())
+			if tt.contentType != "" {
+				assert.Contains(t, resp.Header().Get("Content-Type"), tt.contentType)
+			}
+			for _, s := range tt.contains {
+				assert.Contains(t, resp.String(), s)
+			}
+			for _, s := range tt.notContains {
+				assert.NotContains(t, resp.String(), s)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Сортировка таблиц по клику на заголовок и автообновление страницы.
+// Выбранная сортировка хранится в location.hash и переживает перезагрузку.
+(function () {
+	"use strict";
+
+	function loadState() {
+		try {
+			return JSON.parse(decodeURIComponent(location.hash.slice(1))) || {};
+		} catch (e) {
+			return {};
+		}
+	}
+
+	function saveState(state) {
+		history.replaceState(null, "", "#" + encodeURIComponent(JSON.stringify(state)));
+	}
+
+	function sortTable(table, index, dir) {
+		var numeric = table.tHead.rows[0].cells[index].dataset.key === "value";
+		var body = table.tBodies[0];
+		var rows = Array.prototype.slice.call(body.rows);
+		rows.sort(function (a, b) {
+			var x = a.cells[index].dataset.sort;
+			var y = b.cells[index].dataset.sort;
+			var cmp = numeric ? parseFloat(x) - parseFloat(y) : (x < y ? -1 : x > y ? 1 : 0);
+			return dir === "desc" ? -cmp : cmp;
+		});
+		rows.forEach(function (row) {
+			body.appendChild(row);
+		});
+
+		Array.prototype.forEach.call(table.tHead.rows[0].cells, function (th, i) {
+			if (i === index) {
+				th.dataset.dir = dir;
+			} else {
+				delete th.dataset.dir;
+			}
+		});
+	}
+
+	var state = loadState();
+
+	document.querySelectorAll("table.sortable").forEach(function (table) {
+		var saved = state[table.id];
+		if (saved) {
+			sortTable(table, saved.index, saved.dir);
+		}
+
+		Array.prototype.forEach.call(table.tHead.rows[0].cells, function (th, index) {
+			if (!th.dataset.key) {
+				return;
+			}
+			th.addEventListener("click", function () {
+				var dir = th.dataset.dir === "asc" ? "desc" : "asc";
+				sortTable(table, index, dir);
+				state[table.id] = { index: index, dir: dir };
+				saveState(state);
+			});
+		});
+	});
+
+	var refresh = parseInt(document.body.dataset.refresh, 10);
+	if (refresh > 0) {
+		setTimeout(function () {
+			location.reload();
+		}, refresh * 1000);
+	}
+})();
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Коды ошибок, по которым клиент может определить причину отказа.
+const (
+	CodeInvalidRequest = "invalid_request"
+	CodeInvalidType    = "invalid_type"
+	CodeInvalidName    = "invalid_name"
+	CodeInvalidValue   = "invalid_value"
+	CodeNotFound       = "not_found"
+	CodeUnauthorized   = "unauthorized"
+	CodeForbidden      = "forbidden"
+	CodeLimitExceeded  = "limit_exceeded"
+	CodeRateLimited    = "rate_limited"
+	CodeTooLarge       = "payload_too_large"
+	CodeInternal       = "internal_error"
+)
+
+const internalErrMsg = "Internal error"
+
+// Error единая модель ошибки API.
+type Error struct {
+	Code    string `json:"code"`         // машиночитаемый код ошибки
+	Message string `json:"message"`      // описание ошибки
+	ID      string `json:"id,omitempty"` // имя метрики, на которой произошла ошибка
+	Status  int    `json:"-"`            // HTTP статус ответа
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// NewError создает ошибку API с указанным статусом и кодом.
+func NewError(status int, code, message, id string) *Error {
+	return &Error{
+		Status:  status,
+		Code:    code,
+		Message: message,
+		ID:      id,
+	}
+}
+
+// InternalError ошибка для непредвиденных сбоев сервера. Детали сбоя клиенту не передаются.
+func InternalError(id string) *Error {
+	return NewError(http.StatusInternalServerError, CodeInternal, internalErrMsg, id)
+}
+
+// WriteError отвечает клиенту ошибкой.
+// Если обработчик отвечает в JSON (Content-Type уже выставлен в application/json),
+// ошибка сериализуется в JSON, иначе отправляется текстом в формате "code: message".
+func WriteError(w http.ResponseWriter, e *Error) {
+	status := e.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		http.Error(w, e.Error(), status)
+		return
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(e)
+}
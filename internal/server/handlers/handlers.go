@@ -1,6 +1,141 @@
 package handlers
 
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cardinality"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/naming"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/ratelimit"
+	"go.uber.org/zap"
+)
+
 const (
-	Gauge   string = "gauge"
-	Counter string = "counter"
+	Gauge     string = "gauge"
+	Counter   string = "counter"
+	Histogram string = "histogram"
 )
+
+// ValidateType проверяет, что тип метрики поддерживается сервером.
+func ValidateType(mType, id string) *Error {
+	if mType != Gauge && mType != Counter {
+		return NewError(http.StatusBadRequest, CodeInvalidType, fmt.Sprintf("invalid metric type %q", mType), id)
+	}
+	return nil
+}
+
+// ValidateReadType проверяет тип метрики, которую читают, ищут или удаляют.
+// Кроме gauge и counter допускается histogram: гистограммы записываются только приемником OTLP.
+func ValidateReadType(mType, id string) *Error {
+	if mType == Histogram {
+		return nil
+	}
+	return ValidateType(mType, id)
+}
+
+// ValidateMetric проверяет метрику, пришедшую на запись:
+// тип, наличие имени и значения, соответствующего типу.
+func ValidateMetric(m *models.Metrics) *Error {
+	if m == nil {
+		return NewError(http.StatusBadRequest, CodeInvalidRequest, "metric is null", "")
+	}
+	if err := ValidateType(m.MType, m.ID); err != nil {
+		return err
+	}
+	if m.ID == "" {
+		return NewError(http.StatusNotFound, CodeInvalidName, "metric name is empty", "")
+	}
+	if m.MType == Gauge && m.Value == nil {
+		return NewError(http.StatusBadRequest, CodeInvalidValue,
+			fmt.Sprintf("metric %q: gauge value is missing", m.ID), m.ID)
+	}
+	if m.MType == Counter && m.Delta == nil {
+		return NewError(http.StatusBadRequest, CodeInvalidValue,
+			fmt.Sprintf("metric %q: counter delta is missing", m.ID), m.ID)
+	}
+	return nil
+}
+
+// NormalizeName приводит имя читаемой или удаляемой метрики к виду, заданному политикой именования.
+// Имя может быть именем серии с метками (см. naming.Policy.Lookup).
+func NormalizeName(policy *naming.Policy, name string) (string, *Error) {
+	normalized, err := policy.Lookup(name)
+	return normalized, nameError(err, name)
+}
+
+// AdmitName приводит имя записываемой метрики к виду, заданному политикой именования.
+// Отклоненные имена учитываются в счетчиках политики (см. GET /limits).
+func AdmitName(policy *naming.Policy, name string) (string, *Error) {
+	normalized, err := policy.Admit(name)
+	return normalized, nameError(err, name)
+}
+
+// AdmitSeries строит имя записываемой серии из имени метрики и меток (см. naming.Policy.AdmitSeries).
+func AdmitSeries(policy *naming.Policy, name string, labels map[string]string) (string, *Error) {
+	id, err := policy.AdmitSeries(name, labels)
+	return id, nameError(err, name)
+}
+
+// nameError переводит ошибку политики именования в ошибку API.
+// Слишком длинное имя отклоняется с 422, остальные ошибки - с 400.
+func nameError(err error, name string) *Error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, naming.ErrNameTooLong):
+		return NewError(http.StatusUnprocessableEntity, CodeInvalidName, err.Error(), name)
+	default:
+		return NewError(http.StatusBadRequest, CodeInvalidName, err.Error(), name)
+	}
+}
+
+// Admit проверяет лимит метрик в секунду для клиента запроса и лимиты кардинальности
+// для переданных серий. Оба лимита считаются по источнику запроса (см. cardinality.Source):
+// проверенному API токену или IP адресу соединения.
+// Если запись должна быть отклонена, отвечает клиенту ошибкой и возвращает false.
+// После успешного сохранения серии нужно зарегистрировать через Commit.
+func Admit(zlog *zap.SugaredLogger, limiter *cardinality.Limiter, w http.ResponseWriter, r *http.Request,
+	keys ...models.MetricKey) bool {
+	source := cardinality.Source(r)
+	var rateErr *ratelimit.LimitError
+	if err := ratelimit.AllowMetricsContext(r.Context(), source, len(keys)); errors.As(err, &rateErr) {
+		zlog.Warnf("rejected write of %d metrics: %v", len(keys), err)
+		WriteRateLimit(w, rateErr)
+		return false
+	}
+
+	err := limiter.Check(r.Context(), source, keys...)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, cardinality.ErrSeriesLimit), errors.Is(err, cardinality.ErrSourceLimit):
+		zlog.Warnf("rejected write from %s: %v", source, err)
+		var id string
+		var limitErr *cardinality.LimitError
+		if errors.As(err, &limitErr) {
+			id = limitErr.ID
+		}
+		WriteError(w, NewError(http.StatusTooManyRequests, CodeLimitExceeded,
+			err.Error(), id))
+	default:
+		zlog.Warnf("failed to check series limits: %v", err)
+		WriteError(w, InternalError(""))
+	}
+	return false
+}
+
+// Commit регистрирует в лимитах кардинальности серии, записанные запросом.
+func Commit(limiter *cardinality.Limiter, r *http.Request, keys ...models.MetricKey) {
+	limiter.Commit(cardinality.Source(r), keys...)
+}
+
+// WriteRateLimit отвечает 429 с заголовком Retry-After: через сколько секунд клиент может повторить запрос.
+func WriteRateLimit(w http.ResponseWriter, err *ratelimit.LimitError) {
+	retryAfter := int64(math.Ceil(err.RetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.FormatInt(max(retryAfter, 1), 10))
+	WriteError(w, NewError(http.StatusTooManyRequests, CodeRateLimited, err.Error(), ""))
+}
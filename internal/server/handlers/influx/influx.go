@@ -0,0 +1,112 @@
+package influx
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cardinality"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cumulative"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/lineprotocol"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/naming"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers"
+	"go.uber.org/zap"
+)
+
+const maxLineSize = 1024 * 1024
+
+// WriteHandler принимает метрики в InfluxDB line protocol: POST /api/v2/write.
+// Каждое поле точки становится метрикой <measurement>_<field>, теги - ее метками.
+// Целые поля, имя метрики которых tracker считает именем счетчика, считаются
+// накопительными счетчиками и записываются приращениями; остальные числовые и логические поля - gauge.
+// Строковые поля пропускаются. Запись выполняется целиком: при ошибке в любой строке ничего не сохраняется.
+func WriteHandler(
+	zlog *zap.SugaredLogger,
+	s routers.Storage,
+	limiter *cardinality.Limiter,
+	policy *naming.Policy,
+	tracker *cumulative.Tracker,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		type counter struct {
+			id    string
+			total float64
+		}
+		metrics := make([]*models.Metrics, 0)
+		counters := make([]counter, 0)
+
+		sc := bufio.NewScanner(r.Body)
+		sc.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+		for n := 1; sc.Scan(); n++ {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			p, err := lineprotocol.Parse(line)
+			if err != nil {
+				handlers.WriteError(w, handlers.NewError(http.StatusBadRequest, handlers.CodeInvalidRequest,
+					fmt.Sprintf("line %d: %v", n, err), ""))
+				return
+			}
+
+			for _, f := range p.Fields {
+				if f.Kind == lineprotocol.String {
+					continue
+				}
+
+				name := p.Measurement + "_" + f.Key
+				id, apiErr := handlers.AdmitSeries(policy, name, p.Tags)
+				if apiErr != nil {
+					apiErr.Message = fmt.Sprintf("line %d: %s", n, apiErr.Message)
+					handlers.WriteError(w, apiErr)
+					return
+				}
+
+				if (f.Kind == lineprotocol.Integer || f.Kind == lineprotocol.Unsigned) && tracker.IsCounter(name) {
+					counters = append(counters, counter{id: id, total: f.Value})
+					continue
+				}
+				v := f.Value
+				metrics = append(metrics, &models.Metrics{ID: id, MType: handlers.Gauge, Value: &v})
+			}
+		}
+		if err := sc.Err(); err != nil {
+			zlog.Warnf("failed to read line protocol body: %v", err)
+			handlers.WriteError(w, handlers.NewError(http.StatusBadRequest, handlers.CodeInvalidRequest,
+				fmt.Sprintf("failed to read body: %v", err), ""))
+			return
+		}
+
+		keys := cardinality.Keys(metrics)
+		for _, c := range counters {
+			keys = append(keys, models.MetricKey{ID: c.id, MType: handlers.Counter})
+		}
+		if !handlers.Admit(zlog, limiter, w, r, keys...) {
+			return
+		}
+
+		// приращения считаются только после проверки всего запроса,
+		// иначе отклоненный запрос сдвинул бы базовые значения счетчиков.
+		for _, c := range counters {
+			d := tracker.Delta(c.id, c.total)
+			metrics = append(metrics, &models.Metrics{ID: c.id, MType: handlers.Counter, Delta: &d})
+		}
+
+		if len(metrics) > 0 {
+			if err := s.SaveMetrics(r.Context(), metrics); err != nil {
+				zlog.Warnf("failed to save metrics: %v", err)
+				handlers.WriteError(w, handlers.InternalError(""))
+				return
+			}
+			handlers.Commit(limiter, r, keys...)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,99 @@
+package influx_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/logger"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers/chirouter"
+	"github.com/VanGoghDev/practicum-metrics/internal/storage/memstorage"
+)
+
+func TestWriteHandler(t *testing.T) {
+	log, _ := logger.New("Info")
+	memstrg, _ := memstorage.New(log)
+	r, err := chirouter.BuildRouter(memstrg, log, &config.Config{CounterSuffixes: "_total"})
+	require.NoError(t, err)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	gz := func(s string) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, err := zw.Write([]byte(s))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		gzip     bool
+		status   int
+		gauges   map[string]float64
+		counters map[string]int64
+	}{
+		{
+			name:   "gauges and counter baseline",
+			body:   "# telegraf\nmem,host=web1 used=512i,used_percent=12.5,ok=true,state=\"up\"\nhttp requests_total=100i\n",
+			status: http.StatusNoContent,
+			gauges: map[string]float64{
+				`mem_used{host="web1"}`: 512, `mem_used_percent{host="web1"}`: 12.5, `mem_ok{host="web1"}`: 1,
+			},
+			counters: map[string]int64{"http_requests_total": 0},
+		},
+		{
+			name:     "gzipped counter increment",
+			body:     "http requests_total=130i 1718000000000000000\n",
+			gzip:     true,
+			status:   http.StatusNoContent,
+			counters: map[string]int64{"http_requests_total": 30},
+		},
+		{
+			name:     "invalid line rejects whole request",
+			body:     "http requests_total=200i\nhttp requests_total=\n",
+			status:   http.StatusBadRequest,
+			counters: map[string]int64{"http_requests_total": 30},
+		},
+		{
+			name:   "invalid name",
+			body:   "http\\ server up=1\n",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := resty.New().R()
+			if tt.gzip {
+				req.SetHeader("Content-Encoding", "gzip").SetBody(gz(tt.body))
+			} else {
+				req.SetBody(tt.body)
+			}
+			resp, err := req.Post(srv.URL + "/api/v2/write?bucket=metrics&precision=ns")
+			require.NoError(t, err)
+			require.Equal(t, tt.status, resp.StatusCode(), resp.String())
+
+			for id, want := range tt.gauges {
+				g, err := memstrg.Gauge(context.Background(), id)
+				require.NoError(t, err, id)
+				assert.InDelta(t, want, g.Value, 0, id)
+			}
+			for id, want := range tt.counters {
+				c, err := memstrg.Counter(context.Background(), id)
+				require.NoError(t, err, id)
+				assert.Equal(t, want, c.Value, id)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package limits
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cardinality"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/naming"
+	"go.uber.org/zap"
+)
+
+// response счетчики лимитов кардинальности и политики именования в одном объекте.
+type response struct {
+	Series                 int   `json:"series"`
+	RejectedSeriesLimit    int64 `json:"rejected_series_limit"`
+	RejectedSourceLimit    int64 `json:"rejected_source_limit"`
+	RejectedInvalidName    int64 `json:"rejected_invalid_name"`
+	RejectedNameTooLong    int64 `json:"rejected_name_too_long"`
+	RejectedReservedPrefix int64 `json:"rejected_reserved_prefix"`
+	RejectedWritesTotal    int64 `json:"rejected_writes_total"`
+	MaxSeries              int64 `json:"max_series"`
+	MaxSeriesPerSource     int64 `json:"max_series_per_source"`
+	MaxNameLength          int64 `json:"max_name_length"`
+}
+
+// StatsHandler отдает текущее количество серий и счетчики записей,
+// отклоненных лимитами кардинальности и политикой именования.
+// rejected_writes_total учитывает отказы обоих видов.
+func StatsHandler(zlog *zap.SugaredLogger, limiter *cardinality.Limiter, policy *naming.Policy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		series, names := limiter.Stats(), policy.Stats()
+		resp := response{
+			Series:                 series.Series,
+			RejectedSeriesLimit:    series.RejectedSeriesLimit,
+			RejectedSourceLimit:    series.RejectedSourceLimit,
+			RejectedInvalidName:    names.RejectedInvalidName,
+			RejectedNameTooLong:    names.RejectedNameTooLong,
+			RejectedReservedPrefix: names.RejectedReservedPrefix,
+			RejectedWritesTotal:    series.RejectedWritesTotal + names.Rejected(),
+			MaxSeries:              series.MaxSeries,
+			MaxSeriesPerSource:     series.MaxSeriesPerSource,
+			MaxNameLength:          names.MaxNameLength,
+		}
+		enc := json.NewEncoder(w)
+		if err := enc.Encode(resp); err != nil {
+			zlog.Warnf("error encoding response %v", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+)
+
+// Ограничения размера страницы списка метрик.
+const (
+	DefaultListLimit = 100
+	MaxListLimit     = 1000
+)
+
+// ValidateSort проверяет порядок сортировки списка метрик.
+// Пустой порядок заменяется сортировкой по имени.
+func ValidateSort(sort string) (string, *Error) {
+	switch sort {
+	case "":
+		return models.SortByName, nil
+	case models.SortByName, models.SortByNameDesc, models.SortByType, models.SortByTypeDesc:
+		return sort, nil
+	default:
+		return "", NewError(http.StatusBadRequest, CodeInvalidRequest,
+			fmt.Sprintf("invalid sort %q, expected one of name, -name, type, -type", sort), "")
+	}
+}
+
+// EncodeCursor кодирует ключ последней метрики страницы в непрозрачный курсор.
+func EncodeCursor(key models.MetricKey) string {
+	data, _ := json.Marshal(key)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor восстанавливает ключ метрики из курсора EncodeCursor.
+func DecodeCursor(cursor string) (*models.MetricKey, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode cursor: %w", err)
+	}
+	key := &models.MetricKey{}
+	if err := json.Unmarshal(data, key); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cursor: %w", err)
+	}
+	return key, nil
+}
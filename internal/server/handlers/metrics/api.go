@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/naming"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+// GetHandler отдает метрику в JSON: GET /api/v1/metrics/{type}/{name}.
+func GetHandler(zlog *zap.SugaredLogger, s routers.Storage, policy *naming.Policy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		mType := chi.URLParam(r, "type")
+		mName := chi.URLParam(r, "name")
+
+		if apiErr := handlers.ValidateReadType(mType, mName); apiErr != nil {
+			handlers.WriteError(w, apiErr)
+			return
+		}
+
+		id, apiErr := handlers.NormalizeName(policy, mName)
+		if apiErr != nil {
+			handlers.WriteError(w, apiErr)
+			return
+		}
+
+		resp, err := fetchMetric(r.Context(), s, mType, id)
+		if err != nil {
+			handleError(zlog, err, w, id)
+			return
+		}
+
+		enc := json.NewEncoder(w)
+		if err := enc.Encode(resp); err != nil {
+			zlog.Warnf("error encoding response %v", err)
+			return
+		}
+	}
+}
+
+// listResponse страница списка метрик.
+type listResponse struct {
+	Metrics    []models.Metrics `json:"metrics"`
+	NextCursor string           `json:"next_cursor,omitempty"` // курсор следующей страницы, пусто - страница последняя
+}
+
+// ListHandler отдает страницу списка метрик в JSON:
+// GET /api/v1/metrics?type=&prefix=&match=&sort=&limit=&cursor=.
+// Фильтрация и сортировка выполняются хранилищем, курсор - непрозрачная строка из next_cursor предыдущей страницы.
+func ListHandler(zlog *zap.SugaredLogger, s routers.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		q, apiErr := parseListQuery(r)
+		if apiErr != nil {
+			handlers.WriteError(w, apiErr)
+			return
+		}
+
+		// запрашиваем на одну метрику больше, чтобы понять, есть ли следующая страница.
+		limit := q.Limit
+		q.Limit++
+		metrics, err := s.ListMetrics(r.Context(), q)
+		if err != nil {
+			zlog.Warnf("failed to list metrics: %v", err)
+			handlers.WriteError(w, handlers.InternalError(""))
+			return
+		}
+
+		resp := listResponse{Metrics: metrics}
+		if len(metrics) > limit {
+			resp.Metrics = metrics[:limit]
+			last := resp.Metrics[limit-1]
+			resp.NextCursor = handlers.EncodeCursor(models.MetricKey{ID: last.ID, MType: last.MType})
+		}
+
+		enc := json.NewEncoder(w)
+		if err := enc.Encode(resp); err != nil {
+			zlog.Warnf("error encoding response %v", err)
+			return
+		}
+	}
+}
+
+func parseListQuery(r *http.Request) (*models.ListQuery, *handlers.Error) {
+	params := r.URL.Query()
+	q := &models.ListQuery{
+		MType:  params.Get("type"),
+		Prefix: params.Get("prefix"),
+		Match:  params.Get("match"),
+		Sort:   params.Get("sort"),
+		Limit:  handlers.DefaultListLimit,
+	}
+
+	if q.MType != "" {
+		if apiErr := handlers.ValidateReadType(q.MType, ""); apiErr != nil {
+			return nil, apiErr
+		}
+	}
+
+	sort, apiErr := handlers.ValidateSort(q.Sort)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	q.Sort = sort
+
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > handlers.MaxListLimit {
+			return nil, handlers.NewError(http.StatusBadRequest, handlers.CodeInvalidRequest,
+				fmt.Sprintf("invalid limit %q, expected number from 1 to %d", v, handlers.MaxListLimit), "")
+		}
+		q.Limit = limit
+	}
+
+	if v := params.Get("cursor"); v != "" {
+		after, err := handlers.DecodeCursor(v)
+		if err != nil {
+			return nil, handlers.NewError(http.StatusBadRequest, handlers.CodeInvalidRequest,
+				fmt.Sprintf("invalid cursor %q", v), "")
+		}
+		q.After = after
+	}
+
+	return q, nil
+}
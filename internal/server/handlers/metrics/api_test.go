@@ -0,0 +1,182 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/logger"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers/chirouter"
+	"github.com/VanGoghDev/practicum-metrics/internal/storage/memstorage"
+)
+
+type listPage struct {
+	NextCursor string           `json:"next_cursor"`
+	Metrics    []models.Metrics `json:"metrics"`
+}
+
+type valueResult struct {
+	models.Metrics
+	NotFound bool `json:"not_found"`
+}
+
+func TestAPIv1(t *testing.T) {
+	log, _ := logger.New("Info")
+	memstrg, _ := memstorage.New(log)
+	memstrg.GaugesM = map[string]float64{"Alloc": 2.5}
+	memstrg.CountersM = map[string]int64{"PollCount": 3}
+	r, err := chirouter.BuildRouter(memstrg, log, &config.Config{})
+	require.NoError(t, err)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	client := resty.New().SetBaseURL(srv.URL + "/api/v1")
+
+	t.Run("put and get counter", func(t *testing.T) {
+		resp, err := client.R().SetBody(`{"delta": 2}`).Put("/metrics/counter/PollCount")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode())
+
+		var m models.Metrics
+		resp, err = client.R().SetResult(&m).Get("/metrics/counter/PollCount")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode())
+		require.NotNil(t, m.Delta)
+		assert.Equal(t, int64(5), *m.Delta)
+	})
+
+	t.Run("list by type", func(t *testing.T) {
+		var page listPage
+		resp, err := client.R().SetResult(&page).Get("/metrics?type=gauge")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode())
+		require.Len(t, page.Metrics, 1)
+		assert.Equal(t, "Alloc", page.Metrics[0].ID)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("lookup", func(t *testing.T) {
+		var list []valueResult
+		resp, err := client.R().
+			SetBody(`[{"id": "Alloc", "type": "gauge"}, {"id": "Alloc", "type": "counter"}]`).
+			SetResult(&list).
+			Post("/metrics/lookup")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode())
+		require.Len(t, list, 2)
+		assert.False(t, list[0].NotFound)
+		assert.True(t, list[1].NotFound)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		resp, err := client.R().Delete("/metrics/gauge/Alloc")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNoContent, resp.StatusCode())
+
+		resp, err = client.R().Get("/metrics/gauge/Alloc")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+
+		resp, err = client.R().Delete("/metrics/gauge/Alloc")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+	})
+
+	t.Run("openapi spec", func(t *testing.T) {
+		resp, err := client.R().Get("/openapi.json")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode())
+
+		var spec map[string]any
+		require.NoError(t, json.Unmarshal(resp.Body(), &spec))
+		assert.Equal(t, "3.0.3", spec["openapi"])
+	})
+}
+
+func TestListPagination(t *testing.T) {
+	log, _ := logger.New("Info")
+	memstrg, _ := memstorage.New(log)
+	memstrg.GaugesM = map[string]float64{
+		"HeapAlloc": 1, "HeapIdle": 2, "HeapInuse": 3, "StackInuse": 4, "Alloc": 5,
+	}
+	memstrg.CountersM = map[string]int64{"PollCount": 1, "HeapCount": 2}
+	r, err := chirouter.BuildRouter(memstrg, log, &config.Config{})
+	require.NoError(t, err)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		query   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "sorted by name",
+			query: "limit=2",
+			want:  []string{"Alloc", "HeapAlloc", "HeapCount", "HeapIdle", "HeapInuse", "PollCount", "StackInuse"},
+		},
+		{
+			name:  "prefix and descending sort",
+			query: "prefix=Heap&sort=-name&limit=3",
+			want:  []string{"HeapInuse", "HeapIdle", "HeapCount", "HeapAlloc"},
+		},
+		{
+			name:  "match and type",
+			query: "match=*Inuse&type=gauge",
+			want:  []string{"HeapInuse", "StackInuse"},
+		},
+		{
+			name:  "sorted by type",
+			query: "sort=type&prefix=Heap&limit=1",
+			want:  []string{"HeapCount", "HeapAlloc", "HeapIdle", "HeapInuse"},
+		},
+		{
+			name:    "invalid sort",
+			query:   "sort=value",
+			wantErr: true,
+		},
+		{
+			name:    "invalid cursor",
+			query:   "cursor=!!!",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			cursor := ""
+			for {
+				var page listPage
+				url := srv.URL + "/api/v1/metrics?" + tt.query
+				if cursor != "" {
+					url += "&cursor=" + cursor
+				}
+				resp, err := resty.New().R().SetResult(&page).Get(url)
+				require.NoError(t, err)
+				if tt.wantErr {
+					assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+					return
+				}
+				require.Equal(t, http.StatusOK, resp.StatusCode())
+
+				for _, m := range page.Metrics {
+					got = append(got, m.ID)
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				cursor = page.NextCursor
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
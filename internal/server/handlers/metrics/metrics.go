@@ -1,15 +1,18 @@
 package metrics
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/naming"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/routers"
 	"github.com/VanGoghDev/practicum-metrics/internal/storage/serrors"
+	"github.com/VanGoghDev/practicum-metrics/internal/util/codec"
 	"github.com/VanGoghDev/practicum-metrics/internal/util/converter"
 	"github.com/go-chi/chi"
 	"go.uber.org/zap"
@@ -20,131 +23,71 @@ var (
 	errFailedToFetchCounter = errors.New("failed to fetch counter")
 )
 
-const (
-	internalErrMsg = "Internal error"
-	notFoundErrMsg = "Not found"
-)
-
-func MetricsHandler(zlog *zap.SugaredLogger, s routers.Storage) http.HandlerFunc {
+// MetricHandler отдает значение метрики, запрошенной в теле: POST /value.
+// Формат запроса определяется по Content-Type (application/x-protobuf, application/msgpack, иначе JSON),
+// формат ответа - по Accept, а если он не задан - совпадает с форматом запроса.
+func MetricHandler(zlog *zap.SugaredLogger, s routers.Storage, policy *naming.Policy) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-
-		gauges, err := s.Gauges(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		reqCodec := codec.ForContentType(r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
 		if err != nil {
-			zlog.Warnf("failed to fetch gauges: %v", err)
-			http.Error(w, internalErrMsg, http.StatusInternalServerError)
+			zlog.Warnf("failed to read request body: %v", err)
+			handlers.WriteError(w, handlers.NewError(http.StatusBadRequest, handlers.CodeInvalidRequest,
+				fmt.Sprintf("failed to read body: %v", err), ""))
 			return
 		}
-
-		counters, err := s.Counters(r.Context())
+		req, err := reqCodec.UnmarshalMetric(body)
 		if err != nil {
-			zlog.Warnf("failed to fetch counters: %v", err)
-			http.Error(w, internalErrMsg, http.StatusInternalServerError)
+			zlog.Warnf("failed to decode request: %v", err)
+			handlers.WriteError(w, handlers.NewError(http.StatusBadRequest, handlers.CodeInvalidRequest,
+				fmt.Sprintf("failed to decode %s body: %v", reqCodec.Name(), err), ""))
 			return
 		}
 
-		for _, g := range gauges {
-			sV, err := converter.Str(g.Value)
-			if err != nil {
-				zlog.Warnf("failed to convert gauge value to string: %v", err)
-				http.Error(w, "", http.StatusInternalServerError)
-				break
-			}
-
-			_, err = fmt.Fprintf(w, "%s: %s \n", g.Name, sV)
-			if err != nil {
-				zlog.Warnf("failed to print gauges: %v", err)
-				http.Error(w, "", http.StatusInternalServerError)
-				break
-			}
+		if apiErr := handlers.ValidateType(req.MType, req.ID); apiErr != nil {
+			handlers.WriteError(w, apiErr)
+			return
 		}
 
-		for _, c := range counters {
-			sV, err := converter.Str(c.Value)
-			if err != nil {
-				zlog.Warnf("failed to convert counter value to string: %v", err)
-				http.Error(w, "", http.StatusInternalServerError)
-				break
-			}
-
-			_, err = fmt.Fprintf(w, "%s: %s \n", c.Name, sV)
-			if err != nil {
-				zlog.Warnf("failed to print counters: %v", err)
-				http.Error(w, "", http.StatusInternalServerError)
-				break
-			}
+		if req.ID == "" {
+			handlers.WriteError(w, handlers.NewError(http.StatusNotFound, handlers.CodeInvalidName,
+				"metric name is empty", ""))
+			return
 		}
-	}
-}
 
-func MetricHandler(zlog *zap.SugaredLogger, s routers.Storage) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		var req models.Metrics
-		dec := json.NewDecoder(r.Body)
-		if err := dec.Decode(&req); err != nil {
-			zlog.Errorf("failed to decode request: %w", err)
-			w.WriteHeader(http.StatusInternalServerError)
+		id, apiErr := handlers.NormalizeName(policy, req.ID)
+		if apiErr != nil {
+			handlers.WriteError(w, apiErr)
 			return
 		}
+		req.ID = id
 
-		if req.MType == "" || (req.MType != handlers.Gauge && req.MType != handlers.Counter) {
-			http.Error(w, "Invalid metric type", http.StatusBadRequest)
+		resp, err := fetchMetric(r.Context(), s, req.MType, req.ID)
+		if err != nil {
+			handleError(zlog, err, w, req.ID)
 			return
 		}
 
-		if req.ID == "" {
-			http.Error(w, "Invalid metric name", http.StatusNotFound)
+		respCodec := codec.Negotiate(r.Header.Get("Accept"), reqCodec)
+		data, err := respCodec.MarshalMetric(resp)
+		if err != nil {
+			zlog.Errorf("error encoding response: %v", err)
+			handlers.WriteError(w, handlers.InternalError(req.ID))
 			return
 		}
-
-		switch req.MType {
-		case handlers.Counter:
-			{
-				counter, err := s.Counter(r.Context(), req.ID)
-				if err != nil {
-					handleError(zlog, err, w)
-					return
-				}
-				resp := models.Metrics{
-					ID:    req.ID,
-					Delta: &counter.Value,
-					MType: req.MType,
-				}
-				enc := json.NewEncoder(w)
-				if err := enc.Encode(resp); err != nil {
-					zlog.Errorf("error encoding response: %w", err)
-					http.Error(w, internalErrMsg, http.StatusInternalServerError)
-					return
-				}
-				return
-			}
-		case handlers.Gauge:
-			{
-				gauge, err := s.Gauge(r.Context(), req.ID)
-				if err != nil {
-					handleError(zlog, err, w)
-					return
-				}
-
-				resp := models.Metrics{
-					ID:    req.ID,
-					Value: &gauge.Value,
-					MType: req.MType,
-				}
-				enc := json.NewEncoder(w)
-				if err := enc.Encode(resp); err != nil {
-					zlog.Errorf("error encoding writer: %w", errFailedToFetchGauge)
-					http.Error(w, internalErrMsg, http.StatusInternalServerError)
-					return
-				}
-				return
-			}
+		// JSON ответ, как и раньше, завершается переводом строки.
+		if respCodec == codec.JSON {
+			data = append(data, '\n')
+		}
+		w.Header().Set("Content-Type", respCodec.ContentType())
+		if _, err := w.Write(data); err != nil {
+			zlog.Errorf("failed to write response: %v", err)
 		}
 	}
 }
 
-func MetricHandlerRouterParams(zlog *zap.SugaredLogger, s routers.Storage) http.HandlerFunc {
+func MetricHandlerRouterParams(zlog *zap.SugaredLogger, s routers.Storage, policy *naming.Policy) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 
@@ -152,13 +95,20 @@ func MetricHandlerRouterParams(zlog *zap.SugaredLogger, s routers.Storage) http.
 		mType := chi.URLParam(r, "type")
 		mName := chi.URLParam(r, "name")
 
-		if mType == "" || (mType != handlers.Gauge && mType != handlers.Counter) {
-			http.Error(w, "Invalid metric type", http.StatusBadRequest)
+		if apiErr := handlers.ValidateType(mType, mName); apiErr != nil {
+			handlers.WriteError(w, apiErr)
 			return
 		}
 
 		if mName == "" {
-			http.Error(w, "Invalid metric name", http.StatusNotFound)
+			handlers.WriteError(w, handlers.NewError(http.StatusNotFound, handlers.CodeInvalidName,
+				"metric name is empty", ""))
+			return
+		}
+
+		mName, apiErr := handlers.NormalizeName(policy, mName)
+		if apiErr != nil {
+			handlers.WriteError(w, apiErr)
 			return
 		}
 
@@ -167,25 +117,20 @@ func MetricHandlerRouterParams(zlog *zap.SugaredLogger, s routers.Storage) http.
 			{
 				counter, err := s.Counter(r.Context(), mName)
 				if err != nil {
-					if errors.Is(err, serrors.ErrNotFound) {
-						http.Error(w, "Not found", http.StatusNotFound)
-						return
-					}
-					http.Error(w, internalErrMsg, http.StatusInternalServerError)
+					handleError(zlog, err, w, mName)
 					return
 				}
 				sV, err := converter.Str(counter.Value)
 				if err != nil {
-					zlog.Errorf("failed to convert counter value to string: %w", err)
-					http.Error(w, internalErrMsg, http.StatusInternalServerError)
+					zlog.Errorf("failed to convert counter value to string: %v", err)
+					handlers.WriteError(w, handlers.InternalError(mName))
 					return
 				}
 
 				_, err = fmt.Fprintf(w, "%s", sV)
 				if err != nil {
 					zlog.Errorf("%v: %v", errFailedToFetchCounter, err)
-
-					http.Error(w, internalErrMsg, http.StatusInternalServerError)
+					handlers.WriteError(w, handlers.InternalError(mName))
 					return
 				}
 				return
@@ -194,24 +139,19 @@ func MetricHandlerRouterParams(zlog *zap.SugaredLogger, s routers.Storage) http.
 			{
 				gauge, err := s.Gauge(r.Context(), mName)
 				if err != nil {
-					if errors.Is(err, serrors.ErrNotFound) {
-						http.Error(w, "Not found", http.StatusNotFound)
-						return
-					}
-					zlog.Errorf("%v: %w", err)
-					http.Error(w, internalErrMsg, http.StatusInternalServerError)
+					handleError(zlog, err, w, mName)
 					return
 				}
 				sV, err := converter.Str(gauge.Value)
 				if err != nil {
-					zlog.Errorf("failed to convert gauge value to string: %w", err)
-					http.Error(w, internalErrMsg, http.StatusInternalServerError)
+					zlog.Errorf("failed to convert gauge value to string: %v", err)
+					handlers.WriteError(w, handlers.InternalError(mName))
 					return
 				}
 				_, err = fmt.Fprintf(w, "%s", sV)
 				if err != nil {
-					zlog.Errorf("%v", errFailedToFetchGauge)
-					http.Error(w, internalErrMsg, http.StatusInternalServerError)
+					zlog.Errorf("%v: %v", errFailedToFetchGauge, err)
+					handlers.WriteError(w, handlers.InternalError(mName))
 					return
 				}
 				return
@@ -220,11 +160,49 @@ func MetricHandlerRouterParams(zlog *zap.SugaredLogger, s routers.Storage) http.
 	}
 }
 
-func handleError(zlog *zap.SugaredLogger, err error, w http.ResponseWriter) {
+// fetchMetric читает из хранилища метрику указанного типа.
+func fetchMetric(ctx context.Context, s routers.Storage, mType, id string) (*models.Metrics, error) {
+	switch mType {
+	case handlers.Counter:
+		counter, err := s.Counter(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errFailedToFetchCounter, err)
+		}
+		return &models.Metrics{
+			ID:    id,
+			Delta: &counter.Value,
+			MType: mType,
+		}, nil
+	case handlers.Gauge:
+		gauge, err := s.Gauge(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errFailedToFetchGauge, err)
+		}
+		return &models.Metrics{
+			ID:    id,
+			Value: &gauge.Value,
+			MType: mType,
+		}, nil
+	case handlers.Histogram:
+		found, err := s.MetricsByKeys(ctx, []models.MetricKey{{ID: id, MType: mType}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch histogram: %w", err)
+		}
+		if len(found) == 0 {
+			return nil, serrors.ErrNotFound
+		}
+		return &found[0], nil
+	default:
+		return nil, serrors.ErrNotFound
+	}
+}
+
+func handleError(zlog *zap.SugaredLogger, err error, w http.ResponseWriter, id string) {
 	if errors.Is(err, serrors.ErrNotFound) {
-		http.Error(w, notFoundErrMsg, http.StatusNotFound)
+		handlers.WriteError(w, handlers.NewError(http.StatusNotFound, handlers.CodeNotFound,
+			fmt.Sprintf("metric %q not found", id), id))
 		return
 	}
-	zlog.Errorf("Invalid metric type: %w", err)
-	http.Error(w, internalErrMsg, http.StatusInternalServerError)
+	zlog.Errorf("failed to fetch metric %s: %v", id, err)
+	handlers.WriteError(w, handlers.InternalError(id))
 }
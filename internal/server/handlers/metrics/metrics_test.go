@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-resty/resty/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/logger"
@@ -68,7 +69,8 @@ func TestMetricHandler(t *testing.T) {
 			memstrg, _ := memstorage.New(log)
 			memstrg.CountersM = tt.countersM
 			memstrg.GaugesM = tt.gaugesM
-			r := chirouter.BuildRouter(memstrg, log, &config.Config{})
+			r, err := chirouter.BuildRouter(memstrg, log, &config.Config{})
+			require.NoError(t, err)
 			srv := httptest.NewServer(r)
 			defer srv.Close()
 
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/naming"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers"
+	"go.uber.org/zap"
+)
+
+// valueResult элемент ответа на пакетный запрос значений.
+// Для отсутствующей метрики возвращаются только id, type и признак not_found.
+type valueResult struct {
+	models.Metrics
+	NotFound bool `json:"not_found,omitempty"`
+}
+
+// ValuesHandler отдает значения нескольких метрик за один запрос: POST /values.
+// Тело запроса - массив объектов {id, type}, ответ - массив метрик в том же порядке.
+func ValuesHandler(zlog *zap.SugaredLogger, s routers.Storage, policy *naming.Policy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		req := []models.MetricKey{}
+		dec := json.NewDecoder(r.Body)
+		if err := dec.Decode(&req); err != nil {
+			zlog.Warnf("failed to decode request: %v", err)
+			handlers.WriteError(w, handlers.NewError(http.StatusBadRequest, handlers.CodeInvalidRequest,
+				fmt.Sprintf("failed to decode JSON body: %v", err), ""))
+			return
+		}
+
+		for i, k := range req {
+			if apiErr := handlers.ValidateReadType(k.MType, k.ID); apiErr != nil {
+				handlers.WriteError(w, apiErr)
+				return
+			}
+
+			id, apiErr := handlers.NormalizeName(policy, k.ID)
+			if apiErr != nil {
+				handlers.WriteError(w, apiErr)
+				return
+			}
+			req[i].ID = id
+		}
+
+		found, err := s.MetricsByKeys(r.Context(), req)
+		if err != nil {
+			zlog.Warnf("failed to fetch metrics: %v", err)
+			handlers.WriteError(w, handlers.InternalError(""))
+			return
+		}
+
+		byKey := make(map[models.MetricKey]models.Metrics, len(found))
+		for _, m := range found {
+			byKey[models.MetricKey{ID: m.ID, MType: m.MType}] = m
+		}
+
+		resp := make([]valueResult, 0, len(req))
+		for _, k := range req {
+			m, ok := byKey[k]
+			if !ok {
+				resp = append(resp, valueResult{
+					Metrics:  models.Metrics{ID: k.ID, MType: k.MType},
+					NotFound: true,
+				})
+				continue
+			}
+			resp = append(resp, valueResult{Metrics: m})
+		}
+
+		enc := json.NewEncoder(w)
+		if err := enc.Encode(resp); err != nil {
+			zlog.Warnf("error encoding response %v", err)
+			return
+		}
+	}
+}
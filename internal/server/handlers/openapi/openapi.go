@@ -0,0 +1,22 @@
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// SpecHandler отдает OpenAPI описание API /api/v1.
+func SpecHandler(zlog *zap.SugaredLogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(spec); err != nil {
+			zlog.Warnf("failed to write openapi spec: %v", err)
+			return
+		}
+	}
+}
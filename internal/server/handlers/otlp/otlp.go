@@ -0,0 +1,303 @@
+package otlp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cardinality"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cumulative"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/naming"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers"
+	"github.com/VanGoghDev/practicum-metrics/internal/storage/serrors"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+)
+
+// MetricsHandler принимает метрики OpenTelemetry по OTLP/HTTP: POST /v1/metrics.
+// Тело - ExportMetricsServiceRequest в protobuf или JSON, в зависимости от Content-Type;
+// ответ кодируется так же. Атрибуты ресурса и точки становятся метками серии, точки в ключах заменяются на _.
+//
+// Монотонный Sum записывается как counter (накопительные значения переводятся в приращения),
+// немонотонный Sum и Gauge - как gauge, Histogram - как histogram с теми же границами бакетов
+// (накопительные гистограммы также переводятся в приращения). Min и Max гистограммы не хранятся.
+// Экспоненциальные гистограммы и Summary пропускаются.
+func MetricsHandler(
+	zlog *zap.SugaredLogger,
+	s routers.Storage,
+	limiter *cardinality.Limiter,
+	policy *naming.Policy,
+	tracker *cumulative.Tracker,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if contentType != contentTypeProtobuf && contentType != contentTypeJSON {
+			handlers.WriteError(w, handlers.NewError(http.StatusUnsupportedMediaType, handlers.CodeInvalidRequest,
+				fmt.Sprintf("unsupported content type %q", contentType), ""))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			zlog.Warnf("failed to read otlp body: %v", err)
+			handlers.WriteError(w, handlers.NewError(http.StatusBadRequest, handlers.CodeInvalidRequest,
+				fmt.Sprintf("failed to read body: %v", err), ""))
+			return
+		}
+
+		req := &colmetricspb.ExportMetricsServiceRequest{}
+		if contentType == contentTypeJSON {
+			err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, req)
+		} else {
+			err = proto.Unmarshal(body, req)
+		}
+		if err != nil {
+			handlers.WriteError(w, handlers.NewError(http.StatusBadRequest, handlers.CodeInvalidRequest,
+				fmt.Sprintf("failed to decode export request: %v", err), ""))
+			return
+		}
+
+		b := &batch{policy: policy}
+		for _, rm := range req.GetResourceMetrics() {
+			resource := attributes(nil, rm.GetResource().GetAttributes())
+			for _, sm := range rm.GetScopeMetrics() {
+				for _, m := range sm.GetMetrics() {
+					if apiErr := b.add(resource, m); apiErr != nil {
+						handlers.WriteError(w, apiErr)
+						return
+					}
+				}
+			}
+		}
+
+		if !handlers.Admit(zlog, limiter, w, r, b.keys...) {
+			return
+		}
+
+		// приращения считаются только после проверки всего запроса,
+		// иначе отклоненный запрос сдвинул бы базовые значения счетчиков.
+		for _, c := range b.counters {
+			d := tracker.Delta(c.id, c.total)
+			b.metrics = append(b.metrics, &models.Metrics{ID: c.id, MType: handlers.Counter, Delta: &d})
+		}
+		for _, h := range b.histograms {
+			d := tracker.HistogramDelta(h.id, h.total)
+			b.metrics = append(b.metrics, &models.Metrics{ID: h.id, MType: handlers.Histogram, Histogram: &d})
+		}
+
+		if len(b.metrics) > 0 {
+			err := s.SaveMetrics(r.Context(), b.metrics)
+			switch {
+			case err == nil:
+			case errors.Is(err, serrors.ErrTypeMismatch):
+				zlog.Warnf("rejected metrics: %v", err)
+				handlers.WriteError(w, handlers.NewError(http.StatusConflict, handlers.CodeInvalidType,
+					err.Error(), ""))
+				return
+			default:
+				zlog.Warnf("failed to save metrics: %v", err)
+				handlers.WriteError(w, handlers.InternalError(""))
+				return
+			}
+			handlers.Commit(limiter, r, b.keys...)
+		}
+
+		resp := &colmetricspb.ExportMetricsServiceResponse{}
+		var data []byte
+		if contentType == contentTypeJSON {
+			data, err = protojson.Marshal(resp)
+		} else {
+			data, err = proto.Marshal(resp)
+		}
+		if err != nil {
+			zlog.Warnf("failed to marshal otlp response: %v", err)
+			handlers.WriteError(w, handlers.InternalError(""))
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(data); err != nil {
+			zlog.Warnf("failed to write response: %v", err)
+		}
+	}
+}
+
+type counter struct {
+	id    string
+	total float64
+}
+
+type histogram struct {
+	id    string
+	total models.Histogram
+}
+
+// batch собирает метрики одного запроса. Накопительные значения счетчиков и гистограмм
+// откладываются в counters и histograms до проверки лимитов.
+type batch struct {
+	policy     *naming.Policy
+	metrics    []*models.Metrics
+	counters   []counter
+	histograms []histogram
+	keys       []models.MetricKey
+}
+
+func (b *batch) add(resource map[string]string, m *metricspb.Metric) *handlers.Error {
+	switch data := m.GetData().(type) {
+	case *metricspb.Metric_Gauge:
+		for _, dp := range data.Gauge.GetDataPoints() {
+			if err := b.number(m.GetName(), resource, dp, false, false); err != nil {
+				return err
+			}
+		}
+	case *metricspb.Metric_Sum:
+		monotonic := data.Sum.GetIsMonotonic()
+		delta := data.Sum.GetAggregationTemporality() == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+		for _, dp := range data.Sum.GetDataPoints() {
+			if err := b.number(m.GetName(), resource, dp, monotonic, delta); err != nil {
+				return err
+			}
+		}
+	case *metricspb.Metric_Histogram:
+		delta := data.Histogram.GetAggregationTemporality() == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+		for _, dp := range data.Histogram.GetDataPoints() {
+			if err := b.histogram(m.GetName(), resource, dp, delta); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b *batch) number(name string, resource map[string]string, dp *metricspb.NumberDataPoint,
+	monotonic, delta bool) *handlers.Error {
+	if noRecordedValue(dp.GetFlags()) {
+		return nil
+	}
+
+	v := dp.GetAsDouble()
+	if _, ok := dp.GetValue().(*metricspb.NumberDataPoint_AsInt); ok {
+		v = float64(dp.GetAsInt())
+	}
+
+	labels := attributes(resource, dp.GetAttributes())
+	if monotonic {
+		return b.counter(name, labels, v, delta)
+	}
+	return b.gauge(name, labels, v)
+}
+
+func (b *batch) histogram(name string, resource map[string]string, dp *metricspb.HistogramDataPoint,
+	delta bool) *handlers.Error {
+	if noRecordedValue(dp.GetFlags()) {
+		return nil
+	}
+
+	h := models.Histogram{
+		Bounds: dp.GetExplicitBounds(),
+		Counts: dp.GetBucketCounts(),
+		Count:  dp.GetCount(),
+		Sum:    dp.GetSum(),
+	}
+	// без бакетов OTLP передает только количество и сумму: это гистограмма с одним бакетом.
+	if len(h.Counts) == 0 && len(h.Bounds) == 0 {
+		h.Counts = []uint64{h.Count}
+	}
+
+	id, apiErr := b.id(name, attributes(resource, dp.GetAttributes()))
+	if apiErr != nil {
+		return apiErr
+	}
+	if err := h.Validate(); err != nil {
+		return handlers.NewError(http.StatusBadRequest, handlers.CodeInvalidValue,
+			fmt.Sprintf("metric %q: %v", id, err), id)
+	}
+
+	b.keys = append(b.keys, models.MetricKey{ID: id, MType: handlers.Histogram})
+	if delta {
+		b.metrics = append(b.metrics, &models.Metrics{ID: id, MType: handlers.Histogram, Histogram: &h})
+		return nil
+	}
+	b.histograms = append(b.histograms, histogram{id: id, total: h})
+	return nil
+}
+
+func (b *batch) gauge(name string, labels map[string]string, v float64) *handlers.Error {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	id, err := b.id(name, labels)
+	if err != nil {
+		return err
+	}
+	b.metrics = append(b.metrics, &models.Metrics{ID: id, MType: handlers.Gauge, Value: &v})
+	b.keys = append(b.keys, models.MetricKey{ID: id, MType: handlers.Gauge})
+	return nil
+}
+
+// counter добавляет значение счетчика. Значения с временной агрегацией delta уже являются
+// приращениями, накопительные переводятся в приращения после проверки лимитов.
+func (b *batch) counter(name string, labels map[string]string, v float64, delta bool) *handlers.Error {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	id, err := b.id(name, labels)
+	if err != nil {
+		return err
+	}
+	b.keys = append(b.keys, models.MetricKey{ID: id, MType: handlers.Counter})
+	if delta {
+		d := int64(math.Round(v))
+		b.metrics = append(b.metrics, &models.Metrics{ID: id, MType: handlers.Counter, Delta: &d})
+		return nil
+	}
+	b.counters = append(b.counters, counter{id: id, total: v})
+	return nil
+}
+
+func (b *batch) id(name string, labels map[string]string) (string, *handlers.Error) {
+	return handlers.AdmitSeries(b.policy, name, labels)
+}
+
+// attributes возвращает метки base, дополненные атрибутами attrs.
+// Атрибуты-массивы, словари и байтовые значения пропускаются.
+func attributes(base map[string]string, attrs []*commonpb.KeyValue) map[string]string {
+	labels := make(map[string]string, len(base)+len(attrs))
+	for k, v := range base {
+		labels[k] = v
+	}
+	for _, kv := range attrs {
+		switch v := kv.GetValue().GetValue().(type) {
+		case *commonpb.AnyValue_StringValue:
+			labels[kv.GetKey()] = v.StringValue
+		case *commonpb.AnyValue_BoolValue:
+			labels[kv.GetKey()] = strconv.FormatBool(v.BoolValue)
+		case *commonpb.AnyValue_IntValue:
+			labels[kv.GetKey()] = strconv.FormatInt(v.IntValue, 10)
+		case *commonpb.AnyValue_DoubleValue:
+			labels[kv.GetKey()] = strconv.FormatFloat(v.DoubleValue, 'f', -1, 64)
+		}
+	}
+	return labels
+}
+
+func noRecordedValue(flags uint32) bool {
+	return flags&uint32(metricspb.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK) != 0
+}
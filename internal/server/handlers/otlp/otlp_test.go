@@ -0,0 +1,173 @@
+package otlp_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/logger"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers/chirouter"
+	"github.com/VanGoghDev/practicum-metrics/internal/storage/memstorage"
+)
+
+func attr(k, v string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: k, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}}
+}
+
+// export кодирует запрос с метриками сервиса checkout в protobuf.
+func export(t *testing.T, requests int64, duration []uint64, sum float64) []byte {
+	t.Helper()
+	cumulative := metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+	req := &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{attr("service.name", "checkout")}},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Metrics: []*metricspb.Metric{
+					{
+						Name: "http.server.requests",
+						Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+							IsMonotonic:            true,
+							AggregationTemporality: cumulative,
+							DataPoints: []*metricspb.NumberDataPoint{{
+								Attributes: []*commonpb.KeyValue{attr("method", "GET")},
+								Value:      &metricspb.NumberDataPoint_AsInt{AsInt: requests},
+							}},
+						}},
+					},
+					{
+						Name: "http.server.duration",
+						Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+							AggregationTemporality: cumulative,
+							DataPoints: []*metricspb.HistogramDataPoint{{
+								Count:          duration[0] + duration[1] + duration[2],
+								Sum:            &sum,
+								BucketCounts:   duration,
+								ExplicitBounds: []float64{0.1, 0.5},
+							}},
+						}},
+					},
+				},
+			}},
+		}},
+	}
+	data, err := proto.Marshal(req)
+	require.NoError(t, err)
+	return data
+}
+
+func TestMetricsHandler(t *testing.T) {
+	log, _ := logger.New("Info")
+	memstrg, _ := memstorage.New(log)
+	r, err := chirouter.BuildRouter(memstrg, log, &config.Config{})
+	require.NoError(t, err)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	const service = `service_name="checkout"`
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		status      int
+		gauges      map[string]float64
+		counters    map[string]int64
+		histograms  map[string]models.Histogram
+	}{
+		{
+			name:        "protobuf baseline",
+			contentType: "application/x-protobuf",
+			body:        export(t, 100, []uint64{5, 3, 1}, 1.75),
+			status:      http.StatusOK,
+			counters:    map[string]int64{`http.server.requests{method="GET",` + service + "}": 0},
+			histograms: map[string]models.Histogram{
+				"http.server.duration{" + service + "}": {Bounds: []float64{0.1, 0.5}, Counts: []uint64{0, 0, 0}},
+			},
+		},
+		{
+			name:        "protobuf increments",
+			contentType: "application/x-protobuf",
+			body:        export(t, 110, []uint64{7, 3, 2}, 2.5),
+			status:      http.StatusOK,
+			counters:    map[string]int64{`http.server.requests{method="GET",` + service + "}": 10},
+			histograms: map[string]models.Histogram{
+				"http.server.duration{" + service + "}": {
+					Bounds: []float64{0.1, 0.5}, Counts: []uint64{2, 0, 1}, Count: 3, Sum: 0.75,
+				},
+			},
+		},
+		{
+			name:        "json gauge and delta sum",
+			contentType: "application/json",
+			body: []byte(`{"resourceMetrics":[{"resource":{"attributes":[
+				{"key":"service.name","value":{"stringValue":"checkout"}}]},
+				"scopeMetrics":[{"metrics":[
+				{"name":"queue.size","gauge":{"dataPoints":[{"asDouble":12.5}]}},
+				{"name":"orders.created","sum":{"isMonotonic":true,"aggregationTemporality":1,
+				"dataPoints":[{"asInt":"4"}]}}
+				]}]}]}`),
+			status: http.StatusOK,
+			gauges: map[string]float64{"queue.size{" + service + "}": 12.5},
+			counters: map[string]int64{
+				"orders.created{" + service + "}": 4,
+			},
+		},
+		{
+			name:        "invalid json",
+			contentType: "application/json",
+			body:        []byte(`{"resourceMetrics":`),
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "unsupported content type",
+			contentType: "text/plain",
+			body:        []byte("queue.size 12.5"),
+			status:      http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := resty.New().R().
+				SetHeader("Content-Type", tt.contentType).
+				SetBody(tt.body).
+				Post(srv.URL + "/v1/metrics")
+			require.NoError(t, err)
+			require.Equal(t, tt.status, resp.StatusCode(), resp.String())
+			if tt.status == http.StatusOK {
+				assert.Equal(t, tt.contentType, resp.Header().Get("Content-Type"))
+			}
+
+			for id, want := range tt.gauges {
+				g, err := memstrg.Gauge(context.Background(), id)
+				require.NoError(t, err, id)
+				assert.InDelta(t, want, g.Value, 0, id)
+			}
+			for id, want := range tt.counters {
+				c, err := memstrg.Counter(context.Background(), id)
+				require.NoError(t, err, id)
+				assert.Equal(t, want, c.Value, id)
+			}
+			for id, want := range tt.histograms {
+				metrics, err := memstrg.MetricsByKeys(context.Background(),
+					[]models.MetricKey{{ID: id, MType: "histogram"}})
+				require.NoError(t, err, id)
+				require.Len(t, metrics, 1, id)
+				require.NotNil(t, metrics[0].Histogram, id)
+				assert.Equal(t, want, *metrics[0].Histogram, id)
+			}
+		})
+	}
+}
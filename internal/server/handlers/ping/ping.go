@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/routers"
 	"go.uber.org/zap"
 )
@@ -13,7 +14,7 @@ func PingHandler(zlog *zap.SugaredLogger, cfg *config.Config, storage routers.St
 		err := storage.Ping(r.Context())
 		if err != nil {
 			zlog.Warnf("failed to ping db: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			handlers.WriteError(w, handlers.InternalError(""))
 			return
 		}
 	}
@@ -0,0 +1,137 @@
+package remotewrite
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cardinality"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cumulative"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/naming"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/prompb"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers"
+	"go.uber.org/zap"
+)
+
+const nameLabel = "__name__"
+
+// WriteHandler принимает Prometheus remote write: POST /api/v1/write.
+// Тело - WriteRequest в protobuf, сжатый snappy (распаковывается middleware compressor).
+// Метка __name__ становится именем метрики, остальные метки сохраняются в имени серии.
+// Счетчиками считаются метрики, отмеченные как counter в метаданных (Prometheus присылает их
+// отдельными запросами, поэтому они запоминаются в tracker), либо подходящие под соглашение об именовании tracker.
+// Счетчики записываются приращениями, остальные метрики - gauge с последним значением.
+// NaN (в том числе маркеры устаревания) пропускаются.
+// Серии с недопустимым именем тоже пропускаются, а остальные записываются: Prometheus не повторяет
+// запросы, отклоненные с 4xx, и из-за одной серии потерял бы весь батч.
+func WriteHandler(
+	zlog *zap.SugaredLogger,
+	s routers.Storage,
+	limiter *cardinality.Limiter,
+	policy *naming.Policy,
+	tracker *cumulative.Tracker,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			zlog.Warnf("failed to read remote write body: %v", err)
+			handlers.WriteError(w, handlers.NewError(http.StatusBadRequest, handlers.CodeInvalidRequest,
+				fmt.Sprintf("failed to read body: %v", err), ""))
+			return
+		}
+
+		req, err := prompb.Unmarshal(body)
+		if err != nil {
+			handlers.WriteError(w, handlers.NewError(http.StatusBadRequest, handlers.CodeInvalidRequest,
+				fmt.Sprintf("failed to decode write request: %v", err), ""))
+			return
+		}
+
+		for _, md := range req.Metadata {
+			if md.Type == prompb.MetricTypeCounter {
+				tracker.MarkCounter(md.MetricFamilyName)
+			}
+		}
+
+		type counter struct {
+			id    string
+			total float64
+		}
+		metrics := make([]*models.Metrics, 0, len(req.Timeseries))
+		counters := make([]counter, 0)
+		var skipped int
+		var skipErr *handlers.Error
+
+		for _, ts := range req.Timeseries {
+			var name string
+			labels := make(map[string]string, len(ts.Labels))
+			for _, l := range ts.Labels {
+				if l.Name == nameLabel {
+					name = l.Value
+					continue
+				}
+				labels[l.Name] = l.Value
+			}
+
+			id, apiErr := handlers.AdmitSeries(policy, name, labels)
+			if apiErr != nil {
+				skipped++
+				if skipErr == nil {
+					skipErr = apiErr
+				}
+				continue
+			}
+
+			isCounter := tracker.IsCounter(name)
+
+			var last *float64
+			for _, sample := range ts.Samples {
+				v := sample.Value
+				if math.IsNaN(v) || math.IsInf(v, 0) {
+					continue
+				}
+				if isCounter {
+					counters = append(counters, counter{id: id, total: v})
+				} else {
+					last = &v
+				}
+			}
+			if last != nil {
+				metrics = append(metrics, &models.Metrics{ID: id, MType: handlers.Gauge, Value: last})
+			}
+		}
+
+		if skipped > 0 {
+			zlog.Warnf("skipped %d of %d remote write series: %v", skipped, len(req.Timeseries), skipErr)
+		}
+
+		keys := cardinality.Keys(metrics)
+		for _, c := range counters {
+			keys = append(keys, models.MetricKey{ID: c.id, MType: handlers.Counter})
+		}
+		if !handlers.Admit(zlog, limiter, w, r, keys...) {
+			return
+		}
+
+		for _, c := range counters {
+			d := tracker.Delta(c.id, c.total)
+			metrics = append(metrics, &models.Metrics{ID: c.id, MType: handlers.Counter, Delta: &d})
+		}
+
+		if len(metrics) > 0 {
+			if err := s.SaveMetrics(r.Context(), metrics); err != nil {
+				zlog.Warnf("failed to save metrics: %v", err)
+				handlers.WriteError(w, handlers.InternalError(""))
+				return
+			}
+			handlers.Commit(limiter, r, keys...)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,144 @@
+package remotewrite_test
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/logger"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers/chirouter"
+	"github.com/VanGoghDev/practicum-metrics/internal/storage/memstorage"
+)
+
+func bytesField(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// series кодирует TimeSeries с метками в порядке name, value, name, value... и значениями values.
+func series(labels []string, values ...float64) []byte {
+	var ts []byte
+	for i := 0; i+1 < len(labels); i += 2 {
+		l := bytesField(nil, 1, []byte(labels[i]))
+		l = bytesField(l, 2, []byte(labels[i+1]))
+		ts = bytesField(ts, 1, l)
+	}
+	for _, v := range values {
+		s := protowire.AppendTag(nil, 1, protowire.Fixed64Type)
+		s = protowire.AppendFixed64(s, math.Float64bits(v))
+		ts = bytesField(ts, 2, s)
+	}
+	return bytesField(nil, 1, ts)
+}
+
+func counterMetadata(family string) []byte {
+	md := protowire.AppendTag(nil, 1, protowire.VarintType)
+	md = protowire.AppendVarint(md, 1)
+	md = bytesField(md, 2, []byte(family))
+	return bytesField(nil, 3, md)
+}
+
+func join(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func TestWriteHandler(t *testing.T) {
+	log, _ := logger.New("Info")
+	memstrg, _ := memstorage.New(log)
+	r, err := chirouter.BuildRouter(memstrg, log, &config.Config{CounterSuffixes: "_total"})
+	require.NoError(t, err)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		body     []byte
+		raw      bool
+		status   int
+		gauges   map[string]float64
+		counters map[string]int64
+	}{
+		{
+			name: "gauges and counter baselines",
+			body: join(
+				series([]string{"__name__", "up", "job", "api", "instance", "a:9090"}, 0, 1),
+				series([]string{"__name__", "http_requests_total", "job", "api"}, 100),
+				series([]string{"__name__", "jobs_processed", "job", "api"}, 10),
+				counterMetadata("jobs_processed"),
+			),
+			status:   http.StatusNoContent,
+			gauges:   map[string]float64{`up{instance="a:9090",job="api"}`: 1},
+			counters: map[string]int64{`http_requests_total{job="api"}`: 0, `jobs_processed{job="api"}`: 0},
+		},
+		{
+			name: "counter increments and staleness marker",
+			body: join(
+				series([]string{"__name__", "http_requests_total", "job", "api"}, 120, 150),
+				series([]string{"__name__", "jobs_processed", "job", "api"}, 4),
+				series([]string{"__name__", "up", "job", "api", "instance", "a:9090"}, math.NaN()),
+			),
+			status:   http.StatusNoContent,
+			gauges:   map[string]float64{`up{instance="a:9090",job="api"}`: 1},
+			counters: map[string]int64{`http_requests_total{job="api"}`: 50, `jobs_processed{job="api"}`: 4},
+		},
+		{
+			name: "invalid series are skipped",
+			body: join(
+				series([]string{"job", "api"}, 1),
+				series([]string{"__name__", "bad name", "job", "api"}, 1),
+				series([]string{"__name__", "http_requests_total", "job", "api"}, 160),
+				series([]string{"__name__", "queue_size", "job", "api"}, 7),
+			),
+			status:   http.StatusNoContent,
+			gauges:   map[string]float64{`queue_size{job="api"}`: 7},
+			counters: map[string]int64{`http_requests_total{job="api"}`: 60},
+		},
+		{
+			name:   "body is not snappy",
+			body:   series([]string{"__name__", "up"}, 1),
+			raw:    true,
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := tt.body
+			if !tt.raw {
+				body = snappy.Encode(nil, body)
+			}
+			resp, err := resty.New().R().
+				SetHeader("Content-Encoding", "snappy").
+				SetHeader("Content-Type", "application/x-protobuf").
+				SetHeader("X-Prometheus-Remote-Write-Version", "0.1.0").
+				SetBody(body).
+				Post(srv.URL + "/api/v1/write")
+			require.NoError(t, err)
+			require.Equal(t, tt.status, resp.StatusCode(), resp.String())
+
+			for id, want := range tt.gauges {
+				g, err := memstrg.Gauge(context.Background(), id)
+				require.NoError(t, err, id)
+				assert.InDelta(t, want, g.Value, 0, id)
+			}
+			for id, want := range tt.counters {
+				c, err := memstrg.Counter(context.Background(), id)
+				require.NoError(t, err, id)
+				assert.Equal(t, want, c.Value, id)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package remove
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cardinality"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cumulative"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/naming"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers"
+	"github.com/VanGoghDev/practicum-metrics/internal/storage/serrors"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+// DeleteHandler удаляет метрику: DELETE /api/v1/metrics/{type}/{name}.
+// Удаленная серия перестает учитываться в лимитах кардинальности, а tracker забывает ее накопленное значение.
+func DeleteHandler(zlog *zap.SugaredLogger, s routers.Storage, limiter *cardinality.Limiter,
+	policy *naming.Policy, tracker *cumulative.Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		mType := chi.URLParam(r, "type")
+		mName := chi.URLParam(r, "name")
+
+		if apiErr := handlers.ValidateReadType(mType, mName); apiErr != nil {
+			handlers.WriteError(w, apiErr)
+			return
+		}
+
+		id, apiErr := handlers.NormalizeName(policy, mName)
+		if apiErr != nil {
+			handlers.WriteError(w, apiErr)
+			return
+		}
+
+		err := s.DeleteMetric(r.Context(), mType, id)
+		if err != nil {
+			if errors.Is(err, serrors.ErrNotFound) {
+				handlers.WriteError(w, handlers.NewError(http.StatusNotFound, handlers.CodeNotFound,
+					fmt.Sprintf("metric %q not found", id), id))
+				return
+			}
+			zlog.Warnf("failed to delete metric %s: %v", id, err)
+			handlers.WriteError(w, handlers.InternalError(id))
+			return
+		}
+
+		key := models.MetricKey{ID: id, MType: mType}
+		limiter.Forget(key)
+		tracker.Forget(key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,150 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/pubsub"
+	"github.com/VanGoghDev/practicum-metrics/internal/util/glob"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// keepAlive период отправки служебных сообщений, не дающих прокси закрыть простаивающее соединение.
+const keepAlive = 15 * time.Second
+
+const writeTimeout = 10 * time.Second
+
+var upgrader = websocket.Upgrader{}
+
+// StreamHandler отдает поток принятых записей метрик: GET /api/v1/stream?match=&type=.
+// По умолчанию используется Server-Sent Events, запрос с заголовком Upgrade переводится на WebSocket.
+// Каждое событие - метрика в JSON; если клиент не успевает читать, обновления одной серии схлопываются.
+func StreamHandler(zlog *zap.SugaredLogger, hub *pubsub.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		match := r.URL.Query().Get("match")
+		mType := r.URL.Query().Get("type")
+		if mType != "" {
+			if apiErr := handlers.ValidateReadType(mType, ""); apiErr != nil {
+				w.Header().Set("Content-Type", "application/json")
+				handlers.WriteError(w, apiErr)
+				return
+			}
+		}
+
+		sub := hub.Subscribe(func(m *models.Metrics) bool {
+			return (mType == "" || m.MType == mType) && (match == "" || glob.Match(match, m.ID))
+		})
+		defer hub.Unsubscribe(sub)
+
+		if websocket.IsWebSocketUpgrade(r) {
+			serveWebSocket(zlog, sub, w, r)
+			return
+		}
+		serveSSE(zlog, sub, w, r)
+	}
+}
+
+func serveSSE(zlog *zap.SugaredLogger, sub *pubsub.Subscriber, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		zlog.Warnf("streaming is not supported: %v", err)
+		return
+	}
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.Dropped():
+			zlog.Warnf("stream consumer %s is too slow, dropping", r.RemoteAddr)
+			_, _ = fmt.Fprint(w, "event: dropped\ndata: {}\n\n")
+			_ = rc.Flush()
+			return
+		case <-ticker.C:
+			_, err := fmt.Fprint(w, ": ping\n\n")
+			if err == nil {
+				err = rc.Flush()
+			}
+			if err != nil {
+				return
+			}
+		case <-sub.Updates():
+			for _, m := range sub.Next() {
+				data, err := json.Marshal(m)
+				if err != nil {
+					zlog.Warnf("error encoding metric %s: %v", m.ID, err)
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "event: metric\ndata: %s\n\n", data); err != nil {
+					return
+				}
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func serveWebSocket(zlog *zap.SugaredLogger, sub *pubsub.Subscriber, w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		zlog.Warnf("failed to upgrade connection: %v", err)
+		return
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			zlog.Debugf("failed to close websocket: %v", err)
+		}
+	}()
+
+	// клиент ничего не присылает, но чтение нужно для обработки control фреймов и закрытия соединения.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-sub.Dropped():
+			zlog.Warnf("stream consumer %s is too slow, dropping", r.RemoteAddr)
+			msg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "consumer is too slow")
+			_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeTimeout))
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				return
+			}
+		case <-sub.Updates():
+			for _, m := range sub.Next() {
+				_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+				if err := conn.WriteJSON(m); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
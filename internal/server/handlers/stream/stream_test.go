@@ -0,0 +1,105 @@
+package stream_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/logger"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/pubsub"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers/chirouter"
+	"github.com/VanGoghDev/practicum-metrics/internal/storage/memstorage"
+)
+
+// newServer поднимает сервер; обработчик потока подписывается до отправки заголовков ответа,
+// поэтому записи, сделанные после установки соединения, гарантированно дойдут до клиента.
+func newServer(t *testing.T) (*httptest.Server, *pubsub.Hub) {
+	t.Helper()
+	log, _ := logger.New("Info")
+	memstrg, _ := memstorage.New(log)
+	hub := pubsub.NewHub(pubsub.DefaultMaxPending)
+	r, err := chirouter.BuildRouter(memstrg, log, &config.Config{}, chirouter.WithHub(hub))
+	require.NoError(t, err)
+	return httptest.NewServer(r), hub
+}
+
+func TestSSE(t *testing.T) {
+	srv, _ := newServer(t)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/v1/stream?match=Heap*", http.NoBody)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	_, err = resty.New().R().
+		SetBody(`[{"id": "Alloc", "type": "gauge", "value": 1}, {"id": "HeapAlloc", "type": "gauge", "value": 2}]`).
+		Post(srv.URL + "/updates/")
+	require.NoError(t, err)
+
+	sc := bufio.NewScanner(resp.Body)
+	var data string
+	for sc.Scan() {
+		if v, ok := strings.CutPrefix(sc.Text(), "data: "); ok {
+			data = v
+			break
+		}
+	}
+	require.NoError(t, sc.Err())
+
+	var m models.Metrics
+	require.NoError(t, json.Unmarshal([]byte(data), &m))
+	assert.Equal(t, "HeapAlloc", m.ID)
+	require.NotNil(t, m.Value)
+	assert.InDelta(t, 2.0, *m.Value, 0)
+}
+
+func TestWebSocket(t *testing.T) {
+	srv, _ := newServer(t)
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/v1/stream?type=counter"
+	conn, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Accept-Encoding": {"gzip"}})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	defer conn.Close()
+
+	_, err = resty.New().R().Post(srv.URL + "/update/gauge/Alloc/1")
+	require.NoError(t, err)
+	_, err = resty.New().R().Post(srv.URL + "/update/counter/PollCount/5")
+	require.NoError(t, err)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	var m models.Metrics
+	require.NoError(t, conn.ReadJSON(&m))
+	assert.Equal(t, "PollCount", m.ID)
+	require.NotNil(t, m.Delta)
+	assert.Equal(t, int64(5), *m.Delta)
+}
+
+func TestInvalidType(t *testing.T) {
+	srv, _ := newServer(t)
+	defer srv.Close()
+
+	resp, err := resty.New().R().Get(srv.URL + "/api/v1/stream?type=summary")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+}
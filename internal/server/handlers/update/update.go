@@ -2,74 +2,131 @@ package update
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cardinality"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/naming"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/routers"
 	"github.com/go-chi/chi"
 	"go.uber.org/zap"
 )
 
-const (
-	internalErrMsg = "Internal error"
-)
-
-func UpdateHandler(zlog *zap.SugaredLogger, storage routers.Storage) http.HandlerFunc {
+func UpdateHandler(
+	zlog *zap.SugaredLogger,
+	storage routers.Storage,
+	limiter *cardinality.Limiter,
+	policy *naming.Policy,
+) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		req := &models.Metrics{}
 
 		dec := json.NewDecoder(r.Body)
 		if err := dec.Decode(&req); err != nil {
-			zlog.Warnf("failed to decode JSON body", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			zlog.Warnf("failed to decode JSON body: %v", err)
+			handlers.WriteError(w, handlers.NewError(http.StatusBadRequest, handlers.CodeInvalidRequest,
+				fmt.Sprintf("failed to decode JSON body: %v", err), ""))
 			return
 		}
 
-		if req.MType == "" || (req.MType != handlers.Gauge && req.MType != handlers.Counter) {
-			http.Error(w, "Invalid metric type", http.StatusBadRequest)
-			return
-		}
+		saveMetric(zlog, storage, limiter, policy, w, r, req)
+	}
+}
 
-		if req.ID == "" {
-			http.Error(w, "Invalid metric name", http.StatusNotFound)
+// PutHandler записывает метрику, тип и имя которой переданы в пути запроса,
+// а значение - в JSON теле: PUT /api/v1/metrics/{type}/{name}.
+func PutHandler(
+	zlog *zap.SugaredLogger,
+	storage routers.Storage,
+	limiter *cardinality.Limiter,
+	policy *naming.Policy,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		req := &models.Metrics{}
+
+		dec := json.NewDecoder(r.Body)
+		if err := dec.Decode(&req); err != nil {
+			zlog.Warnf("failed to decode JSON body: %v", err)
+			handlers.WriteError(w, handlers.NewError(http.StatusBadRequest, handlers.CodeInvalidRequest,
+				fmt.Sprintf("failed to decode JSON body: %v", err), ""))
 			return
 		}
+		req.MType = chi.URLParam(r, "type")
+		req.ID = chi.URLParam(r, "name")
 
-		switch req.MType {
-		case handlers.Gauge:
-			err := storage.SaveGauge(r.Context(), req.ID, *req.Value)
-			if err != nil {
-				zlog.Warnf("failed to save gauge: %v", err) // переделать лог
-				http.Error(w, internalErrMsg, http.StatusInternalServerError)
-				return
-			}
-		case handlers.Counter:
-			err := storage.SaveCount(r.Context(), req.ID, *req.Delta)
-			if err != nil {
-				zlog.Warnf("failed to save counter: %v", err) // переделать лог
-				http.Error(w, internalErrMsg, http.StatusInternalServerError)
-				return
-			}
-		}
+		saveMetric(zlog, storage, limiter, policy, w, r, req)
+	}
+}
 
-		resp := models.Metrics{
-			ID:    req.ID,
-			Value: req.Value,
-			Delta: req.Delta,
-			MType: req.MType,
+// saveMetric проверяет и сохраняет метрику, после чего отвечает клиенту сохраненной метрикой в JSON.
+func saveMetric(
+	zlog *zap.SugaredLogger,
+	storage routers.Storage,
+	limiter *cardinality.Limiter,
+	policy *naming.Policy,
+	w http.ResponseWriter,
+	r *http.Request,
+	req *models.Metrics,
+) {
+	if apiErr := handlers.ValidateMetric(req); apiErr != nil {
+		handlers.WriteError(w, apiErr)
+		return
+	}
+
+	id, apiErr := handlers.AdmitName(policy, req.ID)
+	if apiErr != nil {
+		handlers.WriteError(w, apiErr)
+		return
+	}
+	req.ID = id
+
+	key := models.MetricKey{ID: req.ID, MType: req.MType}
+	if !handlers.Admit(zlog, limiter, w, r, key) {
+		return
+	}
+
+	switch req.MType {
+	case handlers.Gauge:
+		err := storage.SaveGauge(r.Context(), req.ID, *req.Value)
+		if err != nil {
+			zlog.Warnf("failed to save gauge %s: %v", req.ID, err)
+			handlers.WriteError(w, handlers.InternalError(req.ID))
+			return
 		}
-		enc := json.NewEncoder(w)
-		if err := enc.Encode(resp); err != nil {
-			zlog.Warnf("error encoding response %v", err)
+	case handlers.Counter:
+		err := storage.SaveCount(r.Context(), req.ID, *req.Delta)
+		if err != nil {
+			zlog.Warnf("failed to save counter %s: %v", req.ID, err)
+			handlers.WriteError(w, handlers.InternalError(req.ID))
 			return
 		}
 	}
+	handlers.Commit(limiter, r, key)
+
+	resp := models.Metrics{
+		ID:    req.ID,
+		Value: req.Value,
+		Delta: req.Delta,
+		MType: req.MType,
+	}
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(resp); err != nil {
+		zlog.Warnf("error encoding response %v", err)
+		return
+	}
 }
 
-func UpdateHandlerRouteParams(zlog *zap.SugaredLogger, storage routers.Storage) http.HandlerFunc {
+func UpdateHandlerRouteParams(
+	zlog *zap.SugaredLogger,
+	storage routers.Storage,
+	limiter *cardinality.Limiter,
+	policy *naming.Policy,
+) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 
@@ -78,42 +135,62 @@ func UpdateHandlerRouteParams(zlog *zap.SugaredLogger, storage routers.Storage)
 		mName := chi.URLParam(r, "name")
 		mVal := chi.URLParam(r, "value")
 
-		if mType == "" || (mType != handlers.Gauge && mType != handlers.Counter) {
-			http.Error(w, "Invalid metric type", http.StatusBadRequest)
+		if apiErr := handlers.ValidateType(mType, mName); apiErr != nil {
+			handlers.WriteError(w, apiErr)
 			return
 		}
 
 		if mName == "" {
-			http.Error(w, "Invalid metric name", http.StatusNotFound)
+			handlers.WriteError(w, handlers.NewError(http.StatusNotFound, handlers.CodeInvalidName,
+				"metric name is empty", ""))
+			return
+		}
+
+		mName, apiErr := handlers.AdmitName(policy, mName)
+		if apiErr != nil {
+			handlers.WriteError(w, apiErr)
 			return
 		}
 
+		invalidValue := handlers.NewError(http.StatusBadRequest, handlers.CodeInvalidValue,
+			fmt.Sprintf("metric %q: invalid value %q", mName, mVal), mName)
+
 		if mType == handlers.Gauge {
-			if val, err := strconv.ParseFloat(mVal, 64); err == nil {
-				err := storage.SaveGauge(r.Context(), mName, val)
-				if err != nil {
-					zlog.Warnf("failed to save gauge: %v", err)
-					http.Error(w, internalErrMsg, http.StatusInternalServerError)
-					return
-				}
-			} else {
-				http.Error(w, "Invalid metric value", http.StatusBadRequest)
+			val, err := strconv.ParseFloat(mVal, 64)
+			if err != nil {
+				handlers.WriteError(w, invalidValue)
 				return
 			}
+			key := models.MetricKey{ID: mName, MType: mType}
+			if !handlers.Admit(zlog, limiter, w, r, key) {
+				return
+			}
+			err = storage.SaveGauge(r.Context(), mName, val)
+			if err != nil {
+				zlog.Warnf("failed to save gauge %s: %v", mName, err)
+				handlers.WriteError(w, handlers.InternalError(mName))
+				return
+			}
+			handlers.Commit(limiter, r, key)
 		}
 
 		if mType == handlers.Counter {
-			if val, err := strconv.ParseInt(mVal, 0, 64); err == nil {
-				err := storage.SaveCount(r.Context(), mName, val)
-				if err != nil {
-					zlog.Warnf("failed to save counter: %v", err)
-					http.Error(w, "Internal error", http.StatusInternalServerError)
-					return
-				}
-			} else {
-				http.Error(w, "Invalid metric value", http.StatusBadRequest)
+			val, err := strconv.ParseInt(mVal, 0, 64)
+			if err != nil {
+				handlers.WriteError(w, invalidValue)
+				return
+			}
+			key := models.MetricKey{ID: mName, MType: mType}
+			if !handlers.Admit(zlog, limiter, w, r, key) {
+				return
+			}
+			err = storage.SaveCount(r.Context(), mName, val)
+			if err != nil {
+				zlog.Warnf("failed to save counter %s: %v", mName, err)
+				handlers.WriteError(w, handlers.InternalError(mName))
 				return
 			}
+			handlers.Commit(limiter, r, key)
 		}
 	}
 }
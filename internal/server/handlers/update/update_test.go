@@ -7,13 +7,16 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/logger"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/routers/chirouter"
 	"github.com/VanGoghDev/practicum-metrics/internal/storage/memstorage"
+	"github.com/VanGoghDev/practicum-metrics/internal/util/codec"
 	"github.com/go-resty/resty/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -87,6 +90,19 @@ func TestUpdateHandler(t *testing.T) {
 				statusCode:  http.StatusBadRequest,
 			},
 		},
+		{
+			name:    "Invalid metric name characters",
+			request: "update/{type}/{name}/{value}",
+			params: map[string]string{
+				"type":  "gauge",
+				"name":  "te st",
+				"value": "1",
+			},
+			want: want{
+				contentType: "text/plain; charset=utf-8",
+				statusCode:  http.StatusBadRequest,
+			},
+		},
 		{
 			name:    "Invalid url path",
 			request: "update/{type}",
@@ -106,7 +122,8 @@ func TestUpdateHandler(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			log, _ := logger.New("Info")
 			s, _ := memstorage.New(log)
-			r := chirouter.BuildRouter(s, log, &config.Config{})
+			r, err := chirouter.BuildRouter(s, log, &config.Config{})
+			require.NoError(t, err)
 			srv := httptest.NewServer(r)
 			defer srv.Close()
 
@@ -130,7 +147,8 @@ func TestGzipCompression(t *testing.T) {
 	s.GaugesM = map[string]float64{
 		"Alloc": 2.0,
 	}
-	r := chirouter.BuildRouter(s, log, &config.Config{})
+	r, err := chirouter.BuildRouter(s, log, &config.Config{})
+	require.NoError(t, err)
 	srv := httptest.NewServer(r)
 	defer srv.Close()
 
@@ -198,3 +216,228 @@ func TestGzipCompression(t *testing.T) {
 		require.JSONEq(t, successBody, string(b))
 	})
 }
+
+func TestJSONErrors(t *testing.T) {
+	type want struct {
+		statusCode int
+		code       string
+		id         string
+	}
+	tests := []struct {
+		name string
+		path string
+		body string
+		want want
+	}{
+		{
+			name: "malformed body",
+			path: "/update",
+			body: `{"id": "Alloc",`,
+			want: want{statusCode: http.StatusBadRequest, code: handlers.CodeInvalidRequest},
+		},
+		{
+			name: "invalid type",
+			path: "/update",
+			body: `{"id": "Alloc", "type": "histogram", "value": 1}`,
+			want: want{statusCode: http.StatusBadRequest, code: handlers.CodeInvalidType, id: "Alloc"},
+		},
+		{
+			name: "missing value",
+			path: "/update",
+			body: `{"id": "Alloc", "type": "gauge"}`,
+			want: want{statusCode: http.StatusBadRequest, code: handlers.CodeInvalidValue, id: "Alloc"},
+		},
+		{
+			name: "invalid name in batch",
+			path: "/updates",
+			body: `[{"id": "Alloc", "type": "gauge", "value": 1}, {"id": "poll count", "type": "counter", "delta": 1}]`,
+			want: want{statusCode: http.StatusBadRequest, code: handlers.CodeInvalidName, id: "poll count"},
+		},
+		{
+			name: "labels in client name",
+			path: "/update",
+			body: `{"id": "Alloc{host=\"a\"}", "type": "gauge", "value": 1}`,
+			want: want{statusCode: http.StatusBadRequest, code: handlers.CodeInvalidName, id: `Alloc{host="a"}`},
+		},
+		{
+			name: "name too long",
+			path: "/update",
+			body: `{"id": "` + strings.Repeat("a", 33) + `", "type": "gauge", "value": 1}`,
+			want: want{statusCode: http.StatusUnprocessableEntity, code: handlers.CodeInvalidName,
+				id: strings.Repeat("a", 33)},
+		},
+		{
+			name: "not found",
+			path: "/value",
+			body: `{"id": "Alloc", "type": "gauge"}`,
+			want: want{statusCode: http.StatusNotFound, code: handlers.CodeNotFound, id: "Alloc"},
+		},
+	}
+
+	log, _ := logger.New("Info")
+	s, _ := memstorage.New(log)
+	r, err := chirouter.BuildRouter(s, log, &config.Config{MaxNameLength: 32})
+	require.NoError(t, err)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var apiErr handlers.Error
+			resp, err := resty.New().R().
+				SetHeader("Content-Type", "application/json").
+				SetBody(tt.body).
+				SetError(&apiErr).
+				Post(srv.URL + tt.path)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.statusCode, resp.StatusCode())
+			assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+			assert.Equal(t, tt.want.code, apiErr.Code)
+			assert.Equal(t, tt.want.id, apiErr.ID)
+			assert.NotEmpty(t, apiErr.Message)
+		})
+	}
+
+	// отказы политики именования учитываются в /limits.
+	var stats struct {
+		RejectedInvalidName int64 `json:"rejected_invalid_name"`
+		RejectedNameTooLong int64 `json:"rejected_name_too_long"`
+		RejectedWritesTotal int64 `json:"rejected_writes_total"`
+	}
+	resp, err := resty.New().R().SetResult(&stats).Get(srv.URL + "/limits/")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, int64(2), stats.RejectedInvalidName)
+	assert.Equal(t, int64(1), stats.RejectedNameTooLong)
+	assert.Equal(t, int64(3), stats.RejectedWritesTotal)
+}
+
+func TestContentNegotiation(t *testing.T) {
+	log, _ := logger.New("Info")
+	s, _ := memstorage.New(log)
+	r, err := chirouter.BuildRouter(s, log, &config.Config{})
+	require.NoError(t, err)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	alloc := 2.5
+	tests := []struct {
+		reqCodec  codec.Codec
+		respCodec codec.Codec
+		name      string
+		accept    string
+		delta     int64
+	}{
+		{name: "json", reqCodec: codec.JSON, respCodec: codec.JSON, delta: 1},
+		{name: "protobuf", reqCodec: codec.Protobuf, respCodec: codec.Protobuf, delta: 2},
+		{name: "msgpack", reqCodec: codec.Msgpack, respCodec: codec.Msgpack, delta: 3},
+		{name: "protobuf request, msgpack response", reqCodec: codec.Protobuf, respCodec: codec.Msgpack,
+			accept: "application/msgpack", delta: 4},
+	}
+
+	var total int64
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delta := tt.delta
+			body, err := tt.reqCodec.MarshalMetrics([]*models.Metrics{
+				{ID: "Alloc", MType: "gauge", Value: &alloc},
+				{ID: "PollCount", MType: "counter", Delta: &delta},
+			})
+			require.NoError(t, err)
+			resp, err := resty.New().R().
+				SetHeader("Content-Type", tt.reqCodec.ContentType()).
+				SetBody(body).
+				Post(srv.URL + "/updates/")
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, resp.StatusCode(), resp.String())
+			total += tt.delta
+
+			body, err = tt.reqCodec.MarshalMetric(&models.Metrics{ID: "PollCount", MType: "counter"})
+			require.NoError(t, err)
+			resp, err = resty.New().R().
+				SetHeader("Content-Type", tt.reqCodec.ContentType()).
+				SetHeader("Accept", tt.accept).
+				SetBody(body).
+				Post(srv.URL + "/value/")
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, resp.StatusCode(), resp.String())
+			assert.Equal(t, tt.respCodec.ContentType(), resp.Header().Get("Content-Type"))
+
+			m, err := tt.respCodec.UnmarshalMetric(resp.Body())
+			require.NoError(t, err)
+			assert.Equal(t, total, *m.Delta)
+		})
+	}
+}
+
+func TestRequestLimits(t *testing.T) {
+	log, _ := logger.New("Info")
+	s, _ := memstorage.New(log)
+	r, err := chirouter.BuildRouter(s, log, &config.Config{
+		MaxBodySize:         1 << 10,
+		MaxDecompressedSize: 4 << 10,
+		MaxBatchSize:        3,
+	})
+	require.NoError(t, err)
+
+	batch := func(n int) []byte {
+		metrics := make([]string, n)
+		for i := range metrics {
+			metrics[i] = fmt.Sprintf(`{"id":"m%d","type":"counter","delta":1}`, i)
+		}
+		return []byte("[" + strings.Join(metrics, ",") + "]")
+	}
+	gz := func(data []byte) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, err := zw.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		return buf.Bytes()
+	}
+	padded := append([]byte(`[{"id":"Alloc","type":"gauge","value":1}]`), bytes.Repeat([]byte(" "), 2<<10)...)
+
+	tests := []struct {
+		name      string
+		body      []byte
+		gzip      bool
+		chunked   bool
+		status    int
+		wantSaved bool
+	}{
+		{name: "batch at limit", body: batch(3), status: http.StatusOK, wantSaved: true},
+		{name: "batch over limit", body: batch(4), status: http.StatusRequestEntityTooLarge},
+		{name: "body over limit", body: padded, status: http.StatusRequestEntityTooLarge},
+		{name: "chunked body over limit", body: padded, chunked: true, status: http.StatusRequestEntityTooLarge},
+		{name: "compressed body under both limits", body: padded, gzip: true, status: http.StatusOK, wantSaved: true},
+		{name: "gzip bomb", body: make([]byte, 1<<20), gzip: true, status: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.CountersM = map[string]int64{}
+			s.GaugesM = map[string]float64{}
+
+			body := tt.body
+			if tt.gzip {
+				body = gz(body)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body))
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			if tt.gzip {
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			if tt.status == http.StatusRequestEntityTooLarge {
+				assert.Contains(t, w.Body.String(), handlers.CodeTooLarge)
+			}
+			assert.Equal(t, tt.wantSaved, len(s.CountersM)+len(s.GaugesM) > 0)
+		})
+	}
+}
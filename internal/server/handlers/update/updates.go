@@ -1,29 +1,82 @@
 package update
 
 import (
-	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 
-	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cardinality"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/naming"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/routers"
+	"github.com/VanGoghDev/practicum-metrics/internal/util/codec"
 	"go.uber.org/zap"
 )
 
-func UpdatesHandler(zlog *zap.SugaredLogger, storage routers.Storage) http.HandlerFunc {
+// defaultMaxBatchSize количество метрик в батче по умолчанию, если лимит не задан.
+const defaultMaxBatchSize = 10000
+
+// UpdatesHandler записывает батч метрик. Формат тела определяется по Content-Type:
+// application/x-protobuf, application/msgpack, иначе JSON.
+// Батч больше maxBatch метрик отклоняется целиком с 413.
+func UpdatesHandler(
+	zlog *zap.SugaredLogger,
+	storage routers.Storage,
+	limiter *cardinality.Limiter,
+	policy *naming.Policy,
+	maxBatch int64,
+) http.HandlerFunc {
+	if maxBatch <= 0 {
+		maxBatch = defaultMaxBatchSize
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		metrics := []*models.Metrics{}
-		dec := json.NewDecoder(r.Body)
-		if err := dec.Decode(&metrics); err != nil {
-			zlog.Warnf("failed to decode JSON body", err)
-			http.Error(w, "Internal error", http.StatusInternalServerError)
+		c := codec.ForContentType(r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			zlog.Warnf("failed to read request body: %v", err)
+			handlers.WriteError(w, handlers.NewError(http.StatusBadRequest, handlers.CodeInvalidRequest,
+				fmt.Sprintf("failed to read body: %v", err), ""))
+			return
+		}
+		metrics, err := c.UnmarshalMetrics(body)
+		if err != nil {
+			zlog.Warnf("failed to decode %s body: %v", c.Name(), err)
+			handlers.WriteError(w, handlers.NewError(http.StatusBadRequest, handlers.CodeInvalidRequest,
+				fmt.Sprintf("failed to decode %s body: %v", c.Name(), err), ""))
 			return
 		}
-		err := storage.SaveMetrics(r.Context(), metrics)
+		if int64(len(metrics)) > maxBatch {
+			zlog.Warnf("rejected batch of %d metrics: limit is %d", len(metrics), maxBatch)
+			handlers.WriteError(w, handlers.NewError(http.StatusRequestEntityTooLarge, handlers.CodeTooLarge,
+				fmt.Sprintf("batch of %d metrics exceeds %d metrics", len(metrics), maxBatch), ""))
+			return
+		}
+
+		for _, m := range metrics {
+			if apiErr := handlers.ValidateMetric(m); apiErr != nil {
+				handlers.WriteError(w, apiErr)
+				return
+			}
+			id, apiErr := handlers.AdmitName(policy, m.ID)
+			if apiErr != nil {
+				handlers.WriteError(w, apiErr)
+				return
+			}
+			m.ID = id
+		}
+		keys := cardinality.Keys(metrics)
+		if !handlers.Admit(zlog, limiter, w, r, keys...) {
+			return
+		}
+
+		err = storage.SaveMetrics(r.Context(), metrics)
 		if err != nil {
 			zlog.Warnf("failed to save metrics: %v", err)
-			http.Error(w, "Internal error", http.StatusInternalServerError)
+			handlers.WriteError(w, handlers.InternalError(""))
 			return
 		}
+		handlers.Commit(limiter, r, keys...)
 	}
 }
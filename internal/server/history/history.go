@@ -0,0 +1,159 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultInterval = 10 * time.Second
+	defaultSize     = 60
+)
+
+// Point значение метрики в момент времени.
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Recorder периодически снимает значения всех метрик из хранилища
+// и хранит последние Size значений каждой серии в кольцевом буфере.
+type Recorder struct {
+	zlog     *zap.SugaredLogger
+	storage  routers.Storage
+	series   map[models.MetricKey]*ring
+	interval time.Duration
+	size     int
+	mu       sync.RWMutex
+}
+
+func New(zlog *zap.SugaredLogger, cfg *config.Config, s routers.Storage) *Recorder {
+	interval := cfg.HistoryInterval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	size := int(cfg.HistorySize)
+	if size <= 0 {
+		size = defaultSize
+	}
+	return &Recorder{
+		zlog:     zlog,
+		storage:  s,
+		series:   make(map[models.MetricKey]*ring),
+		interval: interval,
+		size:     size,
+	}
+}
+
+// Run снимает значения метрик с заданным интервалом, пока не будет отменен контекст.
+func (h *Recorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := h.Sample(ctx); err != nil {
+			h.zlog.Warnf("failed to record metrics history: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sample добавляет в историю текущие значения всех метрик.
+// Серии, которых больше нет в хранилище, удаляются из истории.
+func (h *Recorder) Sample(ctx context.Context) error {
+	metrics, err := h.storage.ListMetrics(ctx, &models.ListQuery{})
+	if err != nil {
+		return fmt.Errorf("failed to list metrics: %w", err)
+	}
+
+	now := time.Now()
+	seen := make(map[models.MetricKey]struct{}, len(metrics))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, m := range metrics {
+		key := models.MetricKey{ID: m.ID, MType: m.MType}
+		seen[key] = struct{}{}
+
+		var v float64
+		switch {
+		case m.Value != nil:
+			v = *m.Value
+		case m.Delta != nil:
+			v = float64(*m.Delta)
+		default:
+			continue
+		}
+
+		r, ok := h.series[key]
+		if !ok {
+			r = newRing(h.size)
+			h.series[key] = r
+		}
+		r.push(Point{Time: now, Value: v})
+	}
+
+	for key := range h.series {
+		if _, ok := seen[key]; !ok {
+			delete(h.series, key)
+		}
+	}
+	return nil
+}
+
+// Points возвращает сохраненные значения серии от старых к новым.
+func (h *Recorder) Points(key models.MetricKey) []Point {
+	if h == nil {
+		return nil
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	r, ok := h.series[key]
+	if !ok {
+		return nil
+	}
+	return r.points()
+}
+
+// ring кольцевой буфер фиксированного размера.
+type ring struct {
+	buf  []Point
+	next int
+	full bool
+}
+
+func newRing(size int) *ring {
+	return &ring{buf: make([]Point, size)}
+}
+
+func (r *ring) push(p Point) {
+	r.buf[r.next] = p
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *ring) points() []Point {
+	if !r.full {
+		return append([]Point(nil), r.buf[:r.next]...)
+	}
+	points := make([]Point, 0, len(r.buf))
+	points = append(points, r.buf[r.next:]...)
+	return append(points, r.buf[:r.next]...)
+}
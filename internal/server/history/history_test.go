@@ -0,0 +1,49 @@
+package history_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/history"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/logger"
+	"github.com/VanGoghDev/practicum-metrics/internal/storage/memstorage"
+)
+
+func TestRecorder(t *testing.T) {
+	log, _ := logger.New("Info")
+	memstrg, _ := memstorage.New(log)
+	memstrg.GaugesM = map[string]float64{"Alloc": 0}
+	memstrg.CountersM = map[string]int64{"PollCount": 0}
+	h := history.New(log.Sugar(), &config.Config{HistorySize: 3}, memstrg)
+
+	ctx := context.Background()
+	for i := 1; i <= 5; i++ {
+		memstrg.GaugesM["Alloc"] = float64(i)
+		memstrg.CountersM["PollCount"] = int64(i * 10)
+		require.NoError(t, h.Sample(ctx))
+	}
+
+	values := func(key models.MetricKey) []float64 {
+		res := make([]float64, 0)
+		for _, p := range h.Points(key) {
+			res = append(res, p.Value)
+		}
+		return res
+	}
+
+	gauge := models.MetricKey{ID: "Alloc", MType: "gauge"}
+	counter := models.MetricKey{ID: "PollCount", MType: "counter"}
+	assert.Equal(t, []float64{3, 4, 5}, values(gauge))
+	assert.Equal(t, []float64{30, 40, 50}, values(counter))
+	assert.Empty(t, values(models.MetricKey{ID: "Alloc", MType: "counter"}))
+
+	delete(memstrg.GaugesM, "Alloc")
+	require.NoError(t, h.Sample(ctx))
+	assert.Empty(t, values(gauge))
+	assert.Equal(t, []float64{40, 50, 50}, values(counter))
+}
@@ -0,0 +1,144 @@
+// Package keyring хранит ключи подписи запросов агентов.
+package keyring
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidKey = errors.New("invalid signature key")
+	ErrNoKeys     = errors.New("no signature keys")
+)
+
+// Ring набор действующих ключей подписи, каждый со своим идентификатором.
+// Агент передает идентификатор ключа в заголовке X-Signature-Key-Id, поэтому
+// на время ротации сервер может принимать и старый, и новый ключ.
+// Ключ из cfg.Key используется для запросов без идентификатора.
+type Ring struct {
+	keys     map[string][]byte
+	path     string
+	fallback []byte
+	mu       sync.RWMutex
+}
+
+// New создает набор из cfg.Key и ключей из файла cfg.KeysFile, если он задан.
+func New(cfg *config.Config) (*Ring, error) {
+	r := &Ring{
+		keys: make(map[string][]byte),
+		path: cfg.KeysFile,
+	}
+	if cfg.Key != "" {
+		r.fallback = []byte(cfg.Key)
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Parse читает ключи, по одному в строке: <идентификатор> <ключ>.
+// Пустые строки и строки, начинающиеся с #, пропускаются.
+func Parse(r io.Reader) (map[string][]byte, error) {
+	keys := make(map[string][]byte)
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%w on line %d: expected key id and key", ErrInvalidKey, n)
+		}
+		if _, ok := keys[fields[0]]; ok {
+			return nil, fmt.Errorf("%w on line %d: duplicate key id %q", ErrInvalidKey, n, fields[0])
+		}
+		keys[fields[0]] = []byte(fields[1])
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read signature keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Reload перечитывает файл ключей. При ошибке продолжают действовать прежние ключи.
+// Файл без ключей (пустой или только с комментариями) считается ошибкой: иначе при пустом cfg.Key
+// набор стал бы пустым и подпись перестала бы проверяться.
+func (r *Ring) Reload() error {
+	if r.path == "" {
+		return nil
+	}
+
+	f, err := os.Open(r.path)
+	if err != nil {
+		return fmt.Errorf("failed to open signature keys: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	keys, err := Parse(f)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("%w in %s", ErrNoKeys, r.path)
+	}
+
+	r.mu.Lock()
+	r.keys = keys
+	r.mu.Unlock()
+	return nil
+}
+
+// Key возвращает ключ с идентификатором id, пустой id - ключ cfg.Key.
+func (r *Ring) Key(id string) ([]byte, bool) {
+	if id == "" {
+		return r.fallback, r.fallback != nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key, ok := r.keys[id]
+	return key, ok
+}
+
+// Empty сообщает, что ни одного ключа не задано и подпись проверять не нужно.
+func (r *Ring) Empty() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.fallback == nil && len(r.keys) == 0
+}
+
+// WatchSIGHUP перечитывает файл ключей при получении SIGHUP до отмены контекста.
+func (r *Ring) WatchSIGHUP(ctx context.Context, zlog *zap.SugaredLogger) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	defer signal.Stop(sig)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sig:
+			if err := r.Reload(); err != nil {
+				zlog.Errorf("failed to reload signature keys: %v", err)
+				continue
+			}
+			zlog.Infof("signature keys reloaded from %s", r.path)
+		}
+	}
+}
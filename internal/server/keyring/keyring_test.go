@@ -0,0 +1,104 @@
+package keyring
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		want    map[string][]byte
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{
+			name:  "keys and comments",
+			input: "# rotated 2024-06\nv1 old-secret\n\n  v2   new-secret  \n",
+			want:  map[string][]byte{"v1": []byte("old-secret"), "v2": []byte("new-secret")},
+		},
+		{
+			name:    "missing key",
+			input:   "v1\n",
+			wantErr: true,
+		},
+		{
+			name:    "duplicate id",
+			input:   "v1 a\nv1 b\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.input))
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidKey)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRing_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(path, []byte("v1 old-secret\n"), 0o600))
+
+	r, err := New(&config.Config{KeysFile: path})
+	require.NoError(t, err)
+	assert.False(t, r.Empty())
+	_, ok := r.Key("")
+	assert.False(t, ok)
+
+	require.NoError(t, os.WriteFile(path, []byte("v2 new-secret\n"), 0o600))
+	require.NoError(t, r.Reload())
+	_, ok = r.Key("v1")
+	assert.False(t, ok)
+	key, ok := r.Key("v2")
+	require.True(t, ok)
+	assert.Equal(t, []byte("new-secret"), key)
+
+	// некорректный файл не сбрасывает действующие ключи.
+	require.NoError(t, os.WriteFile(path, []byte("broken\n"), 0o600))
+	require.Error(t, r.Reload())
+	_, ok = r.Key("v2")
+	assert.True(t, ok)
+}
+
+func TestRing_ReloadWithoutKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(path, []byte("v1 old-secret\n"), 0o600))
+	r, err := New(&config.Config{KeysFile: path})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "empty file", input: ""},
+		{name: "only comments", input: "# v1 old-secret\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(path, []byte(tt.input), 0o600))
+			require.ErrorIs(t, r.Reload(), ErrNoKeys)
+			// прежние ключи продолжают действовать, подпись по-прежнему проверяется.
+			assert.False(t, r.Empty())
+			_, ok := r.Key("v1")
+			assert.True(t, ok)
+
+			_, err := New(&config.Config{KeysFile: path})
+			require.ErrorIs(t, err, ErrNoKeys)
+		})
+	}
+}
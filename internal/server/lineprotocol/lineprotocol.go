@@ -0,0 +1,169 @@
+package lineprotocol
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Типы значений полей.
+const (
+	Float FieldKind = iota
+	Integer
+	Unsigned
+	Boolean
+	String
+)
+
+var ErrInvalidLine = errors.New("invalid line protocol")
+
+// FieldKind тип значения поля.
+type FieldKind int
+
+// Field поле точки. Числовые и логические значения хранятся в Value (true - 1, false - 0),
+// строковые - в Str.
+type Field struct {
+	Key   string
+	Str   string
+	Value float64
+	Kind  FieldKind
+}
+
+// Point точка InfluxDB: measurement[,tag=value...] field=value[,field=value...] [timestamp].
+// Время точки не хранится, поэтому timestamp проверяется, но не возвращается.
+type Point struct {
+	Tags        map[string]string
+	Measurement string
+	Fields      []Field
+}
+
+// Parse разбирает одну строку line protocol.
+func Parse(line string) (*Point, error) {
+	sections := splitUnescaped(line, ' ', true)
+	if len(sections) < 2 || len(sections) > 3 {
+		return nil, fmt.Errorf("%w: expected measurement, fields and optional timestamp", ErrInvalidLine)
+	}
+
+	p := &Point{}
+	keys := splitUnescaped(sections[0], ',', false)
+	p.Measurement = unescape(keys[0])
+	if p.Measurement == "" {
+		return nil, fmt.Errorf("%w: empty measurement", ErrInvalidLine)
+	}
+	for _, tag := range keys[1:] {
+		k, v, ok := cutUnescaped(tag, '=')
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("%w: invalid tag %q", ErrInvalidLine, tag)
+		}
+		if p.Tags == nil {
+			p.Tags = make(map[string]string)
+		}
+		p.Tags[unescape(k)] = unescape(v)
+	}
+
+	for _, field := range splitUnescaped(sections[1], ',', true) {
+		k, v, ok := cutUnescaped(field, '=')
+		if !ok || k == "" {
+			return nil, fmt.Errorf("%w: invalid field %q", ErrInvalidLine, field)
+		}
+		f, err := parseValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: field %q: %w", ErrInvalidLine, unescape(k), err)
+		}
+		f.Key = unescape(k)
+		p.Fields = append(p.Fields, f)
+	}
+
+	if len(sections) == 3 {
+		if _, err := strconv.ParseInt(sections[2], 10, 64); err != nil {
+			return nil, fmt.Errorf("%w: invalid timestamp %q", ErrInvalidLine, sections[2])
+		}
+	}
+
+	return p, nil
+}
+
+func parseValue(v string) (Field, error) {
+	switch {
+	case v == "":
+		return Field{}, errors.New("empty value")
+	case v[0] == '"':
+		if len(v) < 2 || v[len(v)-1] != '"' {
+			return Field{}, fmt.Errorf("unterminated string %s", v)
+		}
+		s := strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(v[1 : len(v)-1])
+		return Field{Kind: String, Str: s}, nil
+	case strings.HasSuffix(v, "i"):
+		i, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+		if err != nil {
+			return Field{}, fmt.Errorf("invalid integer %s", v)
+		}
+		return Field{Kind: Integer, Value: float64(i)}, nil
+	case strings.HasSuffix(v, "u"):
+		u, err := strconv.ParseUint(v[:len(v)-1], 10, 64)
+		if err != nil {
+			return Field{}, fmt.Errorf("invalid unsigned integer %s", v)
+		}
+		return Field{Kind: Unsigned, Value: float64(u)}, nil
+	}
+
+	switch v {
+	case "t", "T", "true", "True", "TRUE":
+		return Field{Kind: Boolean, Value: 1}, nil
+	case "f", "F", "false", "False", "FALSE":
+		return Field{Kind: Boolean, Value: 0}, nil
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return Field{}, fmt.Errorf("invalid float %s", v)
+	}
+	return Field{Kind: Float, Value: f}, nil
+}
+
+// splitUnescaped делит строку по разделителю, не предваренному обратной косой чертой.
+// Если quotes установлен, разделители внутри двойных кавычек не учитываются.
+// Пустые части, возникающие из-за повторяющихся разделителей, отбрасываются.
+func splitUnescaped(s string, sep byte, quotes bool) []string {
+	parts := make([]string, 0)
+	start := 0
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case quotes && s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == sep && !inQuotes:
+			if i > start {
+				parts = append(parts, s[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if start < len(s) {
+		parts = append(parts, s[start:])
+	}
+	return parts
+}
+
+// cutUnescaped делит строку по первому неэкранированному разделителю.
+func cutUnescaped(s string, sep byte) (before, after string, found bool) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+var unescaper = strings.NewReplacer(`\,`, `,`, `\ `, ` `, `\=`, `=`, `\\`, `\`)
+
+func unescape(s string) string {
+	return unescaper.Replace(s)
+}
@@ -0,0 +1,69 @@
+package lineprotocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		want    *Point
+		name    string
+		line    string
+		wantErr bool
+	}{
+		{
+			name: "float field",
+			line: "cpu usage_idle=92.5",
+			want: &Point{Measurement: "cpu", Fields: []Field{{Key: "usage_idle", Kind: Float, Value: 92.5}}},
+		},
+		{
+			name: "tags, typed fields and timestamp",
+			line: `mem,host=web1,region=eu used=1024i,free=12u,ok=true,name="a b" 1718000000000000000`,
+			want: &Point{
+				Measurement: "mem",
+				Tags:        map[string]string{"host": "web1", "region": "eu"},
+				Fields: []Field{
+					{Key: "used", Kind: Integer, Value: 1024},
+					{Key: "free", Kind: Unsigned, Value: 12},
+					{Key: "ok", Kind: Boolean, Value: 1},
+					{Key: "name", Kind: String, Str: "a b"},
+				},
+			},
+		},
+		{
+			name: "escaped characters",
+			line: `disk\ io,path=/var\,log,dev=x\=y read\ bytes=1,msg="say \"hi\", ok"`,
+			want: &Point{
+				Measurement: "disk io",
+				Tags:        map[string]string{"path": "/var,log", "dev": "x=y"},
+				Fields: []Field{
+					{Key: "read bytes", Kind: Float, Value: 1},
+					{Key: "msg", Kind: String, Str: `say "hi", ok`},
+				},
+			},
+		},
+		{name: "no fields", line: "cpu", wantErr: true},
+		{name: "invalid integer", line: "cpu a=1.5i", wantErr: true},
+		{name: "invalid float", line: "cpu a=abc", wantErr: true},
+		{name: "NaN", line: "cpu a=NaN", wantErr: true},
+		{name: "unterminated string", line: `cpu a="abc`, wantErr: true},
+		{name: "invalid timestamp", line: "cpu a=1 yesterday", wantErr: true},
+		{name: "empty tag value", line: "cpu,host= a=1", wantErr: true},
+		{name: "tag without value", line: `cpu,dev\=x a=1`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.line)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidLine)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package bodylimit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
+	"go.uber.org/zap"
+)
+
+// defaultMaxSize размер тела по умолчанию, если лимит не задан.
+const defaultMaxSize = 10 << 20
+
+// New ограничивает размер тела запроса в том виде, в котором оно пришло по сети,
+// то есть до расшифровки и распаковки. Тело больше maxSize отклоняется с 413.
+// Тело читается целиком, поэтому middleware должен стоять перед signature, decryptor и compressor.
+func New(zlog *zap.SugaredLogger, maxSize int64) func(next http.Handler) http.Handler {
+	if maxSize <= 0 {
+		maxSize = defaultMaxSize
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			reject := func() {
+				zlog.Warnf("rejected request %s %s: body exceeds %d bytes", r.Method, r.URL.Path, maxSize)
+				w.Header().Set("Content-Type", "application/json")
+				handlers.WriteError(w, handlers.NewError(http.StatusRequestEntityTooLarge, handlers.CodeTooLarge,
+					fmt.Sprintf("request body exceeds %d bytes", maxSize), ""))
+			}
+
+			// Content-Length проверяется сразу, без чтения тела.
+			if r.ContentLength > maxSize {
+				reject()
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxSize+1))
+			if err != nil {
+				zlog.Warnf("failed to read request body: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if int64(len(body)) > maxSize {
+				reject()
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -1,12 +1,16 @@
 package compressor
 
 import (
+	"bytes"
 	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
+	"github.com/golang/snappy"
 	"go.uber.org/zap"
 )
 
@@ -31,6 +35,16 @@ func (cw CompressWriter) Write(data []byte) (int, error) {
 	return code, nil
 }
 
+// Flush досылает клиенту уже сжатые данные, нужен для потоковых ответов.
+func (cw CompressWriter) Flush() {
+	if err := cw.zw.Flush(); err != nil {
+		return
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 // Close закрывает gzip.Writer и досылает все данные из буфера.
 func (cw *CompressWriter) Close() error {
 	err := cw.zw.Close()
@@ -58,11 +72,14 @@ func NewCompressReader(r io.ReadCloser) (*CompressReader, error) {
 
 // Read implements io.ReadCloser.
 func (c *CompressReader) Read(p []byte) (n int, err error) {
-	code, err := c.zr.Read(p)
+	n, err = c.zr.Read(p)
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("failed to read gzip.Reader: %w", err)
+		if errors.Is(err, io.EOF) {
+			return n, io.EOF
+		}
+		return n, fmt.Errorf("failed to read gzip.Reader: %w", err)
 	}
-	return code, nil
+	return n, nil
 }
 
 func (c *CompressReader) Close() error {
@@ -81,13 +98,67 @@ func (c *CompressReader) CloseGzRReader() error {
 	return nil
 }
 
-func New(zlog *zap.SugaredLogger) func(next http.Handler) http.Handler {
+// defaultMaxSize размер распакованного тела по умолчанию, если лимит не задан.
+const defaultMaxSize = 32 << 20
+
+// ErrTooLarge тело запроса после распаковки больше допустимого.
+var ErrTooLarge = errors.New("decompressed body is too large")
+
+// SnappyReader тело запроса, сжатое snappy в блочном формате (Prometheus remote write).
+type SnappyReader struct {
+	*bytes.Reader
+	r io.ReadCloser
+}
+
+// NewSnappyReader распаковывает тело, сжатое snappy. Формат блочный, поэтому тело
+// распаковывается целиком в память, а тело больше maxSize до или после распаковки отклоняется с ErrTooLarge.
+// Размер после распаковки записан в заголовке блока и проверяется до выделения памяти.
+func NewSnappyReader(r io.ReadCloser, maxSize int64) (*SnappyReader, error) {
+	compressed, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snappy body: %w", err)
+	}
+	if int64(len(compressed)) > maxSize {
+		return nil, ErrTooLarge
+	}
+
+	n, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode snappy body: %w", err)
+	}
+	if int64(n) > maxSize {
+		return nil, ErrTooLarge
+	}
+
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode snappy body: %w", err)
+	}
+	return &SnappyReader{Reader: bytes.NewReader(data), r: r}, nil
+}
+
+func (s *SnappyReader) Close() error {
+	if err := s.r.Close(); err != nil {
+		return fmt.Errorf("failed to close io.ReadCloser: %w", err)
+	}
+	return nil
+}
+
+// New распаковывает тела запросов, сжатые gzip или snappy, и сжимает ответы gzip, если клиент их принимает.
+// Тело распаковывается целиком до вызова обработчика, а тело больше maxSize после распаковки
+// отклоняется с 413, поэтому небольшой запрос не может занять распаковкой всю память сервера.
+func New(zlog *zap.SugaredLogger, maxSize int64) func(next http.Handler) http.Handler {
+	if maxSize <= 0 {
+		maxSize = defaultMaxSize
+	}
+
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			ow := w
 
 			// Если клиент поддерживает обработку сжатых ответов, то переопределим responseWriter.
-			if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			// Соединение, переключаемое на другой протокол (WebSocket), не сжимаем.
+			if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") && r.Header.Get("Upgrade") == "" {
 				cw := NewCompressWriter(w)
 				cw.ResponseWriter.Header().Set("Content-Encoding", "gzip")
 
@@ -101,6 +172,13 @@ func New(zlog *zap.SugaredLogger) func(next http.Handler) http.Handler {
 				}()
 			}
 
+			reject := func() {
+				zlog.Warnf("rejected request %s %s: decompressed body exceeds %d bytes", r.Method, r.URL.Path, maxSize)
+				ow.Header().Set("Content-Type", "application/json")
+				handlers.WriteError(ow, handlers.NewError(http.StatusRequestEntityTooLarge, handlers.CodeTooLarge,
+					fmt.Sprintf("decompressed request body exceeds %d bytes", maxSize), ""))
+			}
+
 			// Если данные пришли в сжатом формате, то заменим body после декомпрессии.
 			if strings.Contains(r.Header.Get("Content-Encoding"), "gzip") {
 				zlog.Debug("reading compressed body")
@@ -111,7 +189,6 @@ func New(zlog *zap.SugaredLogger) func(next http.Handler) http.Handler {
 					w.WriteHeader(http.StatusInternalServerError)
 					return
 				}
-				r.Body = cr
 				defer func() {
 					err = cr.Close()
 					if err != nil {
@@ -120,7 +197,38 @@ func New(zlog *zap.SugaredLogger) func(next http.Handler) http.Handler {
 						return
 					}
 				}()
+
+				// читаем не больше maxSize+1 байт, чтобы не распаковывать gzip бомбу целиком.
+				body, err := io.ReadAll(io.LimitReader(cr, maxSize+1))
+				if err != nil {
+					zlog.Warnf("failed to decompress gzip body: %v", err)
+					ow.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				if int64(len(body)) > maxSize {
+					reject()
+					return
+				}
+				r.Body = struct {
+					io.Reader
+					io.Closer
+				}{bytes.NewReader(body), cr}
 			}
+
+			if r.Header.Get("Content-Encoding") == "snappy" {
+				sr, err := NewSnappyReader(r.Body, maxSize)
+				if errors.Is(err, ErrTooLarge) {
+					reject()
+					return
+				}
+				if err != nil {
+					zlog.Warnf("failed to decompress snappy body: %v", err)
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				r.Body = sr
+			}
+
 			next.ServeHTTP(ow, r)
 		}
 
@@ -0,0 +1,91 @@
+package compressor_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/server/logger"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/middleware/compressor"
+)
+
+const maxSize = 1 << 20
+
+func gz(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	require.NoError(t, err)
+	_, err = zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// snappyBomb блок snappy, в заголовке которого указан размер n после распаковки, а данных нет.
+func snappyBomb(n uint64) []byte {
+	var buf [16]byte
+	i := 0
+	for ; n >= 0x80; n >>= 7 {
+		buf[i] = byte(n) | 0x80
+		i++
+	}
+	buf[i] = byte(n)
+	return buf[:i+1]
+}
+
+func TestDecompressionLimits(t *testing.T) {
+	log, _ := logger.New("Info")
+	h := compressor.New(log.Sugar(), maxSize)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		_, err := io.Copy(io.Discard, r.Body)
+		assert.NoError(t, err)
+	}))
+
+	exact := bytes.Repeat([]byte{'0'}, maxSize)
+	// 64 МБ нулей сжимаются gzip примерно в 64 КБ.
+	bomb := gz(t, make([]byte, 64<<20))
+	require.Less(t, len(bomb), 128<<10)
+	small := gz(t, []byte(`[{"id":"Alloc","type":"gauge","value":1}]`))
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		status   int
+	}{
+		{name: "gzip at limit", encoding: "gzip", body: gz(t, exact), status: http.StatusOK},
+		{name: "gzip over limit by one byte", encoding: "gzip", body: gz(t, append(exact, '0')),
+			status: http.StatusRequestEntityTooLarge},
+		{name: "gzip bomb", encoding: "gzip", body: bomb, status: http.StatusRequestEntityTooLarge},
+		{name: "gzip corrupted", encoding: "gzip", body: small[:len(small)-4], status: http.StatusBadRequest},
+		{name: "snappy at limit", encoding: "snappy", body: snappy.Encode(nil, exact), status: http.StatusOK},
+		{name: "snappy over limit", encoding: "snappy", body: snappy.Encode(nil, append(exact, '0')),
+			status: http.StatusRequestEntityTooLarge},
+		{name: "snappy bomb header", encoding: "snappy", body: snappyBomb(1 << 30),
+			status: http.StatusRequestEntityTooLarge},
+		{name: "uncompressed over limit is left to bodylimit", body: append(exact, '0'), status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(tt.body))
+			if tt.encoding != "" {
+				r.Header.Set("Content-Encoding", tt.encoding)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			if tt.status == http.StatusRequestEntityTooLarge {
+				assert.Contains(t, w.Body.String(), "payload_too_large")
+			}
+		})
+	}
+}
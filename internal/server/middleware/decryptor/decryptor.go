@@ -0,0 +1,64 @@
+package decryptor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
+	"github.com/VanGoghDev/practicum-metrics/internal/util/encryption"
+	"go.uber.org/zap"
+)
+
+// New расшифровывает тело запроса, зашифрованное агентом открытым ключом сервера
+// (заголовок X-Encryption), закрытым ключом key. Агент шифрует уже сжатое тело,
+// поэтому middleware должен стоять перед compressor.
+// Запросы без заголовка пропускаются как есть. Если ключ не задан,
+// зашифрованный запрос отклоняется с 400.
+func New(zlog *zap.SugaredLogger, key *encryption.PrivateKey) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			scheme := r.Header.Get(encryption.HeaderScheme)
+			if scheme == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			reject := func(message string) {
+				zlog.Warnf("rejected request %s %s: %s", r.Method, r.URL.Path, message)
+				w.Header().Set("Content-Type", "application/json")
+				handlers.WriteError(w, handlers.NewError(http.StatusBadRequest, handlers.CodeInvalidRequest, message, ""))
+			}
+
+			if key == nil {
+				reject("request body is encrypted, but server has no crypto key")
+				return
+			}
+
+			sealed, err := io.ReadAll(r.Body)
+			if err != nil {
+				zlog.Warnf("failed to read request body: %v", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			body, err := key.Decrypt(scheme, sealed)
+			if err != nil {
+				if errors.Is(err, encryption.ErrUnsupportedKey) {
+					reject("request encryption scheme does not match server key")
+					return
+				}
+				reject("request body can not be decrypted")
+				return
+			}
+
+			r.Header.Del(encryption.HeaderScheme)
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.ContentLength = int64(len(body))
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,134 @@
+package decryptor_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	agentconfig "github.com/VanGoghDev/practicum-metrics/internal/agent/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/agent/transport"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/logger"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers/chirouter"
+	"github.com/VanGoghDev/practicum-metrics/internal/storage/memstorage"
+	"go.uber.org/zap"
+)
+
+const body = `[{"id":"PollCount","type":"counter","delta":1}]`
+
+// writeKeys генерирует пару ключей и сохраняет ее в PEM файлы.
+func writeKeys(t *testing.T, rsaKey bool) (pubPath, privPath string) {
+	t.Helper()
+
+	var pub, priv any
+	if rsaKey {
+		k, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		pub, priv = &k.PublicKey, k
+	} else {
+		k, err := ecdh.X25519().GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		pub, priv = k.PublicKey(), k
+	}
+
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	pubPath, privPath = filepath.Join(dir, "public.pem"), filepath.Join(dir, "private.pem")
+	require.NoError(t, os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0o600))
+	require.NoError(t, os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0o600))
+	return pubPath, privPath
+}
+
+func TestAgentEncryptedRequest(t *testing.T) {
+	rsaPub, rsaPriv := writeKeys(t, true)
+	xPub, xPriv := writeKeys(t, false)
+
+	tests := []struct {
+		name      string
+		serverKey string
+		agentKey  string
+		signKey   string
+		status    int
+	}{
+		{name: "rsa", serverKey: rsaPriv, agentKey: rsaPub, status: http.StatusOK},
+		{name: "x25519", serverKey: xPriv, agentKey: xPub, status: http.StatusOK},
+		{name: "x25519 signed", serverKey: xPriv, agentKey: xPub, signKey: "secret", status: http.StatusOK},
+		{name: "plain body with server key", serverKey: xPriv, status: http.StatusOK},
+		{name: "encrypted body without server key", agentKey: xPub, status: http.StatusBadRequest},
+		{name: "scheme does not match server key", serverKey: rsaPriv, agentKey: xPub, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, _ := logger.New("Info")
+			memstrg, _ := memstorage.New(log)
+			cfg := &config.Config{CryptoKey: tt.serverKey, Key: tt.signKey, SignatureStrict: true}
+			r, err := chirouter.BuildRouter(memstrg, log, cfg)
+			require.NoError(t, err)
+			srv := httptest.NewServer(r)
+			defer srv.Close()
+
+			agentCfg := &agentconfig.Config{CryptoKey: tt.agentKey, Key: tt.signKey}
+			tripper, err := transport.New(zap.NewNop(), agentCfg, http.DefaultTransport)
+			require.NoError(t, err)
+			req, err := http.NewRequest(http.MethodPost, srv.URL+"/updates/", bytes.NewBufferString(body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := (&http.Client{Transport: tripper}).Do(req)
+			require.NoError(t, err)
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+			_, _ = io.Copy(io.Discard, resp.Body)
+
+			require.Equal(t, tt.status, resp.StatusCode)
+			if tt.status != http.StatusOK {
+				return
+			}
+			c, err := memstrg.Counter(context.Background(), "PollCount")
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), c.Value)
+		})
+	}
+}
+
+func TestDecryptGarbage(t *testing.T) {
+	_, priv := writeKeys(t, false)
+	log, _ := logger.New("Info")
+	memstrg, _ := memstorage.New(log)
+	r, err := chirouter.BuildRouter(memstrg, log, &config.Config{CryptoKey: priv})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(body))
+	req.Header.Set("X-Encryption", "x25519")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid_request")
+}
+
+func TestBuildRouter_InvalidKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(path, []byte("not a key"), 0o600))
+	log, _ := logger.New("Info")
+	memstrg, _ := memstorage.New(log)
+	_, err := chirouter.BuildRouter(memstrg, log, &config.Config{CryptoKey: path})
+	require.Error(t, err)
+}
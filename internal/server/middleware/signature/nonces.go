@@ -0,0 +1,39 @@
+package signature
+
+import (
+	"sync"
+	"time"
+)
+
+// nonceCache хранит nonce принятых запросов, пока их время подписи не выйдет за допустимое окно.
+// После этого повтор запроса отклоняется по времени, и nonce можно забыть.
+type nonceCache struct {
+	lastPrune time.Time
+	seen      map[string]time.Time
+	mu        sync.Mutex
+}
+
+func newNonceCache() *nonceCache {
+	return &nonceCache{seen: make(map[string]time.Time)}
+}
+
+// add запоминает nonce до момента expires. Возвращает false, если nonce уже использован.
+func (c *nonceCache) add(nonce string, expires, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.lastPrune) >= time.Second {
+		for n, exp := range c.seen {
+			if now.After(exp) {
+				delete(c.seen, n)
+			}
+		}
+		c.lastPrune = now
+	}
+
+	if exp, ok := c.seen[nonce]; ok && !now.After(exp) {
+		return false
+	}
+	c.seen[nonce] = expires
+	return true
+}
@@ -0,0 +1,86 @@
+package signature
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/util/signing"
+)
+
+// signedWriter копит ответ, чтобы подписать его тело целиком до отправки заголовков.
+// Middleware подписи стоит перед compressor, поэтому сюда приходит уже сжатое тело,
+// то есть ровно те байты, которые получит агент.
+//
+// Поток Server-Sent Events не заканчивается, пока клиент не отключится, поэтому его нельзя
+// ни накопить, ни подписать: такой ответ отправляется как есть, без подписи.
+type signedWriter struct {
+	http.ResponseWriter
+	body   bytes.Buffer
+	status int
+	stream bool
+}
+
+func (sw *signedWriter) WriteHeader(status int) {
+	if sw.status != 0 {
+		return
+	}
+	sw.status = status
+	if strings.HasPrefix(sw.Header().Get("Content-Type"), "text/event-stream") {
+		sw.stream = true
+		sw.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (sw *signedWriter) Write(data []byte) (int, error) {
+	if sw.status == 0 {
+		sw.WriteHeader(http.StatusOK)
+	}
+	if sw.stream {
+		n, err := sw.ResponseWriter.Write(data)
+		if err != nil {
+			return n, fmt.Errorf("failed to write response stream: %w", err)
+		}
+		return n, nil
+	}
+	n, err := sw.body.Write(data)
+	if err != nil {
+		return n, fmt.Errorf("failed to buffer response: %w", err)
+	}
+	return n, nil
+}
+
+// Flush досылает клиенту поток событий. Остальные ответы отправляются целиком после подписи.
+func (sw *signedWriter) Flush() {
+	if !sw.stream {
+		return
+	}
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap нужен http.ResponseController, например для установки таймаутов записи.
+func (sw *signedWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// flush подписывает накопленное тело тем же ключом, временем и nonce, что и запрос,
+// и отправляет ответ. Так подпись ответа нельзя переставить на ответ другому запросу.
+func (sw *signedWriter) flush(key []byte, timestamp, nonce string) error {
+	if sw.stream {
+		return nil
+	}
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	mac := signing.Sign(key, timestamp, nonce, sw.body.Bytes())
+	sw.ResponseWriter.Header().Set(signing.HeaderSignature, base64.StdEncoding.EncodeToString(mac))
+	sw.ResponseWriter.WriteHeader(sw.status)
+	if _, err := sw.ResponseWriter.Write(sw.body.Bytes()); err != nil {
+		return fmt.Errorf("failed to write signed response: %w", err)
+	}
+	return nil
+}
@@ -5,57 +5,144 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
-	"encoding/hex"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/keyring"
+	"github.com/VanGoghDev/practicum-metrics/internal/util/signing"
 	"go.uber.org/zap"
 )
 
-func New(zlog *zap.SugaredLogger, cfg *config.Config) func(next http.Handler) http.Handler {
+const (
+	defaultMaxSkew = 5 * time.Minute
+	maxNonceLen    = 128
+)
+
+// New проверяет подпись HashSHA256 запроса: HMAC-SHA256 с ключом из keys от тела запроса
+// в том виде, в котором его подписал и отправил агент, поэтому middleware должен стоять
+// перед decryptor и compressor.
+//
+// В строгом режиме (cfg.SignatureStrict, включен по умолчанию) запрос без подписи,
+// с некорректной или неверной подписью отклоняется с 401. Без строгого режима запрос
+// без подписи пропускается, а неверная подпись отклоняется с 400, как раньше.
+// Ключ выбирается по идентификатору из заголовка X-Signature-Key-Id, без заголовка - ключ cfg.Key.
+// Запрос с неизвестным идентификатором отклоняется. Если ни одного ключа не задано, подпись не проверяется.
+//
+// Для защиты от повторов агент подписывает вместе с телом время подписи и nonce
+// (заголовки X-Signature-Timestamp и X-Signature-Nonce). Запрос отклоняется, если время
+// отличается от времени сервера больше чем на cfg.SignatureMaxSkew или nonce уже встречался
+// в пределах этого окна. В строгом режиме запрос без времени и nonce отклоняется,
+// без строгого режима проверяется подпись только тела, как у агентов старых версий.
+//
+// Ответ на подписанный запрос подписывается в заголовке HashSHA256 тем же ключом, временем и nonce
+// от тела ответа. Чтобы подпись покрывала сжатое тело, middleware должен стоять перед compressor.
+func New(zlog *zap.SugaredLogger, cfg *config.Config, keys *keyring.Ring) func(next http.Handler) http.Handler {
+	skew := cfg.SignatureMaxSkew
+	if skew <= 0 {
+		skew = defaultMaxSkew
+	}
+	nonces := newNonceCache()
+
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
-			if cfg.Key == "" {
+			if keys.Empty() {
 				next.ServeHTTP(w, r)
 				return
 			}
 
-			reqSign := r.Header.Get("HashSHA256")
+			reject := func(message string) {
+				zlog.Warnf("rejected request %s %s: %s", r.Method, r.URL.Path, message)
+				if !cfg.SignatureStrict {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				handlers.WriteError(w, handlers.NewError(http.StatusUnauthorized, handlers.CodeUnauthorized, message, ""))
+			}
 
-			// в ходе обсуждения выявили, что в текущей реализации
-			// автотестов, это единственный вариант пока что.
+			reqSign := r.Header.Get(signing.HeaderSignature)
 			if reqSign == "" {
-				next.ServeHTTP(w, r)
+				if !cfg.SignatureStrict {
+					next.ServeHTTP(w, r)
+					return
+				}
+				reject("request signature is missing")
 				return
 			}
 
-			hV, _ := base64.StdEncoding.DecodeString(reqSign)
+			hV, err := base64.StdEncoding.DecodeString(reqSign)
+			if err != nil || len(hV) != sha256.Size {
+				reject("request signature is malformed")
+				return
+			}
+
+			keyID := r.Header.Get(signing.HeaderKeyID)
+			key, ok := keys.Key(keyID)
+			if !ok {
+				reject("request signature key id is unknown")
+				return
+			}
+
+			ts, nonce := r.Header.Get(signing.HeaderTimestamp), r.Header.Get(signing.HeaderNonce)
+			replayProtected := ts != "" || nonce != ""
+			if !replayProtected && cfg.SignatureStrict {
+				reject("request signature timestamp and nonce are missing")
+				return
+			}
+
+			now := time.Now()
+			var signedAt time.Time
+			if replayProtected {
+				sec, err := strconv.ParseInt(ts, 10, 64)
+				if err != nil || nonce == "" || len(nonce) > maxNonceLen {
+					reject("request signature timestamp or nonce is malformed")
+					return
+				}
+				signedAt = time.Unix(sec, 0)
+				if now.Sub(signedAt) > skew || signedAt.Sub(now) > skew {
+					reject("request signature timestamp is outside the allowed clock skew")
+					return
+				}
+			}
+
 			body, err := io.ReadAll(r.Body)
 			if err != nil {
-				zlog.Warnf("failed to read request body: %w", err)
+				zlog.Warnf("failed to read request body: %v", err)
 				w.WriteHeader(http.StatusInternalServerError)
 				return
 			}
 
-			h := hmac.New(sha256.New, []byte(cfg.Key))
-			h.Write(body)
-			dst := h.Sum(nil)
+			dst := signing.Sign(key, ts, nonce, body)
 			if !hmac.Equal(dst, hV) {
-				w.WriteHeader(http.StatusBadRequest)
+				reject("request signature does not match")
+				return
+			}
+			// nonce запоминается только после проверки подписи, иначе чужие запросы
+			// могли бы занять nonce настоящего агента.
+			if replayProtected && !nonces.add(nonce, signedAt.Add(skew), now) {
+				reject("request nonce was already used")
 				return
 			}
-			v := hex.EncodeToString(dst)
-			w.Header().Set("HashSHA256", v)
+			if keyID != "" {
+				w.Header().Set(signing.HeaderKeyID, keyID)
+			}
 			r.Body = io.NopCloser(bytes.NewBuffer(body))
 
-			err = r.Body.Close()
-			if err != nil {
-				zlog.Warnf("failed to close request body: %w", err)
-				w.WriteHeader(http.StatusInternalServerError)
+			// Соединение, переключаемое на другой протокол (WebSocket), не буферизуем и не подписываем.
+			if r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
 				return
 			}
-			next.ServeHTTP(w, r)
+
+			sw := &signedWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			if err := sw.flush(key, ts, nonce); err != nil {
+				zlog.Warnf("failed to send response %s %s: %v", r.Method, r.URL.Path, err)
+			}
 		}
 
 		return http.HandlerFunc(fn)
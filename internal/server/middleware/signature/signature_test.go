@@ -0,0 +1,344 @@
+package signature_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	agentconfig "github.com/VanGoghDev/practicum-metrics/internal/agent/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/agent/transport"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/keyring"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/logger"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/middleware/compressor"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/middleware/signature"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers/chirouter"
+	"github.com/VanGoghDev/practicum-metrics/internal/storage/memstorage"
+	"go.uber.org/zap"
+)
+
+const (
+	key   = "secret"
+	body  = `[{"id":"PollCount","type":"counter","delta":1}]`
+	nonce = "0123456789abcdef0123456789abcdef"
+)
+
+// sign подписывает тело без времени и nonce, как агенты старых версий.
+func sign(k string, data []byte) string {
+	h := hmac.New(sha256.New, []byte(k))
+	h.Write(data)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// signAt подписывает время, nonce и тело.
+func signAt(k, ts, n string, data []byte) string {
+	h := hmac.New(sha256.New, []byte(k))
+	h.Write([]byte(ts + "\n" + n + "\n"))
+	h.Write(data)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func unix(d time.Duration) string {
+	return strconv.FormatInt(time.Now().Add(d).Unix(), 10)
+}
+
+func gz(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func newSignature(t *testing.T, zlog *zap.SugaredLogger, cfg *config.Config) func(http.Handler) http.Handler {
+	t.Helper()
+	keys, err := keyring.New(cfg)
+	require.NoError(t, err)
+	return signature.New(zlog, cfg, keys)
+}
+
+// echo отвечает распакованным телом запроса.
+var echo = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	data, _ := io.ReadAll(r.Body)
+	_, _ = w.Write(data)
+})
+
+func TestSignature(t *testing.T) {
+	log, _ := logger.New("Info")
+	zlog := log.Sugar()
+	compressed := gz(t, []byte(body))
+
+	now := unix(0)
+	stale := unix(-10 * time.Minute)
+	future := unix(10 * time.Minute)
+
+	tests := []struct {
+		name   string
+		body   []byte
+		sign   string
+		key    string
+		ts     string
+		nonce  string
+		strict bool
+		gzip   bool
+		status int
+	}{
+		{name: "no key, unsigned", body: []byte(body), strict: true, status: http.StatusOK},
+		{name: "strict, unsigned", key: key, body: []byte(body), strict: true, status: http.StatusUnauthorized},
+		{name: "strict, malformed", key: key, body: []byte(body), sign: "not base64!", ts: now, nonce: nonce,
+			strict: true, status: http.StatusUnauthorized},
+		{name: "strict, wrong key", key: key, body: []byte(body), sign: signAt("other", now, nonce, []byte(body)),
+			ts: now, nonce: nonce, strict: true, status: http.StatusUnauthorized},
+		{name: "strict, signed", key: key, body: []byte(body), sign: signAt(key, now, nonce, []byte(body)),
+			ts: now, nonce: nonce, strict: true, status: http.StatusOK},
+		{name: "strict, signed without timestamp and nonce", key: key, body: []byte(body),
+			sign: sign(key, []byte(body)), strict: true, status: http.StatusUnauthorized},
+		{name: "strict, malformed timestamp", key: key, body: []byte(body),
+			sign: signAt(key, "yesterday", nonce, []byte(body)), ts: "yesterday", nonce: nonce, strict: true,
+			status: http.StatusUnauthorized},
+		{name: "strict, stale timestamp", key: key, body: []byte(body), sign: signAt(key, stale, nonce, []byte(body)),
+			ts: stale, nonce: nonce, strict: true, status: http.StatusUnauthorized},
+		{name: "strict, future timestamp", key: key, body: []byte(body),
+			sign: signAt(key, future, nonce, []byte(body)), ts: future, nonce: nonce, strict: true,
+			status: http.StatusUnauthorized},
+		{name: "strict, nonce not covered by signature", key: key, body: []byte(body),
+			sign: signAt(key, now, "other", []byte(body)), ts: now, nonce: nonce, strict: true,
+			status: http.StatusUnauthorized},
+		{name: "strict, gzip signed over compressed body", key: key, body: compressed,
+			sign: signAt(key, now, nonce, compressed), ts: now, nonce: nonce, gzip: true, strict: true,
+			status: http.StatusOK},
+		{name: "strict, gzip signed over plain body", key: key, body: compressed,
+			sign: signAt(key, now, nonce, []byte(body)), ts: now, nonce: nonce, gzip: true, strict: true,
+			status: http.StatusUnauthorized},
+		{name: "lenient, unsigned", key: key, body: []byte(body), status: http.StatusOK},
+		{name: "lenient, signed without timestamp and nonce", key: key, body: []byte(body),
+			sign: sign(key, []byte(body)), status: http.StatusOK},
+		{name: "lenient, wrong key", key: key, body: []byte(body), sign: sign("other", []byte(body)),
+			status: http.StatusBadRequest},
+		{name: "lenient, stale timestamp", key: key, body: []byte(body), sign: signAt(key, stale, nonce, []byte(body)),
+			ts: stale, nonce: nonce, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Key: tt.key, SignatureStrict: tt.strict}
+			h := newSignature(t, zlog, cfg)(compressor.New(zlog, 0)(echo))
+
+			r := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(tt.body))
+			if tt.sign != "" {
+				r.Header.Set("HashSHA256", tt.sign)
+			}
+			if tt.ts != "" {
+				r.Header.Set("X-Signature-Timestamp", tt.ts)
+			}
+			if tt.nonce != "" {
+				r.Header.Set("X-Signature-Nonce", tt.nonce)
+			}
+			if tt.gzip {
+				r.Header.Set("Content-Encoding", "gzip")
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			if tt.status == http.StatusOK {
+				assert.Equal(t, body, w.Body.String())
+			}
+			// ответ на подписанный запрос подписан тем же ключом, временем и nonce.
+			if tt.status == http.StatusOK && tt.sign != "" {
+				want := sign(tt.key, []byte(body))
+				if tt.ts != "" {
+					want = signAt(tt.key, tt.ts, tt.nonce, []byte(body))
+				}
+				assert.Equal(t, want, w.Header().Get("HashSHA256"))
+			}
+		})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	log, _ := logger.New("Info")
+	h := newSignature(t, log.Sugar(), &config.Config{Key: key, SignatureStrict: true})(echo)
+
+	ts := unix(0)
+	send := func(n, sign string) int {
+		r := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(body))
+		r.Header.Set("HashSHA256", sign)
+		r.Header.Set("X-Signature-Timestamp", ts)
+		r.Header.Set("X-Signature-Nonce", n)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// запрос с неверной подписью не должен занимать nonce.
+	assert.Equal(t, http.StatusUnauthorized, send(nonce, signAt("other", ts, nonce, []byte(body))))
+	assert.Equal(t, http.StatusOK, send(nonce, signAt(key, ts, nonce, []byte(body))))
+	assert.Equal(t, http.StatusUnauthorized, send(nonce, signAt(key, ts, nonce, []byte(body))))
+	assert.Equal(t, http.StatusOK, send("other", signAt(key, ts, "other", []byte(body))))
+}
+
+func TestKeyRotation(t *testing.T) {
+	log, _ := logger.New("Info")
+	path := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(path, []byte("v1 old-secret\nv2 new-secret\n"), 0o600))
+
+	cfg := &config.Config{Key: key, KeysFile: path, SignatureStrict: true}
+	keys, err := keyring.New(cfg)
+	require.NoError(t, err)
+	h := signature.New(log.Sugar(), cfg, keys)(echo)
+
+	n := 0
+	send := func(id, k string) *httptest.ResponseRecorder {
+		n++
+		ts, nc := unix(0), strconv.Itoa(n)
+		r := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(body))
+		r.Header.Set("HashSHA256", signAt(k, ts, nc, []byte(body)))
+		r.Header.Set("X-Signature-Timestamp", ts)
+		r.Header.Set("X-Signature-Nonce", nc)
+		if id != "" {
+			r.Header.Set("X-Signature-Key-Id", id)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := send("v1", "old-secret")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "v1", w.Header().Get("X-Signature-Key-Id"))
+	assert.Equal(t, http.StatusOK, send("v2", "new-secret").Code)
+	assert.Equal(t, http.StatusOK, send("", key).Code)
+	assert.Equal(t, http.StatusUnauthorized, send("v2", "old-secret").Code)
+	assert.Equal(t, http.StatusUnauthorized, send("v3", "new-secret").Code)
+
+	// после ротации старый ключ больше не принимается.
+	require.NoError(t, os.WriteFile(path, []byte("v2 new-secret\n"), 0o600))
+	require.NoError(t, keys.Reload())
+	assert.Equal(t, http.StatusUnauthorized, send("v1", "old-secret").Code)
+	assert.Equal(t, http.StatusOK, send("v2", "new-secret").Code)
+}
+
+func TestAgentSignedRequest(t *testing.T) {
+	log, _ := logger.New("Info")
+	zlog := log.Sugar()
+	cfg := &config.Config{Key: key, SignatureStrict: true}
+	srv := httptest.NewServer(newSignature(t, zlog, cfg)(compressor.New(zlog, 0)(echo)))
+	defer srv.Close()
+
+	// агент сначала сжимает тело, затем подписывает сжатые данные.
+	tripper, err := transport.New(zap.NewNop(), &agentconfig.Config{Key: key}, http.DefaultTransport)
+	require.NoError(t, err)
+	client := &http.Client{Transport: tripper}
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/updates/", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	// агент сам запрашивает сжатый ответ, поэтому распаковываем его вручную.
+	require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	zr, err := gzip.NewReader(resp.Body)
+	require.NoError(t, err)
+	data, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(data))
+}
+
+func TestRouterSignedRoutes(t *testing.T) {
+	log, _ := logger.New("Info")
+	memstrg, _ := memstorage.New(log)
+	r, err := chirouter.BuildRouter(memstrg, log, &config.Config{Key: key, SignatureStrict: true})
+	require.NoError(t, err)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{name: "dashboard", method: http.MethodGet, path: "/", status: http.StatusOK},
+		{name: "ping", method: http.MethodGet, path: "/ping", status: http.StatusOK},
+		{name: "api ping", method: http.MethodGet, path: "/api/v1/ping", status: http.StatusOK},
+		{name: "openapi", method: http.MethodGet, path: "/api/v1/openapi.json", status: http.StatusOK},
+		{name: "list metrics", method: http.MethodGet, path: "/api/v1/metrics/", status: http.StatusOK},
+		{name: "influx write", method: http.MethodPost, path: "/api/v2/write", body: "queue size=1",
+			status: http.StatusNoContent},
+		{name: "agent update", method: http.MethodPost, path: "/update/counter/PollCount/1",
+			status: http.StatusUnauthorized},
+		{name: "agent updates", method: http.MethodPost, path: "/updates/", body: body,
+			status: http.StatusUnauthorized},
+		{name: "api metrics write", method: http.MethodPost, path: "/api/v1/metrics/", body: body,
+			status: http.StatusUnauthorized},
+		{name: "api metric put", method: http.MethodPut, path: "/api/v1/metrics/gauge/Alloc", body: `{"value":1}`,
+			status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, srv.URL+tt.path, bytes.NewBufferString(tt.body))
+			require.NoError(t, err)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+			assert.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+}
+
+func TestSignedEventStream(t *testing.T) {
+	log, _ := logger.New("Info")
+	zlog := log.Sugar()
+	cfg := &config.Config{Key: key, SignatureStrict: true}
+	events := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, "data: {}\n\n")
+		require.NoError(t, http.NewResponseController(w).Flush())
+		// поток не заканчивается, пока клиент не отключится.
+		<-r.Context().Done()
+	})
+	srv := httptest.NewServer(newSignature(t, zlog, cfg)(compressor.New(zlog, 0)(events)))
+	defer srv.Close()
+
+	now := unix(0)
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/stream", http.NoBody)
+	require.NoError(t, err)
+	req.Header.Set("HashSHA256", signAt(key, now, nonce, nil))
+	req.Header.Set("X-Signature-Timestamp", now)
+	req.Header.Set("X-Signature-Nonce", nonce)
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	require.NoError(t, err)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("HashSHA256"))
+	// первое событие приходит до завершения обработчика.
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "data: {}\n", line)
+}
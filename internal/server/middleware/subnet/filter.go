@@ -0,0 +1,48 @@
+package subnet
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Filter проверяет по доверенной подсети адреса клиентов. Общий для HTTP API (см. New)
+// и приемников без HTTP (gRPC, StatsD, Graphite), чтобы запись через них ограничивалась одинаково.
+type Filter struct {
+	trusted *net.IPNet
+}
+
+// NewFilter создает фильтр по подсети cidr вида 10.0.0.0/8. Если подсеть не задана, подходит любой адрес.
+func NewFilter(cidr string) (*Filter, error) {
+	f := &Filter{}
+	if cidr == "" {
+		return f, nil
+	}
+	_, trusted, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse trusted subnet: %w", err)
+	}
+	f.trusted = trusted
+	return f, nil
+}
+
+// Allows сообщает, входит ли адрес addr (IP или host:port) в доверенную подсеть.
+// Нулевой фильтр, как и фильтр без подсети, подходит для любого адреса.
+func (f *Filter) Allows(addr string) bool {
+	if f == nil || f.trusted == nil {
+		return true
+	}
+	addr = strings.TrimSpace(addr)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip := net.ParseIP(addr)
+	return ip != nil && f.trusted.Contains(ip)
+}
+
+func (f *Filter) String() string {
+	if f == nil || f.trusted == nil {
+		return ""
+	}
+	return f.trusted.String()
+}
@@ -0,0 +1,47 @@
+package subnet
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
+	"go.uber.org/zap"
+)
+
+// RealIPHeader заголовок, в котором агент передает адрес своего исходящего интерфейса.
+const RealIPHeader = "X-Real-IP"
+
+// New пропускает только запросы из доверенной подсети фильтра trusted, остальные отклоняет с 403.
+// Адрес клиента берется из заголовка X-Real-IP, а если его нет - из адреса соединения.
+// Если подсеть не задана, запросы не проверяются.
+func New(zlog *zap.SugaredLogger, trusted *Filter) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			source := clientIP(r)
+			if !trusted.Allows(source) {
+				zlog.Warnf("rejected request %s %s from %q (remote %s): outside trusted subnet %s",
+					r.Method, r.URL.Path, source, r.RemoteAddr, trusted)
+				w.Header().Set("Content-Type", "application/json")
+				handlers.WriteError(w, handlers.NewError(http.StatusForbidden, handlers.CodeForbidden,
+					"client address is outside trusted subnet", ""))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func clientIP(r *http.Request) string {
+	if ip := strings.TrimSpace(r.Header.Get(RealIPHeader)); ip != "" {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,137 @@
+package subnet_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	agentconfig "github.com/VanGoghDev/practicum-metrics/internal/agent/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/agent/transport"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/logger"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/middleware/subnet"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers/chirouter"
+	"github.com/VanGoghDev/practicum-metrics/internal/storage/memstorage"
+	"go.uber.org/zap"
+)
+
+func TestNew(t *testing.T) {
+	log, _ := logger.New("Info")
+	trusted, err := subnet.NewFilter("10.0.0.0/8")
+	require.NoError(t, err)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		trusted *subnet.Filter
+		name    string
+		realIP  string
+		remote  string
+		status  int
+	}{
+		{name: "no subnet", remote: "192.168.1.1:5000", status: http.StatusOK},
+		{name: "real ip inside", trusted: trusted, realIP: "10.1.2.3", remote: "192.168.1.1:5000",
+			status: http.StatusOK},
+		{name: "real ip outside", trusted: trusted, realIP: "192.168.1.1", remote: "10.1.2.3:5000",
+			status: http.StatusForbidden},
+		{name: "remote inside", trusted: trusted, remote: "10.1.2.3:5000", status: http.StatusOK},
+		{name: "remote outside", trusted: trusted, remote: "192.168.1.1:5000", status: http.StatusForbidden},
+		{name: "invalid real ip", trusted: trusted, realIP: "localhost", remote: "10.1.2.3:5000",
+			status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			w := httptest.NewRecorder()
+			subnet.New(log.Sugar(), tt.trusted)(ok).ServeHTTP(w, r)
+
+			require.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusForbidden {
+				assert.Contains(t, w.Body.String(), "forbidden")
+			}
+		})
+	}
+}
+
+func TestAgentRealIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		trusted string
+		status  int
+	}{
+		{name: "agent inside trusted subnet", trusted: "127.0.0.0/8", status: http.StatusOK},
+		{name: "agent outside trusted subnet", trusted: "10.0.0.0/8", status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, _ := logger.New("Info")
+			memstrg, _ := memstorage.New(log)
+			r, err := chirouter.BuildRouter(memstrg, log, &config.Config{TrustedSubnet: tt.trusted})
+			require.NoError(t, err)
+			srv := httptest.NewServer(r)
+			defer srv.Close()
+
+			tripper, err := transport.New(zap.NewNop(), &agentconfig.Config{}, http.DefaultTransport)
+			require.NoError(t, err)
+			req, err := http.NewRequest(http.MethodPost, srv.URL+"/updates/",
+				bytes.NewBufferString(`[{"id":"PollCount","type":"counter","delta":1}]`))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := (&http.Client{Transport: tripper}).Do(req)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+			assert.Equal(t, tt.status, resp.StatusCode)
+
+			// чтение метрик доверенной подсетью не ограничивается.
+			resp, err = http.Get(srv.URL + "/api/v1/metrics/")
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		})
+	}
+}
+
+func TestBuildRouter_InvalidSubnet(t *testing.T) {
+	log, _ := logger.New("Info")
+	memstrg, _ := memstorage.New(log)
+	_, err := chirouter.BuildRouter(memstrg, log, &config.Config{TrustedSubnet: "10.0.0.0"})
+	require.Error(t, err)
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		cidr   string
+		addr   string
+		allows bool
+	}{
+		{name: "no subnet", addr: "192.168.1.1:5000", allows: true},
+		{name: "ip inside", cidr: "10.0.0.0/8", addr: "10.1.2.3", allows: true},
+		{name: "host and port inside", cidr: "10.0.0.0/8", addr: "10.1.2.3:5000", allows: true},
+		{name: "ipv6 host and port outside", cidr: "10.0.0.0/8", addr: "[::1]:5000", allows: false},
+		{name: "outside", cidr: "10.0.0.0/8", addr: "192.168.1.1:5000", allows: false},
+		{name: "not an ip", cidr: "10.0.0.0/8", addr: "localhost", allows: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := subnet.NewFilter(tt.cidr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.allows, f.Allows(tt.addr))
+		})
+	}
+
+	_, err := subnet.NewFilter("10.0.0.0")
+	require.Error(t, err)
+}
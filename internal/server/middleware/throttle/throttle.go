@@ -0,0 +1,50 @@
+package throttle
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/ratelimit"
+	"go.uber.org/zap"
+)
+
+// New ограничивает частоту запросов каждого клиента лимитером l и отклоняет лишние запросы
+// с 429 и заголовком Retry-After. Лимитер сохраняется в контексте запроса,
+// чтобы обработчики записи учли количество метрик в батче (см. ratelimit.AllowMetricsContext).
+// Если лимиты не заданы, запросы не проверяются.
+func New(zlog *zap.SugaredLogger, l *ratelimit.Limiter) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if !l.Enabled() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var limitErr *ratelimit.LimitError
+			if err := l.AllowRequest(ClientID(r)); errors.As(err, &limitErr) {
+				zlog.Warnf("rejected request %s %s: %v", r.Method, r.URL.Path, err)
+				w.Header().Set("Content-Type", "application/json")
+				handlers.WriteRateLimit(w, limitErr)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ratelimit.WithLimiter(r.Context(), l)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// ClientID определяет клиента для лимита запросов: IP адрес соединения.
+// Запросы проверяются до чтения тела и проверки API токена, поэтому заголовкам, которые
+// клиент выбирает сам (X-Real-IP, непроверенный токен), лимит не доверяет: иначе клиент
+// получал бы новый бакет, меняя их значение. Лимит метрик считается уже по проверенному токену.
+func ClientID(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
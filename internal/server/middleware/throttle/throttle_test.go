@@ -0,0 +1,148 @@
+package throttle_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/logger"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/middleware/throttle"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers/chirouter"
+	"github.com/VanGoghDev/practicum-metrics/internal/storage/memstorage"
+)
+
+const batch = `[{"id":"Alloc","type":"gauge","value":1},{"id":"HeapAlloc","type":"gauge","value":2},` +
+	`{"id":"PollCount","type":"counter","delta":1}]`
+
+func TestClientID(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		agent  string
+		realIP string
+		remote string
+		want   string
+	}{
+		{name: "ip", remote: "10.1.2.3:5000", want: "10.1.2.3"},
+		{name: "ipv6", remote: "[::1]:5000", want: "::1"},
+		{name: "without port", remote: "10.1.2.3", want: "10.1.2.3"},
+		{name: "client headers are ignored", token: "secret", agent: "agent-1", realIP: "10.9.9.9",
+			remote: "10.1.2.3:5000", want: "10.1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.agent != "" {
+				r.Header.Set("X-Agent-ID", tt.agent)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			assert.Equal(t, tt.want, throttle.ClientID(r))
+		})
+	}
+}
+
+func TestRouterLimits(t *testing.T) {
+	log, _ := logger.New("Info")
+	tokens := filepath.Join(t.TempDir(), "tokens")
+	require.NoError(t, os.WriteFile(tokens, []byte("agent-token writer\nother-token writer\n"), 0o600))
+
+	type send struct {
+		remote string
+		agent  string
+		token  string
+		body   string
+		status int
+	}
+	tests := []struct {
+		cfg   *config.Config
+		name  string
+		sends []send
+	}{
+		{
+			name: "requests per second",
+			cfg:  &config.Config{RateLimitRequests: 1, RateLimitRequestsBurst: 2},
+			sends: []send{
+				{remote: "10.0.0.1:1", body: batch, status: http.StatusOK},
+				{remote: "10.0.0.1:2", body: batch, status: http.StatusOK},
+				{remote: "10.0.0.1:3", body: batch, status: http.StatusTooManyRequests},
+				{remote: "10.0.0.2:1", body: batch, status: http.StatusOK},
+			},
+		},
+		{
+			name: "rotating agent id does not reset the limit",
+			cfg:  &config.Config{RateLimitRequests: 1},
+			sends: []send{
+				{remote: "10.0.0.1:1", agent: "a", body: batch, status: http.StatusOK},
+				{remote: "10.0.0.1:1", agent: "b", body: batch, status: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name: "metrics per second count batch size",
+			cfg:  &config.Config{RateLimitMetrics: 5},
+			sends: []send{
+				{remote: "10.0.0.1:1", body: batch, status: http.StatusOK},
+				{remote: "10.0.0.1:1", body: batch, status: http.StatusTooManyRequests},
+				{remote: "10.0.0.1:1", body: `[{"id":"Alloc","type":"gauge","value":1}]`, status: http.StatusOK},
+				{remote: "10.0.0.2:1", body: batch, status: http.StatusOK},
+			},
+		},
+		{
+			name: "metrics are limited by verified token",
+			cfg:  &config.Config{RateLimitMetrics: 5, AuthTokensFile: tokens},
+			sends: []send{
+				{remote: "10.0.0.1:1", token: "agent-token", body: batch, status: http.StatusOK},
+				{remote: "10.0.0.2:1", token: "agent-token", body: batch, status: http.StatusTooManyRequests},
+				{remote: "10.0.0.1:1", token: "other-token", body: batch, status: http.StatusOK},
+			},
+		},
+		{
+			name: "invalid tokens share the ip request limit",
+			cfg:  &config.Config{RateLimitRequests: 1, AuthTokensFile: tokens},
+			sends: []send{
+				{remote: "10.0.0.1:1", token: "guess-1", body: batch, status: http.StatusUnauthorized},
+				{remote: "10.0.0.1:1", token: "guess-2", body: batch, status: http.StatusTooManyRequests},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memstrg, _ := memstorage.New(log)
+			r, err := chirouter.BuildRouter(memstrg, log, tt.cfg)
+			require.NoError(t, err)
+
+			for i, s := range tt.sends {
+				req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(s.body))
+				req.RemoteAddr = s.remote
+				if s.agent != "" {
+					req.Header.Set("X-Agent-ID", s.agent)
+				}
+				if s.token != "" {
+					req.Header.Set("Authorization", "Bearer "+s.token)
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+
+				require.Equal(t, s.status, w.Code, "request %d: %s", i, w.Body.String())
+				if s.status == http.StatusTooManyRequests {
+					assert.Equal(t, "1", w.Header().Get("Retry-After"))
+					assert.Contains(t, w.Body.String(), "rate_limited")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package naming
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+)
+
+// DefaultAllowedChars набор символов, разрешенных в имени метрики по умолчанию.
+// Задается в формате содержимого класса символов регулярного выражения.
+const DefaultAllowedChars = `a-zA-Z0-9_.:\-`
+
+var (
+	ErrEmptyName      = errors.New("metric name is empty")
+	ErrInvalidChars   = errors.New("metric name contains invalid characters")
+	ErrNameTooLong    = errors.New("metric name is too long")
+	ErrReservedPrefix = errors.New("metric name uses reserved prefix")
+	ErrAllowedChars   = errors.New("invalid allowed characters")
+)
+
+// Stats счетчики записей, отклоненных политикой именования.
+type Stats struct {
+	RejectedInvalidName    int64 `json:"rejected_invalid_name"`
+	RejectedNameTooLong    int64 `json:"rejected_name_too_long"`
+	RejectedReservedPrefix int64 `json:"rejected_reserved_prefix"`
+	MaxNameLength          int64 `json:"max_name_length"`
+}
+
+// Policy правила именования метрик: допустимые символы, максимальная длина,
+// зарезервированные префиксы и нормализация (приведение к нижнему регистру,
+// замена недопустимых символов).
+type Policy struct {
+	invalid          *regexp.Regexp
+	replacement      string
+	reservedPrefixes []string
+	maxLength        int
+	lowercase        bool
+
+	rejectedInvalid  atomic.Int64
+	rejectedTooLong  atomic.Int64
+	rejectedReserved atomic.Int64
+}
+
+func New(cfg *config.Config) (*Policy, error) {
+	allowed := cfg.NameAllowedChars
+	if allowed == "" {
+		allowed = DefaultAllowedChars
+	}
+
+	// набор вставляется внутрь класса [^...], поэтому скобки и ^ в нем изменили бы смысл выражения.
+	if strings.ContainsAny(allowed, "[]^") {
+		return nil, fmt.Errorf("%w %q: characters [, ] and ^ are not allowed", ErrAllowedChars, allowed)
+	}
+	invalid, err := regexp.Compile("[^" + allowed + "]")
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile allowed characters %q: %w", allowed, err)
+	}
+
+	prefixes := make([]string, 0)
+	for _, p := range strings.Split(cfg.NameReservedPrefixes, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			prefixes = append(prefixes, p)
+		}
+	}
+
+	return &Policy{
+		invalid:          invalid,
+		replacement:      cfg.NameReplaceInvalid,
+		reservedPrefixes: prefixes,
+		maxLength:        int(cfg.MaxNameLength),
+		lowercase:        cfg.NameLowercase,
+	}, nil
+}
+
+// Normalize приводит имя метрики к виду, заданному политикой, и проверяет его целиком,
+// включая символы {, } и кавычки, если они не разрешены политикой.
+// Возвращает нормализованное имя либо ошибку, в которой указано исходное имя метрики.
+func (p *Policy) Normalize(name string) (string, error) {
+	if p == nil {
+		return name, nil
+	}
+
+	normalized, err := p.normalize(name)
+	if err != nil {
+		return "", err
+	}
+	if err := p.checkLength(normalized, name); err != nil {
+		return "", err
+	}
+	return normalized, nil
+}
+
+// Lookup приводит к виду, заданному политикой, имя метрики, которую ищут для чтения или удаления.
+// Имя может быть именем серии с метками (name{key="value",...}), построенным сервером
+// через models.SeriesID, поэтому нормализуется только часть до меток.
+// Для записи метрик используются Admit и AdmitSeries.
+func (p *Policy) Lookup(id string) (string, error) {
+	if p == nil {
+		return id, nil
+	}
+
+	name, labels := id, ""
+	if i := strings.IndexByte(id, '{'); i > 0 && strings.HasSuffix(id, "}") {
+		name, labels = id[:i], id[i:]
+	}
+	normalized, err := p.normalize(name)
+	if err != nil {
+		return "", err
+	}
+	return normalized + labels, nil
+}
+
+// Admit нормализует имя записываемой метрики так же, как Normalize,
+// и учитывает отклоненные имена в счетчиках политики.
+func (p *Policy) Admit(name string) (string, error) {
+	normalized, err := p.Normalize(name)
+	p.count(err)
+	return normalized, err
+}
+
+// AdmitSeries нормализует имя записываемой метрики и строит из него и меток имя серии
+// через models.SeriesID. Ограничение длины относится к полному имени серии вместе с метками.
+// Отклоненные имена учитываются в счетчиках политики.
+func (p *Policy) AdmitSeries(name string, labels map[string]string) (string, error) {
+	id, err := p.series(name, labels)
+	p.count(err)
+	return id, err
+}
+
+func (p *Policy) series(name string, labels map[string]string) (string, error) {
+	if p == nil {
+		return models.SeriesID(name, labels), nil
+	}
+
+	normalized, err := p.normalize(name)
+	if err != nil {
+		return "", err
+	}
+	id := models.SeriesID(normalized, labels)
+	if err := p.checkLength(id, name); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// normalize применяет к имени метрики регистр, набор символов и зарезервированные префиксы.
+func (p *Policy) normalize(name string) (string, error) {
+	if name == "" {
+		return "", ErrEmptyName
+	}
+
+	normalized := name
+	if p.lowercase {
+		normalized = strings.ToLower(normalized)
+	}
+
+	if p.invalid.MatchString(normalized) {
+		if p.replacement == "" {
+			return "", fmt.Errorf("%w: metric %q, invalid characters %q",
+				ErrInvalidChars, name, strings.Join(p.invalid.FindAllString(normalized, -1), ""))
+		}
+		normalized = p.invalid.ReplaceAllLiteralString(normalized, p.replacement)
+	}
+
+	for _, prefix := range p.reservedPrefixes {
+		if strings.HasPrefix(normalized, prefix) {
+			return "", fmt.Errorf("%w: metric %q starts with %q", ErrReservedPrefix, name, prefix)
+		}
+	}
+
+	return normalized, nil
+}
+
+func (p *Policy) checkLength(id, name string) error {
+	if p.maxLength > 0 && len(id) > p.maxLength {
+		return fmt.Errorf("%w: metric %q is longer than %d", ErrNameTooLong, name, p.maxLength)
+	}
+	return nil
+}
+
+// count учитывает ошибку проверки имени в счетчиках политики.
+func (p *Policy) count(err error) {
+	switch {
+	case p == nil, err == nil:
+	case errors.Is(err, ErrNameTooLong):
+		p.rejectedTooLong.Add(1)
+	case errors.Is(err, ErrReservedPrefix):
+		p.rejectedReserved.Add(1)
+	default:
+		p.rejectedInvalid.Add(1)
+	}
+}
+
+// Stats возвращает счетчики записей, отклоненных политикой.
+func (p *Policy) Stats() Stats {
+	if p == nil {
+		return Stats{}
+	}
+	return Stats{
+		RejectedInvalidName:    p.rejectedInvalid.Load(),
+		RejectedNameTooLong:    p.rejectedTooLong.Load(),
+		RejectedReservedPrefix: p.rejectedReserved.Load(),
+		MaxNameLength:          int64(p.maxLength),
+	}
+}
+
+// Rejected возвращает общее количество отклоненных политикой записей.
+func (s Stats) Rejected() int64 {
+	return s.RejectedInvalidName + s.RejectedNameTooLong + s.RejectedReservedPrefix
+}
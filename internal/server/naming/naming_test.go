@@ -0,0 +1,187 @@
+package naming
+
+import (
+	"testing"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Normalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		metric  string
+		want    string
+		wantErr error
+	}{
+		{
+			name:   "default policy accepts agent metrics",
+			metric: "CPUutilization1",
+			want:   "CPUutilization1",
+		},
+		{
+			name:    "default policy rejects spaces",
+			metric:  "heap alloc",
+			wantErr: ErrInvalidChars,
+		},
+		{
+			name:    "default policy rejects unicode",
+			metric:  "память",
+			wantErr: ErrInvalidChars,
+		},
+		{
+			name:    "empty name",
+			metric:  "",
+			wantErr: ErrEmptyName,
+		},
+		{
+			name:   "lowercase",
+			cfg:    config.Config{NameLowercase: true},
+			metric: "HeapAlloc",
+			want:   "heapalloc",
+		},
+		{
+			name:   "replace invalid characters",
+			cfg:    config.Config{NameReplaceInvalid: "_"},
+			metric: "heap alloc/bytes",
+			want:   "heap_alloc_bytes",
+		},
+		{
+			name:    "max length",
+			cfg:     config.Config{MaxNameLength: 4},
+			metric:  "Alloc",
+			wantErr: ErrNameTooLong,
+		},
+		{
+			name:    "reserved prefix",
+			cfg:     config.Config{NameReservedPrefixes: "__, internal."},
+			metric:  "internal.queue",
+			wantErr: ErrReservedPrefix,
+		},
+		{
+			name:    "client names with labels are checked in full",
+			metric:  `requests{code="200"}`,
+			wantErr: ErrInvalidChars,
+		},
+		{
+			name:    "custom charset",
+			cfg:     config.Config{NameAllowedChars: "a-z"},
+			metric:  "Alloc",
+			wantErr: ErrInvalidChars,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(&tt.cfg)
+			require.NoError(t, err)
+
+			got, err := p.Normalize(tt.metric)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPolicy_AdmitSeries(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		metric  string
+		labels  map[string]string
+		want    string
+		wantErr error
+	}{
+		{
+			name:   "labels are kept as is",
+			cfg:    config.Config{NameLowercase: true},
+			metric: "HTTP.requests",
+			labels: map[string]string{"path": "/api v1", "code": "200"},
+			want:   `http.requests{code="200",path="/api v1"}`,
+		},
+		{
+			name:    "labels count towards max length",
+			cfg:     config.Config{MaxNameLength: 10},
+			metric:  "requests",
+			labels:  map[string]string{"code": "200"},
+			wantErr: ErrNameTooLong,
+		},
+		{
+			name:    "metric name is checked",
+			metric:  "http requests",
+			labels:  map[string]string{"code": "200"},
+			wantErr: ErrInvalidChars,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(&tt.cfg)
+			require.NoError(t, err)
+
+			got, err := p.AdmitSeries(tt.metric, tt.labels)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPolicy_Lookup(t *testing.T) {
+	p, err := New(&config.Config{NameLowercase: true})
+	require.NoError(t, err)
+
+	got, err := p.Lookup(`HTTP.requests{code="200",path="/api v1"}`)
+	require.NoError(t, err)
+	assert.Equal(t, `http.requests{code="200",path="/api v1"}`, got)
+
+	_, err = p.Lookup(`http requests{code="200"}`)
+	assert.ErrorIs(t, err, ErrInvalidChars)
+}
+
+func TestNew_AllowedChars(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed string
+		wantErr bool
+	}{
+		{name: "ranges", allowed: `a-z0-9_\-`},
+		{name: "closing bracket", allowed: `a-z]|.*[`, wantErr: true},
+		{name: "opening bracket", allowed: `[:alpha:]`, wantErr: true},
+		{name: "negation", allowed: `^a-z`, wantErr: true},
+		{name: "broken escape", allowed: `a-z\`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(&config.Config{NameAllowedChars: tt.allowed})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestPolicy_Admit(t *testing.T) {
+	p, err := New(&config.Config{MaxNameLength: 8, NameReservedPrefixes: "__"})
+	require.NoError(t, err)
+
+	for _, name := range []string{"Alloc", "heap alloc", "HeapAllocBytes", "__queue", "Pollcnt"} {
+		_, _ = p.Admit(name)
+	}
+	// чтение через Normalize в счетчиках не учитывается.
+	_, err = p.Normalize("heap alloc")
+	require.Error(t, err)
+
+	stats := p.Stats()
+	assert.Equal(t, Stats{
+		RejectedInvalidName:    1,
+		RejectedNameTooLong:    1,
+		RejectedReservedPrefix: 1,
+		MaxNameLength:          8,
+	}, stats)
+	assert.Equal(t, int64(3), stats.Rejected())
+}
@@ -0,0 +1,182 @@
+// Package prompb декодирует WriteRequest протокола Prometheus remote write 1.0.
+// Разбираются только поля, которые использует сервер: метки, значения и тип метрики
+// из метаданных; примеры (exemplars) и нативные гистограммы пропускаются.
+package prompb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// MetricTypeCounter значение MetricMetadata.type для счетчика.
+const MetricTypeCounter = 1
+
+var ErrInvalidMessage = errors.New("invalid remote write message")
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+type MetricMetadata struct {
+	MetricFamilyName string
+	Type             int32
+}
+
+type WriteRequest struct {
+	Timeseries []TimeSeries
+	Metadata   []MetricMetadata
+}
+
+// Unmarshal декодирует WriteRequest из protobuf.
+func Unmarshal(b []byte) (*WriteRequest, error) {
+	req := &WriteRequest{}
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			ts, err := unmarshalTimeSeries(v)
+			if err != nil {
+				return err
+			}
+			req.Timeseries = append(req.Timeseries, ts)
+		case num == 3 && typ == protowire.BytesType:
+			md, err := unmarshalMetadata(v)
+			if err != nil {
+				return err
+			}
+			req.Metadata = append(req.Metadata, md)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func unmarshalTimeSeries(b []byte) (TimeSeries, error) {
+	var ts TimeSeries
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			l, err := unmarshalLabel(v)
+			if err != nil {
+				return err
+			}
+			ts.Labels = append(ts.Labels, l)
+		case num == 2 && typ == protowire.BytesType:
+			s, err := unmarshalSample(v)
+			if err != nil {
+				return err
+			}
+			ts.Samples = append(ts.Samples, s)
+		}
+		return nil
+	})
+	if err != nil {
+		return TimeSeries{}, fmt.Errorf("timeseries: %w", err)
+	}
+	return ts, nil
+}
+
+func unmarshalLabel(b []byte) (Label, error) {
+	var l Label
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			l.Name = string(v)
+		case num == 2 && typ == protowire.BytesType:
+			l.Value = string(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return Label{}, fmt.Errorf("label: %w", err)
+	}
+	return l, nil
+}
+
+func unmarshalSample(b []byte) (Sample, error) {
+	var s Sample
+	err := walk(b, func(num protowire.Number, typ protowire.Type, _ []byte, n uint64) error {
+		switch {
+		case num == 1 && typ == protowire.Fixed64Type:
+			s.Value = math.Float64frombits(n)
+		case num == 2 && typ == protowire.VarintType:
+			s.Timestamp = int64(n)
+		}
+		return nil
+	})
+	if err != nil {
+		return Sample{}, fmt.Errorf("sample: %w", err)
+	}
+	return s, nil
+}
+
+func unmarshalMetadata(b []byte) (MetricMetadata, error) {
+	var md MetricMetadata
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			md.Type = int32(n)
+		case num == 2 && typ == protowire.BytesType:
+			md.MetricFamilyName = string(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return MetricMetadata{}, fmt.Errorf("metadata: %w", err)
+	}
+	return md, nil
+}
+
+// walk обходит поля сообщения. Для полей с длиной передается содержимое v,
+// для varint и fixed - значение n. Группы и неизвестные поля пропускаются.
+func walk(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error) error {
+	for len(b) > 0 {
+		num, typ, l := protowire.ConsumeTag(b)
+		if l < 0 {
+			return fmt.Errorf("%w: %w", ErrInvalidMessage, protowire.ParseError(l))
+		}
+		b = b[l:]
+
+		var v []byte
+		var n uint64
+		switch typ {
+		case protowire.VarintType:
+			n, l = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			n, l = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var n32 uint32
+			n32, l = protowire.ConsumeFixed32(b)
+			n = uint64(n32)
+		case protowire.BytesType:
+			v, l = protowire.ConsumeBytes(b)
+		default:
+			l = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if l < 0 {
+			return fmt.Errorf("%w: %w", ErrInvalidMessage, protowire.ParseError(l))
+		}
+		b = b[l:]
+
+		if err := fn(num, typ, v, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package prompb_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/server/prompb"
+)
+
+func bytesField(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func label(name, value string) []byte {
+	b := bytesField(nil, 1, []byte(name))
+	return bytesField(b, 2, []byte(value))
+}
+
+func sample(v float64, ts int64) []byte {
+	b := protowire.AppendTag(nil, 1, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, math.Float64bits(v))
+	b = protowire.AppendTag(b, 2, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(ts))
+}
+
+func TestUnmarshal(t *testing.T) {
+	ts := bytesField(nil, 1, label("__name__", "http_requests_total"))
+	ts = bytesField(ts, 1, label("job", "api"))
+	ts = bytesField(ts, 2, sample(42, 1718000000000))
+	ts = bytesField(ts, 2, sample(43.5, 1718000015000))
+	// exemplar пропускается
+	ts = bytesField(ts, 3, []byte{})
+
+	md := protowire.AppendTag(nil, 1, protowire.VarintType)
+	md = protowire.AppendVarint(md, prompb.MetricTypeCounter)
+	md = bytesField(md, 2, []byte("http_requests_total"))
+	md = bytesField(md, 4, []byte("Total requests."))
+
+	valid := bytesField(nil, 1, ts)
+	valid = bytesField(valid, 3, md)
+
+	tests := []struct {
+		name    string
+		want    *prompb.WriteRequest
+		data    []byte
+		wantErr bool
+	}{
+		{
+			name: "series and metadata",
+			data: valid,
+			want: &prompb.WriteRequest{
+				Timeseries: []prompb.TimeSeries{{
+					Labels:  []prompb.Label{{Name: "__name__", Value: "http_requests_total"}, {Name: "job", Value: "api"}},
+					Samples: []prompb.Sample{{Value: 42, Timestamp: 1718000000000}, {Value: 43.5, Timestamp: 1718000015000}},
+				}},
+				Metadata: []prompb.MetricMetadata{{MetricFamilyName: "http_requests_total", Type: prompb.MetricTypeCounter}},
+			},
+		},
+		{
+			name: "empty request",
+			data: []byte{},
+			want: &prompb.WriteRequest{},
+		},
+		{
+			name:    "truncated message",
+			data:    valid[:len(valid)-3],
+			wantErr: true,
+		},
+		{
+			name:    "not protobuf",
+			data:    []byte("http_requests_total 42"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := prompb.Unmarshal(tt.data)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, prompb.ErrInvalidMessage)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers"
+)
+
+// DefaultMaxPending сколько различных серий может накопиться у подписчика,
+// прежде чем он будет отключен как не успевающий читать обновления.
+const DefaultMaxPending = 10000
+
+// Hub рассылает принятые записи метрик подписчикам.
+// Публикация никогда не блокируется на медленных подписчиках: обновления одной серии
+// схлопываются, а подписчик с переполненной очередью отключается.
+type Hub struct {
+	subs       map[*Subscriber]struct{}
+	maxPending int
+	mu         sync.RWMutex
+}
+
+func NewHub(maxPending int) *Hub {
+	if maxPending <= 0 {
+		maxPending = DefaultMaxPending
+	}
+	return &Hub{
+		subs:       make(map[*Subscriber]struct{}),
+		maxPending: maxPending,
+	}
+}
+
+// Subscribe регистрирует подписчика. match отбирает интересующие его метрики, nil - все метрики.
+// После окончания чтения подписчика нужно отписать через Unsubscribe.
+func (h *Hub) Subscribe(match func(m *models.Metrics) bool) *Subscriber {
+	sub := &Subscriber{
+		match:      match,
+		pending:    make(map[models.MetricKey]*models.Metrics),
+		notify:     make(chan struct{}, 1),
+		done:       make(chan struct{}),
+		maxPending: h.maxPending,
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subs[sub] = struct{}{}
+	return sub
+}
+
+func (h *Hub) Unsubscribe(sub *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs, sub)
+}
+
+// Publish передает записанные метрики всем подписчикам.
+func (h *Hub) Publish(metrics ...*models.Metrics) {
+	if h == nil {
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subs {
+		sub.push(metrics)
+	}
+}
+
+// Subscriber очередь обновлений одного клиента.
+type Subscriber struct {
+	match      func(m *models.Metrics) bool
+	pending    map[models.MetricKey]*models.Metrics
+	notify     chan struct{}
+	done       chan struct{}
+	order      []models.MetricKey
+	maxPending int
+	dropped    bool
+	mu         sync.Mutex
+}
+
+// Updates сигнализирует о появлении новых обновлений, забрать их можно через Next.
+func (s *Subscriber) Updates() <-chan struct{} {
+	return s.notify
+}
+
+// Dropped закрывается, когда подписчик отключен из-за переполнения очереди.
+func (s *Subscriber) Dropped() <-chan struct{} {
+	return s.done
+}
+
+// Next забирает накопленные обновления в порядке их первого появления.
+func (s *Subscriber) Next() []*models.Metrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]*models.Metrics, 0, len(s.order))
+	for _, key := range s.order {
+		res = append(res, s.pending[key])
+	}
+	s.order = s.order[:0]
+	clear(s.pending)
+	return res
+}
+
+func (s *Subscriber) push(metrics []*models.Metrics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dropped {
+		return
+	}
+
+	for _, m := range metrics {
+		if s.match != nil && !s.match(m) {
+			continue
+		}
+
+		key := models.MetricKey{ID: m.ID, MType: m.MType}
+		prev, ok := s.pending[key]
+		if !ok {
+			if len(s.order) >= s.maxPending {
+				s.dropped = true
+				close(s.done)
+				return
+			}
+			s.order = append(s.order, key)
+			s.pending[key] = clone(m)
+			continue
+		}
+
+		// схлопываем обновления серии: для счетчика суммируем приращения, гистограммы складываем,
+		// для gauge берем последнее значение.
+		switch {
+		case prev.Delta != nil && m.Delta != nil:
+			*prev.Delta += *m.Delta
+		case prev.Histogram != nil && m.Histogram != nil:
+			merged := prev.Histogram.Merge(*m.Histogram)
+			prev.Histogram = &merged
+		default:
+			s.pending[key] = clone(m)
+		}
+	}
+
+	if len(s.order) == 0 {
+		return
+	}
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func clone(m *models.Metrics) *models.Metrics {
+	c := &models.Metrics{ID: m.ID, MType: m.MType}
+	if m.Value != nil {
+		v := *m.Value
+		c.Value = &v
+	}
+	if m.Delta != nil {
+		d := *m.Delta
+		c.Delta = &d
+	}
+	if m.Histogram != nil {
+		h := m.Histogram.Clone()
+		c.Histogram = &h
+	}
+	return c
+}
+
+// storage хранилище, публикующее в Hub каждую успешную запись.
+type storage struct {
+	routers.Storage
+	hub *Hub
+}
+
+// Publishing оборачивает хранилище так, что все успешно сохраненные метрики публикуются в hub.
+func Publishing(s routers.Storage, hub *Hub) routers.Storage {
+	return &storage{Storage: s, hub: hub}
+}
+
+func (s *storage) SaveGauge(ctx context.Context, name string, value float64) error {
+	if err := s.Storage.SaveGauge(ctx, name, value); err != nil {
+		return fmt.Errorf("failed to save gauge: %w", err)
+	}
+	s.hub.Publish(&models.Metrics{ID: name, MType: "gauge", Value: &value})
+	return nil
+}
+
+func (s *storage) SaveCount(ctx context.Context, name string, value int64) error {
+	if err := s.Storage.SaveCount(ctx, name, value); err != nil {
+		return fmt.Errorf("failed to save counter: %w", err)
+	}
+	s.hub.Publish(&models.Metrics{ID: name, MType: "counter", Delta: &value})
+	return nil
+}
+
+func (s *storage) SaveMetrics(ctx context.Context, metrics []*models.Metrics) error {
+	if err := s.Storage.SaveMetrics(ctx, metrics); err != nil {
+		return fmt.Errorf("failed to save metrics: %w", err)
+	}
+	s.hub.Publish(metrics...)
+	return nil
+}
@@ -0,0 +1,113 @@
+package pubsub_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/pubsub"
+)
+
+func gauge(id string, v float64) *models.Metrics {
+	return &models.Metrics{ID: id, MType: "gauge", Value: &v}
+}
+
+func counter(id string, d int64) *models.Metrics {
+	return &models.Metrics{ID: id, MType: "counter", Delta: &d}
+}
+
+func histogram(id string, counts ...uint64) *models.Metrics {
+	h := models.Histogram{Bounds: []float64{1}, Counts: counts}
+	for _, c := range counts {
+		h.Count += c
+	}
+	return &models.Metrics{ID: id, MType: "histogram", Histogram: &h}
+}
+
+func TestHub(t *testing.T) {
+	t.Run("coalesce pending updates", func(t *testing.T) {
+		hub := pubsub.NewHub(10)
+		sub := hub.Subscribe(nil)
+		defer hub.Unsubscribe(sub)
+
+		hub.Publish(gauge("Alloc", 1), counter("PollCount", 2))
+		hub.Publish(gauge("Alloc", 3), counter("PollCount", 5))
+
+		<-sub.Updates()
+		got := sub.Next()
+		require.Len(t, got, 2)
+		assert.Equal(t, "Alloc", got[0].ID)
+		assert.InDelta(t, 3.0, *got[0].Value, 0)
+		assert.Equal(t, "PollCount", got[1].ID)
+		assert.Equal(t, int64(7), *got[1].Delta)
+		assert.Empty(t, sub.Next())
+	})
+
+	t.Run("coalesce histograms", func(t *testing.T) {
+		hub := pubsub.NewHub(10)
+		sub := hub.Subscribe(nil)
+		defer hub.Unsubscribe(sub)
+
+		hub.Publish(histogram("latency", 1, 2))
+		hub.Publish(histogram("latency", 3, 0))
+
+		got := sub.Next()
+		require.Len(t, got, 1)
+		require.NotNil(t, got[0].Histogram)
+		assert.Equal(t, []uint64{4, 2}, got[0].Histogram.Counts)
+		assert.Equal(t, uint64(6), got[0].Histogram.Count)
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		hub := pubsub.NewHub(10)
+		sub := hub.Subscribe(func(m *models.Metrics) bool { return m.MType == "counter" })
+		defer hub.Unsubscribe(sub)
+
+		hub.Publish(gauge("Alloc", 1), counter("PollCount", 2))
+		got := sub.Next()
+		require.Len(t, got, 1)
+		assert.Equal(t, "PollCount", got[0].ID)
+	})
+
+	t.Run("published metric is copied", func(t *testing.T) {
+		hub := pubsub.NewHub(10)
+		sub := hub.Subscribe(nil)
+		defer hub.Unsubscribe(sub)
+
+		m := counter("PollCount", 1)
+		hub.Publish(m)
+		*m.Delta = 100
+		assert.Equal(t, int64(1), *sub.Next()[0].Delta)
+
+		h := histogram("latency", 1, 0)
+		hub.Publish(h)
+		h.Histogram.Counts[0] = 100
+		assert.Equal(t, []uint64{1, 0}, sub.Next()[0].Histogram.Counts)
+	})
+
+	t.Run("drop slow consumer", func(t *testing.T) {
+		hub := pubsub.NewHub(2)
+		slow := hub.Subscribe(nil)
+		defer hub.Unsubscribe(slow)
+		fast := hub.Subscribe(nil)
+		defer hub.Unsubscribe(fast)
+
+		hub.Publish(gauge("a", 1), gauge("b", 1))
+		assert.Len(t, fast.Next(), 2)
+		hub.Publish(gauge("c", 1))
+
+		select {
+		case <-slow.Dropped():
+		default:
+			t.Fatal("slow consumer is not dropped")
+		}
+		select {
+		case <-fast.Dropped():
+			t.Fatal("fast consumer is dropped")
+		default:
+		}
+		assert.Len(t, fast.Next(), 1)
+	})
+}
@@ -0,0 +1,223 @@
+// Package ratelimit ограничивает частоту запросов и количество записываемых метрик для каждого клиента.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+)
+
+var (
+	ErrRequestLimit = errors.New("request rate limit exceeded")
+	ErrMetricLimit  = errors.New("metric rate limit exceeded")
+)
+
+const (
+	// pruneInterval как часто из памяти удаляются клиенты, которые давно не присылали запросов.
+	pruneInterval = time.Minute
+	// defaultMaxClients сколько клиентов хранится в памяти одновременно.
+	defaultMaxClients = 10000
+)
+
+// LimitError ошибка превышения лимита клиентом Client.
+// RetryAfter - через сколько можно повторить запрос.
+type LimitError struct {
+	Err        error
+	Client     string
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v for %s, retry after %s", e.Err, e.Client, e.RetryAfter)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// bucket token bucket: пополняется со скоростью rate в секунду до burst токенов.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take списывает n токенов. Если токенов не хватает, ничего не списывает
+// и возвращает, через сколько их станет достаточно.
+// Батч больше burst пропускается при полном бакете, а баланс уходит в минус,
+// поэтому средняя скорость все равно не превышает rate.
+func (b *bucket) take(n, rate, burst float64, now time.Time) time.Duration {
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	need := math.Min(n, burst)
+	if b.tokens < need {
+		return time.Duration((need - b.tokens) / rate * float64(time.Second))
+	}
+	b.tokens -= n
+	return 0
+}
+
+type client struct {
+	requests bucket
+	metrics  bucket
+	seen     time.Time
+}
+
+// Limiter ограничивает для каждого клиента количество запросов и количество метрик в секунду.
+// Нулевое значение лимита означает отсутствие ограничения.
+// Если burst не задан, клиент может разово превысить лимит не больше чем на одну секунду.
+type Limiter struct {
+	clients map[string]*client
+
+	requestRate, requestBurst float64
+	metricRate, metricBurst   float64
+
+	// idle время, за которое бакеты любого клиента полностью пополняются.
+	// Клиента, не присылавшего запросов дольше, можно забыть без изменения поведения.
+	idle time.Duration
+
+	mu         sync.Mutex
+	lastPrune  time.Time
+	maxClients int
+	now        func() time.Time
+}
+
+func New(cfg *config.Config) *Limiter {
+	l := &Limiter{
+		clients:      make(map[string]*client),
+		requestRate:  float64(cfg.RateLimitRequests),
+		requestBurst: burst(cfg.RateLimitRequests, cfg.RateLimitRequestsBurst),
+		metricRate:   float64(cfg.RateLimitMetrics),
+		metricBurst:  burst(cfg.RateLimitMetrics, cfg.RateLimitMetricsBurst),
+		maxClients:   defaultMaxClients,
+		now:          time.Now,
+	}
+	for _, b := range [][2]float64{{l.requestRate, l.requestBurst}, {l.metricRate, l.metricBurst}} {
+		if b[0] > 0 {
+			l.idle = max(l.idle, time.Duration(b[1]/b[0]*float64(time.Second)))
+		}
+	}
+	return l
+}
+
+func burst(rate, b int64) float64 {
+	if b <= 0 {
+		return float64(rate)
+	}
+	return float64(b)
+}
+
+// Enabled сообщает, задан ли хотя бы один лимит.
+func (l *Limiter) Enabled() bool {
+	return l != nil && (l.requestRate > 0 || l.metricRate > 0)
+}
+
+// AllowRequest учитывает запрос клиента и возвращает *LimitError, если лимит запросов исчерпан.
+func (l *Limiter) AllowRequest(clientID string) error {
+	if l == nil || l.requestRate <= 0 {
+		return nil
+	}
+	return l.take(clientID, 1, ErrRequestLimit)
+}
+
+// AllowMetrics учитывает n записываемых клиентом метрик и возвращает *LimitError,
+// если лимит метрик исчерпан. Батч либо учитывается целиком, либо отклоняется целиком.
+func (l *Limiter) AllowMetrics(clientID string, n int) error {
+	if l == nil || l.metricRate <= 0 || n <= 0 {
+		return nil
+	}
+	return l.take(clientID, float64(n), ErrMetricLimit)
+}
+
+func (l *Limiter) take(clientID string, n float64, kind error) error {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(now)
+	c, ok := l.clients[clientID]
+	if !ok {
+		l.makeRoom(now)
+		c = &client{
+			requests: bucket{tokens: l.requestBurst, last: now},
+			metrics:  bucket{tokens: l.metricBurst, last: now},
+		}
+		l.clients[clientID] = c
+	}
+	c.seen = now
+
+	var wait time.Duration
+	if errors.Is(kind, ErrRequestLimit) {
+		wait = c.requests.take(n, l.requestRate, l.requestBurst, now)
+	} else {
+		wait = c.metrics.take(n, l.metricRate, l.metricBurst, now)
+	}
+	if wait > 0 {
+		return &LimitError{Err: kind, Client: clientID, RetryAfter: wait}
+	}
+	return nil
+}
+
+// prune раз в pruneInterval удаляет клиентов, бакеты которых уже полностью пополнились.
+// Вызывается под мьютексом.
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < pruneInterval {
+		return
+	}
+	l.lastPrune = now
+	l.removeIdle(now)
+}
+
+func (l *Limiter) removeIdle(now time.Time) {
+	for id, c := range l.clients {
+		if now.Sub(c.seen) > l.idle {
+			delete(l.clients, id)
+		}
+	}
+}
+
+// makeRoom освобождает место для нового клиента, если в памяти уже maxClients клиентов:
+// сначала удаляются клиенты с полными бакетами, а если таких нет - клиент, дольше всех
+// не присылавший запросов. Вызывается под мьютексом.
+func (l *Limiter) makeRoom(now time.Time) {
+	if len(l.clients) < l.maxClients {
+		return
+	}
+	l.removeIdle(now)
+	if len(l.clients) < l.maxClients {
+		return
+	}
+
+	var oldest string
+	var seen time.Time
+	for id, c := range l.clients {
+		if oldest == "" || c.seen.Before(seen) {
+			oldest, seen = id, c.seen
+		}
+	}
+	delete(l.clients, oldest)
+}
+
+type limiterKey struct{}
+
+// WithLimiter сохраняет в контексте запроса ограничитель,
+// чтобы обработчики могли учесть количество записываемых метрик через AllowMetricsContext.
+func WithLimiter(ctx context.Context, l *Limiter) context.Context {
+	return context.WithValue(ctx, limiterKey{}, l)
+}
+
+// AllowMetricsContext учитывает n метрик клиента clientID ограничителем, сохраненным в контексте
+// через WithLimiter. Если ограничителя в контексте нет, метрики не ограничиваются.
+func AllowMetricsContext(ctx context.Context, clientID string, n int) error {
+	l, ok := ctx.Value(limiterKey{}).(*Limiter)
+	if !ok {
+		return nil
+	}
+	return l.AllowMetrics(clientID, n)
+}
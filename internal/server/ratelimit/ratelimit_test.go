@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+)
+
+// clock подменяет время лимитера.
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func newLimiter(cfg *config.Config) (*Limiter, *clock) {
+	c := &clock{t: time.Unix(1700000000, 0)}
+	l := New(cfg)
+	l.now = c.now
+	return l, c
+}
+
+func retryAfter(t *testing.T, err error) time.Duration {
+	t.Helper()
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	return limitErr.RetryAfter
+}
+
+func TestAllowRequest(t *testing.T) {
+	l, c := newLimiter(&config.Config{RateLimitRequests: 2, RateLimitRequestsBurst: 3})
+
+	for range 3 {
+		require.NoError(t, l.AllowRequest("a"))
+	}
+	err := l.AllowRequest("a")
+	require.ErrorIs(t, err, ErrRequestLimit)
+	assert.Equal(t, 500*time.Millisecond, retryAfter(t, err))
+
+	// лимит считается для каждого клиента отдельно.
+	require.NoError(t, l.AllowRequest("b"))
+
+	c.t = c.t.Add(500 * time.Millisecond)
+	require.NoError(t, l.AllowRequest("a"))
+	require.ErrorIs(t, l.AllowRequest("a"), ErrRequestLimit)
+
+	// бакет пополняется не больше чем до burst.
+	c.t = c.t.Add(time.Hour)
+	for range 3 {
+		require.NoError(t, l.AllowRequest("a"))
+	}
+	require.ErrorIs(t, l.AllowRequest("a"), ErrRequestLimit)
+}
+
+func TestAllowMetrics(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   *config.Config
+		sizes []int
+		want  []time.Duration
+	}{
+		{
+			name:  "unlimited",
+			cfg:   &config.Config{},
+			sizes: []int{1000, 1000},
+			want:  []time.Duration{0, 0},
+		},
+		{
+			name:  "burst defaults to rate",
+			cfg:   &config.Config{RateLimitMetrics: 10},
+			sizes: []int{6, 4, 5},
+			want:  []time.Duration{0, 0, 500 * time.Millisecond},
+		},
+		{
+			name:  "rejected batch is not counted",
+			cfg:   &config.Config{RateLimitMetrics: 10},
+			sizes: []int{8, 5, 2},
+			want:  []time.Duration{0, 300 * time.Millisecond, 0},
+		},
+		{
+			// батч больше burst принимается при полном бакете, а следующий ждет, пока долг не погасится.
+			name:  "batch above burst",
+			cfg:   &config.Config{RateLimitMetrics: 10},
+			sizes: []int{30, 1},
+			want:  []time.Duration{0, 2100 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, _ := newLimiter(tt.cfg)
+			for i, n := range tt.sizes {
+				err := l.AllowMetrics("a", n)
+				if tt.want[i] == 0 {
+					require.NoError(t, err, i)
+					continue
+				}
+				require.ErrorIs(t, err, ErrMetricLimit, i)
+				assert.Equal(t, tt.want[i], retryAfter(t, err), i)
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	l, c := newLimiter(&config.Config{RateLimitRequests: 1, RateLimitMetrics: 10, RateLimitMetricsBurst: 100})
+	require.NoError(t, l.AllowRequest("a"))
+	require.NoError(t, l.AllowMetrics("b", 100))
+
+	// бакет метрик пополняется за 10 секунд, только после этого клиента можно забыть.
+	c.t = c.t.Add(2 * pruneInterval)
+	require.NoError(t, l.AllowRequest("c"))
+	assert.NotContains(t, l.clients, "a")
+	assert.NotContains(t, l.clients, "b")
+	assert.Contains(t, l.clients, "c")
+}
+
+func TestMaxClients(t *testing.T) {
+	l, c := newLimiter(&config.Config{RateLimitRequests: 1, RateLimitRequestsBurst: 10})
+	l.maxClients = 2
+
+	require.NoError(t, l.AllowRequest("a"))
+	c.t = c.t.Add(time.Second)
+	require.NoError(t, l.AllowRequest("b"))
+	c.t = c.t.Add(time.Second)
+	require.NoError(t, l.AllowRequest("a"))
+
+	// при заполненной памяти забывается клиент, дольше всех не присылавший запросов.
+	c.t = c.t.Add(time.Second)
+	require.NoError(t, l.AllowRequest("c"))
+	assert.Len(t, l.clients, 2)
+	assert.Contains(t, l.clients, "a")
+	assert.NotContains(t, l.clients, "b")
+
+	// клиенты с полными бакетами удаляются раньше остальных.
+	c.t = c.t.Add(11 * time.Second)
+	require.NoError(t, l.AllowRequest("d"))
+	assert.Equal(t, []string{"d"}, keys(l))
+}
+
+func keys(l *Limiter) []string {
+	ids := make([]string, 0, len(l.clients))
+	for id := range l.clients {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestAllowMetricsContext(t *testing.T) {
+	l, _ := newLimiter(&config.Config{RateLimitMetrics: 2})
+
+	require.NoError(t, AllowMetricsContext(context.Background(), "a", 100))
+
+	ctx := WithLimiter(context.Background(), l)
+	require.NoError(t, AllowMetricsContext(ctx, "a", 2))
+	require.ErrorIs(t, AllowMetricsContext(ctx, "a", 1), ErrMetricLimit)
+	require.NoError(t, AllowMetricsContext(ctx, "b", 1))
+}
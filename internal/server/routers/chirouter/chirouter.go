@@ -1,46 +1,264 @@
 package chirouter
 
 import (
+	"fmt"
+	"net/http"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/server/auth"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cardinality"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cumulative"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers/dashboard"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers/influx"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers/limits"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers/metrics"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers/openapi"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers/otlp"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers/ping"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers/remotewrite"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers/remove"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers/stream"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers/update"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/history"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/keyring"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/middleware/bodylimit"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/middleware/compressor"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/middleware/decryptor"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/middleware/logger"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/middleware/signature"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/middleware/subnet"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/middleware/throttle"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/naming"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/pubsub"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/ratelimit"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/routers"
+	"github.com/VanGoghDev/practicum-metrics/internal/util/encryption"
 	"github.com/go-chi/chi"
 	"go.uber.org/zap"
 )
 
-func BuildRouter(s routers.Storage, log *zap.Logger, cfg *config.Config) chi.Router {
-	r := chi.NewRouter()
+// Option настраивает необязательные компоненты роутера.
+type Option func(o *options)
+
+type options struct {
+	history *history.Recorder
+	hub     *pubsub.Hub
+	limiter *cardinality.Limiter
+	keys    *keyring.Ring
+	auth    *auth.Authenticator
+	policy  *naming.Policy
+	rates   *ratelimit.Limiter
+}
+
+// WithHistory задает источник истории значений метрик для графиков дашборда.
+func WithHistory(h *history.Recorder) Option {
+	return func(o *options) {
+		o.history = h
+	}
+}
+
+// WithHub задает хаб, в который публикуются принятые записи метрик.
+// По умолчанию роутер создает собственный хаб.
+func WithHub(hub *pubsub.Hub) Option {
+	return func(o *options) {
+		o.hub = hub
+	}
+}
+
+// WithLimiter задает ограничитель кардинальности, общий с другими приемниками метрик.
+// По умолчанию роутер создает собственный ограничитель.
+func WithLimiter(l *cardinality.Limiter) Option {
+	return func(o *options) {
+		o.limiter = l
+	}
+}
+
+// WithKeyRing задает набор ключей подписи запросов, например перечитываемый по SIGHUP.
+// По умолчанию роутер загружает ключи из конфигурации.
+func WithKeyRing(keys *keyring.Ring) Option {
+	return func(o *options) {
+		o.keys = keys
+	}
+}
+
+// WithAuth задает проверку API токенов, общую с другими API сервера.
+// По умолчанию роутер создает ее из конфигурации.
+func WithAuth(a *auth.Authenticator) Option {
+	return func(o *options) {
+		o.auth = a
+	}
+}
+
+// WithPolicy задает политику именования, общую с другими приемниками метрик.
+// По умолчанию роутер создает ее из конфигурации.
+func WithPolicy(p *naming.Policy) Option {
+	return func(o *options) {
+		o.policy = p
+	}
+}
+
+// WithRateLimiter задает ограничитель частоты запросов и метрик, общий с gRPC API.
+// По умолчанию роутер создает его из конфигурации.
+func WithRateLimiter(l *ratelimit.Limiter) Option {
+	return func(o *options) {
+		o.rates = l
+	}
+}
+
+func BuildRouter(s routers.Storage, log *zap.Logger, cfg *config.Config, opts ...Option) (chi.Router, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.hub == nil {
+		o.hub = pubsub.NewHub(pubsub.DefaultMaxPending)
+	}
 	sugarlog := log.Sugar()
+	if o.auth == nil {
+		a, err := auth.FromConfig(sugarlog, cfg, s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init api tokens: %w", err)
+		}
+		o.auth = a
+	}
+	s = pubsub.Publishing(s, o.hub)
+	if o.keys == nil {
+		keys, err := keyring.New(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load signature keys: %w", err)
+		}
+		o.keys = keys
+	}
+
+	var cryptoKey *encryption.PrivateKey
+	if cfg.CryptoKey != "" {
+		var err error
+		cryptoKey, err = encryption.LoadPrivateKey(cfg.CryptoKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load crypto key: %w", err)
+		}
+	}
+
+	trustedSubnet, err := subnet.NewFilter(cfg.TrustedSubnet)
+	if err != nil {
+		return nil, err
+	}
+
+	r := chi.NewRouter()
 	r.Use(logger.New(sugarlog))
-	r.Use(signature.New(sugarlog, cfg))
-	r.Use(compressor.New(sugarlog))
+	// лишние запросы отклоняются до чтения тела и проверки подписи.
+	rates := o.rates
+	if rates == nil {
+		rates = ratelimit.New(cfg)
+	}
+	r.Use(throttle.New(sugarlog, rates))
+	r.Use(bodylimit.New(sugarlog, cfg.MaxBodySize))
 
-	r.Route("/", func(r chi.Router) {
-		r.Get("/", metrics.MetricsHandler(sugarlog, s))
-	})
+	// codec расшифровывает и распаковывает тело запроса и сжимает ответ.
+	codec := chi.Middlewares{decryptor.New(sugarlog, cryptoKey), compressor.New(sugarlog, cfg.MaxDecompressedSize)}
+	// Подпись HashSHA256 ставит только агент, поэтому она проверяется лишь на маршрутах, в которые пишет агент.
+	// Агент подписывает тело в том виде, в котором отправил его по сети, поэтому подпись проверяется до codec.
+	// Дашборд, ping, поток и приемники Prometheus, OTLP и Influx подпись не требуют.
+	agent := append(chi.Middlewares{signature.New(sugarlog, cfg, o.keys)}, codec...)
 
-	r.Route("/value", func(r chi.Router) {
-		r.Post("/", metrics.MetricHandler(sugarlog, s))
-		r.Get("/{type}/{name}", metrics.MetricHandlerRouterParams(sugarlog, s))
-	})
+	limiter := o.limiter
+	if limiter == nil {
+		limiter = cardinality.New(cfg, s)
+	}
+	tracker := cumulative.New(cfg)
+	policy := o.policy
+	if policy == nil {
+		var err error
+		policy, err = naming.New(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init naming policy: %w", err)
+		}
+	}
+
+	// запись метрик принимается только из доверенной подсети.
+	trusted := subnet.New(sugarlog, trustedSubnet)
+	reader := o.auth.Require(auth.RoleReader)
+	writer := o.auth.Require(auth.RoleWriter)
+	admin := o.auth.Require(auth.RoleAdmin)
+
+	r.Group(func(r chi.Router) {
+		r.Use(agent...)
+
+		r.Route("/update", func(r chi.Router) {
+			r.Use(trusted, writer)
+			r.Post("/", update.UpdateHandler(sugarlog, s, limiter, policy))
+			r.Post("/{type}/{name}/{value}", update.UpdateHandlerRouteParams(sugarlog, s, limiter, policy))
+		})
 
-	r.Route("/update", func(r chi.Router) {
-		r.Post("/", update.UpdateHandler(sugarlog, s))
-		r.Post("/{type}/{name}/{value}", update.UpdateHandlerRouteParams(sugarlog, s))
+		r.Route("/updates", func(r chi.Router) {
+			r.Use(trusted, writer)
+			r.Post("/", update.UpdatesHandler(sugarlog, s, limiter, policy, cfg.MaxBatchSize))
+		})
 	})
 
-	r.Route("/updates", func(r chi.Router) {
-		r.Post("/", update.UpdatesHandler(sugarlog, s))
+	r.Group(func(r chi.Router) {
+		r.Use(codec...)
+
+		r.Route("/", func(r chi.Router) {
+			r.With(reader).Get("/", dashboard.Handler(sugarlog, s, o.history))
+		})
+
+		r.Handle("/static/*", http.StripPrefix("/static/", dashboard.StaticHandler()))
+
+		r.Route("/value", func(r chi.Router) {
+			r.Use(reader)
+			r.Post("/", metrics.MetricHandler(sugarlog, s, policy))
+			r.Get("/{type}/{name}", metrics.MetricHandlerRouterParams(sugarlog, s, policy))
+		})
+
+		r.Route("/values", func(r chi.Router) {
+			r.Use(reader)
+			r.Post("/", metrics.ValuesHandler(sugarlog, s, policy))
+		})
+
+		r.Route("/ping", func(r chi.Router) {
+			r.Get("/", ping.PingHandler(sugarlog, cfg, s))
+		})
+
+		r.Route("/api/v2", func(r chi.Router) {
+			r.With(trusted, writer).Post("/write", influx.WriteHandler(sugarlog, s, limiter, policy, tracker))
+		})
+
+		r.Route("/v1", func(r chi.Router) {
+			r.With(trusted, writer).Post("/metrics", otlp.MetricsHandler(sugarlog, s, limiter, policy, tracker))
+		})
+
+		r.Route("/limits", func(r chi.Router) {
+			r.With(admin).Get("/", limits.StatsHandler(sugarlog, limiter, policy))
+		})
 	})
 
-	r.Route("/ping", func(r chi.Router) {
-		r.Get("/", ping.PingHandler(sugarlog, cfg, s))
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Group(func(r chi.Router) {
+			r.Use(codec...)
+			r.Get("/openapi.json", openapi.SpecHandler(sugarlog))
+			r.Get("/ping", ping.PingHandler(sugarlog, cfg, s))
+			r.With(reader).Get("/stream", stream.StreamHandler(sugarlog, o.hub))
+			r.With(trusted, writer).Post("/write", remotewrite.WriteHandler(sugarlog, s, limiter, policy, tracker))
+		})
+
+		// в /api/v1/metrics агент пишет, а остальные клиенты читают и удаляют метрики без подписи.
+		r.Route("/metrics", func(r chi.Router) {
+			r.Group(func(r chi.Router) {
+				r.Use(agent...)
+				r.Use(trusted, writer)
+				r.Post("/", update.UpdatesHandler(sugarlog, s, limiter, policy, cfg.MaxBatchSize))
+				r.Put("/{type}/{name}", update.PutHandler(sugarlog, s, limiter, policy))
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(codec...)
+				r.With(reader).Get("/", metrics.ListHandler(sugarlog, s))
+				r.With(reader).Post("/lookup", metrics.ValuesHandler(sugarlog, s, policy))
+				r.With(reader).Get("/{type}/{name}", metrics.GetHandler(sugarlog, s, policy))
+				r.With(admin).Delete("/{type}/{name}", remove.DeleteHandler(sugarlog, s, limiter, policy, tracker))
+			})
+		})
 	})
 
-	return r
+	return r, nil
 }
@@ -14,6 +14,9 @@ type Storage interface {
 	Counters(ctx context.Context) (counters []models.Counter, err error)
 	Gauge(ctx context.Context, name string) (gauge models.Gauge, err error)
 	Counter(ctx context.Context, name string) (counter models.Counter, err error)
+	DeleteMetric(ctx context.Context, mType string, name string) (err error)
+	ListMetrics(ctx context.Context, q *models.ListQuery) (metrics []models.Metrics, err error)
+	MetricsByKeys(ctx context.Context, keys []models.MetricKey) (metrics []models.Metrics, err error)
 	Close(ctx context.Context) error
 	Ping(ctx context.Context) error
 }
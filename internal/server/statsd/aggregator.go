@@ -0,0 +1,170 @@
+package statsd
+
+import (
+	"math"
+	"slices"
+	"sync"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+)
+
+// percentiles процентили, которые вычисляются для таймеров и гистограмм.
+var percentiles = []struct {
+	suffix string
+	p      float64
+}{
+	{".p50", 50},
+	{".p95", 95},
+	{".p99", 99},
+}
+
+// series серия StatsD: имя метрики и ее теги.
+type series struct {
+	tags map[string]string
+	name string
+}
+
+func (s series) id(suffix string) string {
+	return models.SeriesID(s.name+suffix, s.tags)
+}
+
+type counter struct {
+	series
+	value float64
+}
+
+type gauge struct {
+	series
+	value float64
+	dirty bool // значение менялось с момента последнего сброса
+}
+
+type timer struct {
+	series
+	values []float64
+	count  float64 // количество значений с учетом частоты семплирования
+}
+
+// Aggregator накапливает значения StatsD между сбросами в хранилище.
+// Счетчики суммируются, для gauge берется последнее значение, для таймеров и гистограмм
+// считаются количество, минимум, максимум, среднее и процентили.
+type Aggregator struct {
+	counters map[string]*counter
+	gauges   map[string]*gauge
+	timers   map[string]*timer
+	mu       sync.Mutex
+}
+
+func NewAggregator() *Aggregator {
+	return &Aggregator{
+		counters: make(map[string]*counter),
+		gauges:   make(map[string]*gauge),
+		timers:   make(map[string]*timer),
+	}
+}
+
+// Add учитывает значение в текущем интервале.
+func (a *Aggregator) Add(s *Sample) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := models.SeriesID(s.Name, s.Tags)
+	sr := series{name: s.Name, tags: s.Tags}
+
+	switch s.Type {
+	case TypeCounter:
+		c, ok := a.counters[key]
+		if !ok {
+			c = &counter{series: sr}
+			a.counters[key] = c
+		}
+		c.value += s.Value / s.Rate
+	case TypeGauge:
+		// значение gauge сохраняется между интервалами, чтобы относительные изменения
+		// применялись к последнему известному значению.
+		g, ok := a.gauges[key]
+		if !ok {
+			g = &gauge{series: sr}
+			a.gauges[key] = g
+		}
+		if s.Relative {
+			g.value += s.Value
+		} else {
+			g.value = s.Value
+		}
+		g.dirty = true
+	case TypeTimer, TypeHistogram:
+		t, ok := a.timers[key]
+		if !ok {
+			t = &timer{series: sr}
+			a.timers[key] = t
+		}
+		t.values = append(t.values, s.Value)
+		t.count += 1 / s.Rate
+	}
+}
+
+// Flush возвращает метрики, накопленные за интервал, и начинает новый интервал.
+func (a *Aggregator) Flush() []*models.Metrics {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	metrics := make([]*models.Metrics, 0, len(a.counters)+len(a.gauges)+len(a.timers)*(4+len(percentiles)))
+	// сумма конечных значений может переполниться, такие значения не записываются.
+	addGauge := func(id string, v float64) {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return
+		}
+		metrics = append(metrics, &models.Metrics{ID: id, MType: "gauge", Value: &v})
+	}
+	addCounter := func(id string, v float64) {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return
+		}
+		d := int64(math.Round(v))
+		if d == 0 {
+			return
+		}
+		metrics = append(metrics, &models.Metrics{ID: id, MType: "counter", Delta: &d})
+	}
+
+	for _, c := range a.counters {
+		addCounter(c.id(""), c.value)
+	}
+	clear(a.counters)
+
+	for key, g := range a.gauges {
+		// gauge, не обновлявшийся целый интервал, забывается, иначе набор gauge рос бы без ограничений.
+		// Относительное изменение после этого применяется к нулю.
+		if !g.dirty {
+			delete(a.gauges, key)
+			continue
+		}
+		addGauge(g.id(""), g.value)
+		g.dirty = false
+	}
+
+	for _, t := range a.timers {
+		slices.Sort(t.values)
+		var sum float64
+		for _, v := range t.values {
+			sum += v
+		}
+		addCounter(t.id(".count"), t.count)
+		addGauge(t.id(".min"), t.values[0])
+		addGauge(t.id(".max"), t.values[len(t.values)-1])
+		addGauge(t.id(".mean"), sum/float64(len(t.values)))
+		for _, p := range percentiles {
+			addGauge(t.id(p.suffix), percentile(t.values, p.p))
+		}
+	}
+	clear(a.timers)
+
+	return metrics
+}
+
+// percentile возвращает процентиль отсортированной выборки методом ближайшего ранга.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
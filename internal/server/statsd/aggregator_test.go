@@ -0,0 +1,81 @@
+package statsd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+)
+
+func flushed(metrics []*models.Metrics) map[string]float64 {
+	res := make(map[string]float64, len(metrics))
+	for _, m := range metrics {
+		if m.Delta != nil {
+			res[m.MType+":"+m.ID] = float64(*m.Delta)
+		} else {
+			res[m.MType+":"+m.ID] = *m.Value
+		}
+	}
+	return res
+}
+
+func TestAggregator(t *testing.T) {
+	agg := NewAggregator()
+	lines := []string{
+		"requests:1|c",
+		"requests:1|c|@0.5",
+		"requests:1|c|#env:prod",
+		"queue:10|g",
+		"queue:+5|g",
+		"queue:-2|g",
+		"latency:10|ms",
+		"latency:30|ms",
+		"latency:20|ms|@0.5",
+	}
+	for _, line := range lines {
+		s, err := Parse(line)
+		require.NoError(t, err)
+		agg.Add(s)
+	}
+
+	assert.Equal(t, map[string]float64{
+		"counter:requests":             3,
+		`counter:requests{env="prod"}`: 1,
+		"gauge:queue":                  13,
+		"counter:latency.count":        4,
+		"gauge:latency.min":            10,
+		"gauge:latency.max":            30,
+		"gauge:latency.mean":           20,
+		"gauge:latency.p50":            20,
+		"gauge:latency.p95":            30,
+		"gauge:latency.p99":            30,
+	}, flushed(agg.Flush()))
+
+	add := func(line string) {
+		s, err := Parse(line)
+		require.NoError(t, err)
+		agg.Add(s)
+	}
+
+	t.Run("next interval", func(t *testing.T) {
+		add("queue:+1|g")
+		assert.Equal(t, map[string]float64{"gauge:queue": 14}, flushed(agg.Flush()))
+	})
+
+	t.Run("idle gauge is evicted", func(t *testing.T) {
+		assert.Empty(t, agg.Flush())
+		assert.Empty(t, agg.gauges)
+
+		add("queue:+1|g")
+		assert.Equal(t, map[string]float64{"gauge:queue": 1}, flushed(agg.Flush()))
+	})
+
+	t.Run("overflow is not flushed", func(t *testing.T) {
+		add("queue:1e308|g")
+		add("queue:+1e308|g")
+		add("requests:1e308|c|@0.1")
+		assert.Empty(t, agg.Flush())
+	})
+}
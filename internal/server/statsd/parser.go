@@ -0,0 +1,92 @@
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Типы метрик StatsD.
+const (
+	TypeCounter   = "c"
+	TypeGauge     = "g"
+	TypeTimer     = "ms"
+	TypeHistogram = "h"
+)
+
+var (
+	ErrInvalidLine = errors.New("invalid statsd line")
+	ErrInvalidType = errors.New("unsupported statsd metric type")
+)
+
+// Sample одно значение, полученное в строке StatsD:
+// <name>:<value>|<type>[|@<sample rate>][|#<tag>:<value>,<tag>].
+type Sample struct {
+	Tags     map[string]string // теги DogStatsD
+	Name     string
+	Type     string
+	Value    float64
+	Rate     float64 // частота семплирования, значение считается отправленным с вероятностью Rate
+	Relative bool    // для gauge: значение со знаком изменяет текущее, а не заменяет его
+}
+
+// Parse разбирает одну строку протокола StatsD.
+// Неизвестные секции после типа (например, |c:<container id> или |T<timestamp> DogStatsD) пропускаются.
+func Parse(line string) (*Sample, error) {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" {
+		return nil, fmt.Errorf("%w %q: no metric name", ErrInvalidLine, line)
+	}
+
+	sections := strings.Split(rest, "|")
+	if len(sections) < 2 {
+		return nil, fmt.Errorf("%w %q: no metric type", ErrInvalidLine, line)
+	}
+
+	s := &Sample{Name: name, Type: sections[1], Rate: 1}
+	switch s.Type {
+	case TypeCounter, TypeGauge, TypeTimer, TypeHistogram:
+	default:
+		return nil, fmt.Errorf("%w %q in %q", ErrInvalidType, s.Type, line)
+	}
+
+	raw := sections[0]
+	if s.Type == TypeGauge && (strings.HasPrefix(raw, "+") || strings.HasPrefix(raw, "-")) {
+		s.Relative = true
+	}
+	// NaN и бесконечности не сохраняются: их нельзя отдать в JSON ответах API.
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil, fmt.Errorf("%w %q: invalid value %q", ErrInvalidLine, line, raw)
+	}
+	s.Value = v
+
+	for _, section := range sections[2:] {
+		switch {
+		case strings.HasPrefix(section, "@"):
+			rate, err := strconv.ParseFloat(section[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return nil, fmt.Errorf("%w %q: invalid sample rate %q", ErrInvalidLine, line, section)
+			}
+			s.Rate = rate
+		case strings.HasPrefix(section, "#"):
+			s.Tags = parseTags(section[1:])
+		}
+	}
+
+	return s, nil
+}
+
+func parseTags(s string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(s, ",") {
+		if tag == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(tag, ":")
+		tags[k] = v
+	}
+	return tags
+}
@@ -0,0 +1,103 @@
+package statsd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		want    *Sample
+		wantErr error
+		name    string
+		line    string
+	}{
+		{
+			name: "counter",
+			line: "requests:1|c",
+			want: &Sample{Name: "requests", Type: TypeCounter, Value: 1, Rate: 1},
+		},
+		{
+			name: "counter with sample rate",
+			line: "requests:2|c|@0.1",
+			want: &Sample{Name: "requests", Type: TypeCounter, Value: 2, Rate: 0.1},
+		},
+		{
+			name: "gauge",
+			line: "queue.size:42.5|g",
+			want: &Sample{Name: "queue.size", Type: TypeGauge, Value: 42.5, Rate: 1},
+		},
+		{
+			name: "relative gauge",
+			line: "queue.size:-3|g",
+			want: &Sample{Name: "queue.size", Type: TypeGauge, Value: -3, Rate: 1, Relative: true},
+		},
+		{
+			name: "timer with tags",
+			line: "latency:320|ms|@0.5|#env:prod,region:eu,canary",
+			want: &Sample{
+				Name: "latency", Type: TypeTimer, Value: 320, Rate: 0.5,
+				Tags: map[string]string{"env": "prod", "region": "eu", "canary": ""},
+			},
+		},
+		{
+			name: "histogram with unknown dogstatsd sections",
+			line: "size:10|h|#env:dev|c:abc123|T1656581400",
+			want: &Sample{Name: "size", Type: TypeHistogram, Value: 10, Rate: 1, Tags: map[string]string{"env": "dev"}},
+		},
+		{
+			name:    "no type",
+			line:    "requests:1",
+			wantErr: ErrInvalidLine,
+		},
+		{
+			name:    "no name",
+			line:    ":1|c",
+			wantErr: ErrInvalidLine,
+		},
+		{
+			name:    "invalid value",
+			line:    "requests:abc|c",
+			wantErr: ErrInvalidLine,
+		},
+		{
+			name:    "NaN gauge",
+			line:    "queue.size:NaN|g",
+			wantErr: ErrInvalidLine,
+		},
+		{
+			name:    "infinite gauge",
+			line:    "queue.size:+Inf|g",
+			wantErr: ErrInvalidLine,
+		},
+		{
+			name:    "infinite timer",
+			line:    "latency:inf|ms",
+			wantErr: ErrInvalidLine,
+		},
+		{
+			name:    "invalid sample rate",
+			line:    "requests:1|c|@2",
+			wantErr: ErrInvalidLine,
+		},
+		{
+			name:    "sets are not supported",
+			line:    "users:42|s",
+			wantErr: ErrInvalidType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.line)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package statsd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cardinality"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/middleware/subnet"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/naming"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/routers"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultFlushInterval = 10 * time.Second
+	maxPacketSize        = 64 * 1024
+)
+
+// Server принимает метрики StatsD по UDP и TCP на одном адресе
+// и раз в интервал сбрасывает агрегированные значения в хранилище.
+type Server struct {
+	zlog     *zap.SugaredLogger
+	storage  routers.Storage
+	limiter  *cardinality.Limiter
+	policy   *naming.Policy
+	trusted  *subnet.Filter
+	agg      *Aggregator
+	udp      net.PacketConn
+	tcp      net.Listener
+	address  string
+	interval time.Duration
+}
+
+// source источник, от имени которого записи StatsD учитываются в лимитах кардинальности.
+// Значения разных отправителей агрегируются вместе, поэтому отдельного отправителя у серии нет.
+const source = "statsd"
+
+// New создает сервер. Ограничитель кардинальности и политика именования общие с HTTP API.
+// Как и в HTTP API, метрики принимаются только из доверенной подсети cfg.TrustedSubnet.
+func New(zlog *zap.SugaredLogger, cfg *config.Config, s routers.Storage, limiter *cardinality.Limiter,
+	policy *naming.Policy) (*Server, error) {
+	interval := cfg.StatsdFlushInterval
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+	trusted, err := subnet.NewFilter(cfg.TrustedSubnet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		zlog:     zlog,
+		storage:  s,
+		limiter:  limiter,
+		policy:   policy,
+		trusted:  trusted,
+		agg:      NewAggregator(),
+		address:  cfg.StatsdAddress,
+		interval: interval,
+	}, nil
+}
+
+// Listen открывает UDP и TCP сокеты. Если в адресе указан порт 0,
+// TCP слушает тот же порт, который система выделила для UDP.
+func (srv *Server) Listen() error {
+	udp, err := net.ListenPacket("udp", srv.address)
+	if err != nil {
+		return fmt.Errorf("failed to listen udp %s: %w", srv.address, err)
+	}
+
+	tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+	if err != nil {
+		_ = udp.Close()
+		return fmt.Errorf("failed to listen tcp %s: %w", srv.address, err)
+	}
+
+	srv.udp = udp
+	srv.tcp = tcp
+	return nil
+}
+
+// Addr адрес, на котором принимаются метрики. Доступен после Listen.
+func (srv *Server) Addr() string {
+	return srv.udp.LocalAddr().String()
+}
+
+// Serve принимает метрики до отмены контекста, после чего сбрасывает в хранилище последний интервал.
+func (srv *Server) Serve(ctx context.Context) error {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		srv.serveUDP()
+	}()
+	go func() {
+		defer wg.Done()
+		srv.serveTCP(ctx)
+	}()
+
+	ticker := time.NewTicker(srv.interval)
+	defer ticker.Stop()
+
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-ticker.C:
+			srv.flush(ctx)
+		}
+	}
+
+	err := errors.Join(srv.udp.Close(), srv.tcp.Close())
+	wg.Wait()
+	srv.flush(context.WithoutCancel(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to close statsd listeners: %w", err)
+	}
+	return nil
+}
+
+func (srv *Server) ListenAndServe(ctx context.Context) error {
+	if err := srv.Listen(); err != nil {
+		return err
+	}
+	return srv.Serve(ctx)
+}
+
+func (srv *Server) serveUDP() {
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, addr, err := srv.udp.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				srv.zlog.Warnf("failed to read statsd packet: %v", err)
+			}
+			return
+		}
+		// пакеты приходят часто, поэтому отклоненные пишутся в лог только на уровне debug.
+		if !srv.trusted.Allows(addr.String()) {
+			srv.zlog.Debugf("skip statsd packet from %s: outside trusted subnet %s", addr, srv.trusted)
+			continue
+		}
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			srv.handleLine(line)
+		}
+	}
+}
+
+func (srv *Server) serveTCP(ctx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		conn, err := srv.tcp.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				srv.zlog.Warnf("failed to accept statsd connection: %v", err)
+			}
+			return
+		}
+		if !srv.trusted.Allows(conn.RemoteAddr().String()) {
+			srv.zlog.Warnf("rejected statsd connection from %s: outside trusted subnet %s",
+				conn.RemoteAddr(), srv.trusted)
+			_ = conn.Close()
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			srv.serveConn(ctx, conn)
+		}()
+	}
+}
+
+func (srv *Server) serveConn(ctx context.Context, conn net.Conn) {
+	// закрываем соединение при остановке сервера, чтобы прервать чтение.
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	defer func() {
+		if stop() {
+			_ = conn.Close()
+		}
+	}()
+
+	sc := bufio.NewScanner(conn)
+	sc.Buffer(make([]byte, 0, 4096), maxPacketSize)
+	for sc.Scan() {
+		srv.handleLine(sc.Text())
+	}
+	if err := sc.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		srv.zlog.Debugf("statsd connection %s closed: %v", conn.RemoteAddr(), err)
+	}
+}
+
+func (srv *Server) handleLine(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+
+	s, err := Parse(line)
+	if err != nil {
+		srv.zlog.Debugf("skip statsd line: %v", err)
+		return
+	}
+
+	name, err := srv.policy.Admit(s.Name)
+	if err != nil {
+		srv.zlog.Debugf("skip statsd line: %v", err)
+		return
+	}
+	s.Name = name
+
+	srv.agg.Add(s)
+}
+
+func (srv *Server) flush(ctx context.Context) {
+	metrics, err := srv.limiter.Filter(ctx, source, srv.agg.Flush())
+	if err != nil {
+		srv.zlog.Warnf("dropped statsd metrics: %v", err)
+	}
+	if len(metrics) == 0 {
+		return
+	}
+	if err := srv.storage.SaveMetrics(ctx, metrics); err != nil {
+		srv.zlog.Warnf("failed to save statsd metrics: %v", err)
+		return
+	}
+	srv.limiter.Commit(source, cardinality.Keys(metrics)...)
+}
@@ -0,0 +1,108 @@
+package statsd_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/server/cardinality"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/logger"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/naming"
+	"github.com/VanGoghDev/practicum-metrics/internal/server/statsd"
+	"github.com/VanGoghDev/practicum-metrics/internal/storage/memstorage"
+)
+
+func TestServer(t *testing.T) {
+	log, _ := logger.New("Info")
+	memstrg, _ := memstorage.New(log)
+	// лимит занимают счетчики и gauge, серии таймера сбрасываются последними и отбрасываются.
+	cfg := &config.Config{StatsdAddress: "127.0.0.1:0", StatsdFlushInterval: time.Hour, MaxSeries: 3}
+	limiter := cardinality.New(cfg, memstrg)
+	policy, err := naming.New(cfg)
+	require.NoError(t, err)
+	srv, err := statsd.New(log.Sugar(), cfg, memstrg, limiter, policy)
+	require.NoError(t, err)
+	require.NoError(t, srv.Listen())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- srv.Serve(ctx)
+	}()
+
+	udp, err := net.Dial("udp", srv.Addr())
+	require.NoError(t, err)
+	_, err = udp.Write([]byte("requests:2|c\nqueue:7|g\ninvalid line\nbad name:1|g"))
+	require.NoError(t, err)
+	require.NoError(t, udp.Close())
+
+	tcp, err := net.Dial("tcp", srv.Addr())
+	require.NoError(t, err)
+	_, err = tcp.Write([]byte("requests:3|c|#env:prod\nrequests:1|c\nlatency:5|ms\n"))
+	require.NoError(t, err)
+	require.NoError(t, tcp.Close())
+
+	// ждем, пока сервер прочитает все строки: обе записи в requests должны дойти.
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	counters := make(map[string]int64)
+	cs, err := memstrg.Counters(context.Background())
+	require.NoError(t, err)
+	for _, c := range cs {
+		counters[c.Name] = c.Value
+	}
+	assert.Equal(t, map[string]int64{"requests": 3, `requests{env="prod"}`: 3}, counters)
+
+	g, err := memstrg.Gauge(context.Background(), "queue")
+	require.NoError(t, err)
+	assert.InDelta(t, 7.0, g.Value, 0)
+
+	gs, err := memstrg.Gauges(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, gs, 1)
+	assert.Equal(t, 3, limiter.Stats().Series)
+	assert.Positive(t, limiter.Stats().RejectedSeriesLimit)
+}
+
+func TestServer_TrustedSubnet(t *testing.T) {
+	log, _ := logger.New("Info")
+	memstrg, _ := memstorage.New(log)
+	cfg := &config.Config{StatsdAddress: "127.0.0.1:0", StatsdFlushInterval: time.Hour, TrustedSubnet: "10.0.0.0/8"}
+	policy, err := naming.New(cfg)
+	require.NoError(t, err)
+	srv, err := statsd.New(log.Sugar(), cfg, memstrg, cardinality.New(cfg, memstrg), policy)
+	require.NoError(t, err)
+	require.NoError(t, srv.Listen())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- srv.Serve(ctx)
+	}()
+
+	for _, network := range []string{"udp", "tcp"} {
+		conn, err := net.Dial(network, srv.Addr())
+		require.NoError(t, err)
+		_, _ = conn.Write([]byte("requests:1|c\nqueue:7|g\n"))
+		require.NoError(t, conn.Close())
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	// 127.0.0.1 не входит в доверенную подсеть, ни один пакет не записан.
+	cs, err := memstrg.Counters(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, cs)
+	gs, err := memstrg.Gauges(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, gs)
+}
@@ -5,23 +5,27 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
+	"sync"
 
 	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
 	"github.com/VanGoghDev/practicum-metrics/internal/storage/memstorage"
-	"github.com/VanGoghDev/practicum-metrics/internal/storage/serrors"
 	"go.uber.org/zap"
 )
 
 type FileStorage struct {
-	memstorage.MemStorage
+	*memstorage.MemStorage
 	zlog    *zap.Logger
 	file    *os.File
 	writer  *bufio.Writer
 	scanner *bufio.Scanner
+	// mu делает изменение памяти и запись в файл одной операцией,
+	// иначе снимок при перезаписи файла мог бы разойтись с дописанными строками.
+	mu sync.Mutex
 }
 
 func New(ctx context.Context, zlog *zap.Logger, cfg *config.Config) (*FileStorage, error) {
@@ -39,12 +43,10 @@ func New(ctx context.Context, zlog *zap.Logger, cfg *config.Config) (*FileStorag
 	f := &FileStorage{
 		zlog:       zlog,
 		file:       file,
-		MemStorage: *memsrtg,
+		MemStorage: memsrtg,
 		writer:     bufio.NewWriter(file),
 		scanner:    bufio.NewScanner(file),
 	}
-	f.MemStorage.GaugesM = make(map[string]float64)
-	f.MemStorage.CountersM = make(map[string]int64)
 
 	if cfg.Restore && f.file != nil {
 		err := f.restore(ctx)
@@ -68,6 +70,11 @@ func (f *FileStorage) SaveMetrics(ctx context.Context, metrics []*models.Metrics
 			if err != nil {
 				return fmt.Errorf("failed to save gauge: %w", err)
 			}
+		case handlers.Histogram:
+			err := f.SaveHistogram(ctx, v.ID, *v.Histogram)
+			if err != nil {
+				return fmt.Errorf("failed to save histogram: %w", err)
+			}
 		default:
 			f.zlog.Sugar().Warnf("metric \"%s\" has unknown type \"%s\".", v.MType, v.MType)
 			continue
@@ -77,11 +84,12 @@ func (f *FileStorage) SaveMetrics(ctx context.Context, metrics []*models.Metrics
 }
 
 func (f *FileStorage) SaveGauge(ctx context.Context, name string, value float64) (err error) {
-	if f.MemStorage.GaugesM == nil {
-		return serrors.ErrGaugesTableNil
-	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
 
-	f.MemStorage.GaugesM[name] = value
+	if err = f.MemStorage.SaveGauge(ctx, name, value); err != nil {
+		return fmt.Errorf("failed to save gauge %s: %w", name, err)
+	}
 
 	gauge := &models.Metrics{
 		ID:    name,
@@ -99,11 +107,12 @@ func (f *FileStorage) SaveGauge(ctx context.Context, name string, value float64)
 }
 
 func (f *FileStorage) SaveCount(ctx context.Context, name string, value int64) (err error) {
-	if f.MemStorage.CountersM == nil {
-		return serrors.ErrCountersTableNil
-	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
 
-	f.MemStorage.CountersM[name] += value
+	if err = f.MemStorage.SaveCount(ctx, name, value); err != nil {
+		return fmt.Errorf("failed to save counter %s: %w", name, err)
+	}
 
 	counter := &models.Metrics{
 		ID:    name,
@@ -120,6 +129,31 @@ func (f *FileStorage) SaveCount(ctx context.Context, name string, value int64) (
 	return f.SaveToFile(ctx, data)
 }
 
+// SaveHistogram добавляет значения к гистограмме в памяти и дописывает их в файл.
+// При восстановлении строки складываются так же, поэтому в файле достаточно приращений.
+func (f *FileStorage) SaveHistogram(ctx context.Context, name string, h models.Histogram) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err = f.MemStorage.SaveHistogram(ctx, name, h); err != nil {
+		return fmt.Errorf("failed to save histogram %s: %w", name, err)
+	}
+
+	histogram := &models.Metrics{
+		ID:        name,
+		MType:     "histogram",
+		Histogram: &h,
+	}
+	data, err := json.Marshal(histogram)
+	if err != nil {
+		return fmt.Errorf("failed to marshal histogram %s: %w", histogram.ID, err)
+	}
+	// добавим символ переноса строки
+	data = append(data, '\n')
+
+	return f.SaveToFile(ctx, data)
+}
+
 func (f *FileStorage) SaveToFile(ctx context.Context, data []byte) error {
 	_, err := f.writer.Write(data)
 
@@ -134,6 +168,51 @@ func (f *FileStorage) SaveToFile(ctx context.Context, data []byte) error {
 	return nil
 }
 
+// DeleteMetric удаляет метрику из памяти и перезаписывает файл текущим состоянием,
+// чтобы удаленная метрика не восстановилась при следующем запуске.
+func (f *FileStorage) DeleteMetric(ctx context.Context, mType string, name string) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err = f.MemStorage.DeleteMetric(ctx, mType, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete metric %s: %w", name, err)
+	}
+	return f.compact(ctx)
+}
+
+// compact перезаписывает файл снимком метрик, хранящихся в памяти.
+func (f *FileStorage) compact(ctx context.Context) error {
+	metrics, err := f.MemStorage.GetMetrics(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get metrics: %w", err)
+	}
+
+	if err = f.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate file: %w", err)
+	}
+	if _, err = f.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek file: %w", err)
+	}
+	f.writer.Reset(f.file)
+
+	for _, m := range metrics {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metric %s: %w", m.ID, err)
+		}
+		data = append(data, '\n')
+		if _, err = f.writer.Write(data); err != nil {
+			return fmt.Errorf("failed to write data to file: %w", err)
+		}
+	}
+
+	if err = f.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to flush data to file: %w", err)
+	}
+	return nil
+}
+
 func (f *FileStorage) restore(ctx context.Context) error {
 	f.zlog.Debug("restoring metrics from file...")
 	metrics := make([]*models.Metrics, 0)
@@ -166,6 +245,11 @@ func (f *FileStorage) restore(ctx context.Context) error {
 			if err != nil {
 				return fmt.Errorf("failed to restore counter %s: %w", v.ID, err)
 			}
+		case "histogram":
+			err := f.SaveHistogram(ctx, v.ID, *v.Histogram)
+			if err != nil {
+				return fmt.Errorf("failed to restore histogram %s: %w", v.ID, err)
+			}
 		}
 	}
 
@@ -3,23 +3,30 @@ package memstorage
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
 	"github.com/VanGoghDev/practicum-metrics/internal/storage/serrors"
+	"github.com/VanGoghDev/practicum-metrics/internal/util/glob"
 	"go.uber.org/zap"
 )
 
 type MemStorage struct {
-	zlog      *zap.Logger
-	GaugesM   map[string]float64
-	CountersM map[string]int64
+	zlog        *zap.Logger
+	GaugesM     map[string]float64
+	CountersM   map[string]int64
+	HistogramsM map[string]models.Histogram
+	mu          sync.RWMutex
 }
 
 func New(zlog *zap.Logger) (*MemStorage, error) {
 	s := &MemStorage{
-		zlog:      zlog,
-		GaugesM:   make(map[string]float64),
-		CountersM: make(map[string]int64),
+		zlog:        zlog,
+		GaugesM:     make(map[string]float64),
+		CountersM:   make(map[string]int64),
+		HistogramsM: make(map[string]models.Histogram),
 	}
 
 	return s, nil
@@ -30,6 +37,9 @@ func (s *MemStorage) SaveGauge(ctx context.Context, name string, value float64)
 		return serrors.ErrGaugesTableNil
 	}
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	s.GaugesM[name] = value
 	return nil
 }
@@ -39,14 +49,33 @@ func (s *MemStorage) SaveCount(ctx context.Context, name string, value int64) (e
 		return serrors.ErrCountersTableNil
 	}
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	s.CountersM[name] += value
 	return nil
 }
 
+// SaveHistogram добавляет к гистограмме name значения h (см. models.Histogram.Merge).
+func (s *MemStorage) SaveHistogram(ctx context.Context, name string, h models.Histogram) (err error) {
+	if s == nil || s.HistogramsM == nil {
+		return serrors.ErrHistogramsTableNil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.HistogramsM[name] = s.HistogramsM[name].Merge(h)
+	return nil
+}
+
 func (s *MemStorage) Gauges(ctx context.Context) (gauges []models.Gauge, err error) {
 	if s == nil || s.GaugesM == nil {
 		return nil, serrors.ErrGaugesTableNil
 	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	gauges = make([]models.Gauge, 0, len(s.GaugesM))
 	for k, v := range s.GaugesM {
 		gauges = append(gauges, models.Gauge{
@@ -62,6 +91,9 @@ func (s *MemStorage) Counters(ctx context.Context) (counters []models.Counter, e
 		return nil, serrors.ErrCountersTableNil
 	}
 
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	counters = make([]models.Counter, 0, len(s.CountersM))
 	for k, v := range s.CountersM {
 		counters = append(counters, models.Counter{
@@ -77,6 +109,9 @@ func (s *MemStorage) Gauge(ctx context.Context, name string) (gauge models.Gauge
 		return models.Gauge{}, serrors.ErrCountersTableNil
 	}
 
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	if name == "" {
 		return models.Gauge{}, serrors.ErrNotFound
 	}
@@ -96,6 +131,9 @@ func (s *MemStorage) Counter(ctx context.Context, name string) (counter models.C
 		return models.Counter{}, serrors.ErrCountersTableNil
 	}
 
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	if name == "" {
 		return models.Counter{}, serrors.ErrNotFound
 	}
@@ -111,7 +149,147 @@ func (s *MemStorage) Counter(ctx context.Context, name string) (counter models.C
 	}
 }
 
+// DeleteMetric удаляет метрику. Если метрики нет, возвращает serrors.ErrNotFound.
+func (s *MemStorage) DeleteMetric(ctx context.Context, mType string, name string) (err error) {
+	if s == nil {
+		return serrors.ErrNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch mType {
+	case "gauge":
+		if s.GaugesM == nil {
+			return serrors.ErrGaugesTableNil
+		}
+		if _, ok := s.GaugesM[name]; !ok {
+			return serrors.ErrNotFound
+		}
+		delete(s.GaugesM, name)
+	case "counter":
+		if s.CountersM == nil {
+			return serrors.ErrCountersTableNil
+		}
+		if _, ok := s.CountersM[name]; !ok {
+			return serrors.ErrNotFound
+		}
+		delete(s.CountersM, name)
+	case "histogram":
+		if s.HistogramsM == nil {
+			return serrors.ErrHistogramsTableNil
+		}
+		if _, ok := s.HistogramsM[name]; !ok {
+			return serrors.ErrNotFound
+		}
+		delete(s.HistogramsM, name)
+	default:
+		return serrors.ErrNotFound
+	}
+	return nil
+}
+
+// ListMetrics возвращает отфильтрованные и отсортированные метрики.
+// Выборка начинается с метрики, следующей за q.After в порядке сортировки.
+func (s *MemStorage) ListMetrics(ctx context.Context, q *models.ListQuery) (metrics []models.Metrics, err error) {
+	if s == nil || s.GaugesM == nil {
+		return nil, serrors.ErrGaugesTableNil
+	}
+	if s.CountersM == nil {
+		return nil, serrors.ErrCountersTableNil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	metrics = make([]models.Metrics, 0)
+	if q.MType == "" || q.MType == "gauge" {
+		for k, v := range s.GaugesM {
+			m := models.Metrics{ID: k, MType: "gauge", Value: &v}
+			if matchQuery(q, &m) {
+				metrics = append(metrics, m)
+			}
+		}
+	}
+	if q.MType == "" || q.MType == "counter" {
+		for k, v := range s.CountersM {
+			m := models.Metrics{ID: k, MType: "counter", Delta: &v}
+			if matchQuery(q, &m) {
+				metrics = append(metrics, m)
+			}
+		}
+	}
+	if q.MType == "" || q.MType == "histogram" {
+		for k, v := range s.HistogramsM {
+			h := v.Clone()
+			m := models.Metrics{ID: k, MType: "histogram", Histogram: &h}
+			if matchQuery(q, &m) {
+				metrics = append(metrics, m)
+			}
+		}
+	}
+
+	slices.SortFunc(metrics, func(a, b models.Metrics) int {
+		return q.Compare(models.MetricKey{ID: a.ID, MType: a.MType}, models.MetricKey{ID: b.ID, MType: b.MType})
+	})
+
+	if q.Limit > 0 && len(metrics) > q.Limit {
+		metrics = metrics[:q.Limit]
+	}
+	return metrics, nil
+}
+
+// MetricsByKeys возвращает найденные метрики из переданного списка ключей.
+// Отсутствующие метрики пропускаются.
+func (s *MemStorage) MetricsByKeys(ctx context.Context, keys []models.MetricKey) (metrics []models.Metrics, err error) {
+	if s == nil || s.GaugesM == nil {
+		return nil, serrors.ErrGaugesTableNil
+	}
+	if s.CountersM == nil {
+		return nil, serrors.ErrCountersTableNil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	metrics = make([]models.Metrics, 0, len(keys))
+	for _, k := range keys {
+		switch k.MType {
+		case "gauge":
+			if v, ok := s.GaugesM[k.ID]; ok {
+				metrics = append(metrics, models.Metrics{ID: k.ID, MType: k.MType, Value: &v})
+			}
+		case "counter":
+			if v, ok := s.CountersM[k.ID]; ok {
+				metrics = append(metrics, models.Metrics{ID: k.ID, MType: k.MType, Delta: &v})
+			}
+		case "histogram":
+			if v, ok := s.HistogramsM[k.ID]; ok {
+				h := v.Clone()
+				metrics = append(metrics, models.Metrics{ID: k.ID, MType: k.MType, Histogram: &h})
+			}
+		}
+	}
+	return metrics, nil
+}
+
+func matchQuery(q *models.ListQuery, m *models.Metrics) bool {
+	if q.After != nil && q.Compare(models.MetricKey{ID: m.ID, MType: m.MType}, *q.After) <= 0 {
+		return false
+	}
+	if q.Prefix != "" && !strings.HasPrefix(m.ID, q.Prefix) {
+		return false
+	}
+	if q.Match != "" && !glob.Match(q.Match, m.ID) {
+		return false
+	}
+	return true
+}
+
 func (s *MemStorage) GetMetrics(ctx context.Context) ([]*models.Metrics, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	metrics := make([]*models.Metrics, 0)
 	for k, v := range s.CountersM {
 		metrics = append(metrics, &models.Metrics{
@@ -129,6 +307,15 @@ func (s *MemStorage) GetMetrics(ctx context.Context) ([]*models.Metrics, error)
 		})
 	}
 
+	for k, v := range s.HistogramsM {
+		h := v.Clone()
+		metrics = append(metrics, &models.Metrics{
+			ID:        k,
+			Histogram: &h,
+			MType:     "histogram",
+		})
+	}
+
 	return metrics, nil
 }
 
@@ -145,6 +332,11 @@ func (s *MemStorage) SaveMetrics(ctx context.Context, metrics []*models.Metrics)
 			if err != nil {
 				return fmt.Errorf("failed to save counter: %w", err)
 			}
+		case "histogram":
+			err := s.SaveHistogram(ctx, v.ID, *v.Histogram)
+			if err != nil {
+				return fmt.Errorf("failed to save histogram: %w", err)
+			}
 		}
 	}
 	return nil
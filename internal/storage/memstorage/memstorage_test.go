@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/logger"
 	"github.com/VanGoghDev/practicum-metrics/internal/storage/serrors"
 	"github.com/stretchr/testify/assert"
@@ -282,3 +283,47 @@ func runTest(t *testing.T, tt *test) func(name string, f func(t *testing.T)) boo
 		return assert.Equal(t, tt.want.err, err) && assert.Equal(t, tt.want.metricValue, s.GaugesM[tt.args.name])
 	}
 }
+
+func TestSaveHistogram(t *testing.T) {
+	tests := []struct {
+		name       string
+		histograms map[string]models.Histogram
+		value      models.Histogram
+		want       models.Histogram
+		err        error
+	}{
+		{
+			name:  "empty histograms table",
+			value: models.Histogram{Counts: []uint64{1}, Count: 1, Sum: 2},
+			err:   serrors.ErrHistogramsTableNil,
+		},
+		{
+			name:       "save new histogram",
+			histograms: map[string]models.Histogram{},
+			value:      models.Histogram{Counts: []uint64{1}, Count: 1, Sum: 2},
+			want:       models.Histogram{Counts: []uint64{1}, Count: 1, Sum: 2},
+		},
+		{
+			name: "merge with existing histogram",
+			histograms: map[string]models.Histogram{
+				"test": {Bounds: []float64{1}, Counts: []uint64{1, 1}, Count: 2, Sum: 3},
+			},
+			value: models.Histogram{Bounds: []float64{1}, Counts: []uint64{2, 0}, Count: 2, Sum: 1},
+			want:  models.Histogram{Bounds: []float64{1}, Counts: []uint64{3, 1}, Count: 4, Sum: 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, _ := logger.New("Info")
+
+			s := &MemStorage{
+				HistogramsM: tt.histograms,
+				zlog:        log,
+			}
+			err := s.SaveHistogram(context.Background(), "test", tt.value)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.want, s.HistogramsM["test"])
+		})
+	}
+}
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS api_tokens(
+			token_hash CHAR(64) PRIMARY KEY,
+			roles      VARCHAR(200) NOT NULL,
+			name       VARCHAR(200)
+		)
\ No newline at end of file
@@ -0,0 +1,4 @@
+DELETE FROM metrics WHERE g_type = 'histogram';
+ALTER TABLE metrics DROP CONSTRAINT IF EXISTS metrics_value_check;
+ALTER TABLE metrics ADD CONSTRAINT metrics_check CHECK (g_value IS NOT NULL OR delta IS NOT NULL);
+ALTER TABLE metrics DROP COLUMN IF EXISTS histogram;
@@ -0,0 +1,4 @@
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS histogram JSONB;
+ALTER TABLE metrics DROP CONSTRAINT IF EXISTS metrics_check;
+ALTER TABLE metrics ADD CONSTRAINT metrics_value_check
+	CHECK (g_value IS NOT NULL OR delta IS NOT NULL OR histogram IS NOT NULL);
@@ -0,0 +1,2 @@
+DELETE FROM metrics WHERE length(name) > 200;
+ALTER TABLE metrics ALTER COLUMN name TYPE VARCHAR(200);
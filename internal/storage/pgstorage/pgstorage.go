@@ -3,14 +3,17 @@ package pgstorage
 import (
 	"context"
 	"embed"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/config"
 	"github.com/VanGoghDev/practicum-metrics/internal/server/handlers"
 	"github.com/VanGoghDev/practicum-metrics/internal/storage/serrors"
+	"github.com/VanGoghDev/practicum-metrics/internal/util/glob"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
@@ -121,6 +124,10 @@ func (s *PgStorage) SaveMetrics(ctx context.Context, metrics []*models.Metrics)
 			if err != nil {
 				return fmt.Errorf("failed to execute insert statement: %w", err)
 			}
+		case handlers.Histogram:
+			if err := s.saveHistogram(ctx, tx, v.ID, *v.Histogram); err != nil {
+				return err
+			}
 		case handlers.Gauge:
 			_, err := tx.Exec(ctx, "delete", v.ID)
 			if err != nil {
@@ -142,8 +149,64 @@ func (s *PgStorage) SaveMetrics(ctx context.Context, metrics []*models.Metrics)
 	return nil
 }
 
+// saveHistogram складывает гистограмму с уже сохраненной (см. models.Histogram.Merge).
+// Сохраненная строка блокируется до конца транзакции, а новая вставляется без перезаписи:
+// если ее успел вставить другой запрос, гистограмма перечитывается и складывается с ней,
+// поэтому одновременные записи не теряют друг друга.
+// Если метрика с таким именем уже сохранена с другим типом, возвращает serrors.ErrTypeMismatch.
+func (s *PgStorage) saveHistogram(ctx context.Context, tx pgx.Tx, name string, h models.Histogram) error {
+	for {
+		var mType string
+		var data []byte
+		err := tx.QueryRow(ctx, "SELECT g_type, histogram FROM metrics WHERE name = $1 FOR UPDATE",
+			name).Scan(&mType, &data)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			inserted, err := s.insertHistogram(ctx, tx, name, h)
+			if err != nil || inserted {
+				return err
+			}
+			continue
+		case err != nil:
+			return fmt.Errorf("failed to select histogram %s: %w", name, err)
+		case mType != handlers.Histogram:
+			return fmt.Errorf("failed to save histogram %s stored as %s: %w", name, mType, serrors.ErrTypeMismatch)
+		}
+
+		var stored models.Histogram
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return fmt.Errorf("failed to unmarshal histogram %s: %w", name, err)
+		}
+		data, err = json.Marshal(stored.Merge(h))
+		if err != nil {
+			return fmt.Errorf("failed to marshal histogram %s: %w", name, err)
+		}
+		_, err = tx.Exec(ctx, "UPDATE metrics SET histogram = $2 WHERE name = $1", name, data)
+		if err != nil {
+			return fmt.Errorf("failed to save histogram %s: %w", name, err)
+		}
+		return nil
+	}
+}
+
+// insertHistogram вставляет новую гистограмму и возвращает false,
+// если строку с таким именем успел вставить другой запрос.
+func (s *PgStorage) insertHistogram(ctx context.Context, tx pgx.Tx, name string, h models.Histogram) (bool, error) {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal histogram %s: %w", name, err)
+	}
+	tag, err := tx.Exec(ctx, "INSERT INTO metrics(name, g_type, histogram) VALUES($1, $2, $3)"+
+		" ON CONFLICT(name) DO NOTHING", name, handlers.Histogram, data)
+	if err != nil {
+		return false, fmt.Errorf("failed to save histogram %s: %w", name, err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
 func (s *PgStorage) SaveGauge(ctx context.Context, name string, value float64) (err error) {
-	_, err = s.pool.Exec(ctx, "INSERT INTO metrics(name, g_type, g_value, delta) VALUES($1, $2, $3, $4)",
+	_, err = s.pool.Exec(ctx, "INSERT INTO metrics(name, g_type, g_value, delta) VALUES($1, $2, $3, $4)"+
+		" ON CONFLICT(name) DO UPDATE SET g_value = EXCLUDED.g_value",
 		name, handlers.Gauge, value, 0)
 	if err != nil {
 		return fmt.Errorf("failed to execute save querry: %w", err)
@@ -162,10 +225,11 @@ func (s *PgStorage) SaveCount(ctx context.Context, name string, value int64) (er
 }
 
 func (s *PgStorage) Gauges(ctx context.Context) (gauges []models.Gauge, err error) {
-	rows, err := s.pool.Query(ctx, "SELECT name, g_value FROM metrics")
+	rows, err := s.pool.Query(ctx, "SELECT name, g_value FROM metrics WHERE g_type = $1", handlers.Gauge)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query gauges: %w", err)
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var g models.Gauge
@@ -184,11 +248,12 @@ func (s *PgStorage) Gauges(ctx context.Context) (gauges []models.Gauge, err erro
 }
 
 func (s *PgStorage) Counters(ctx context.Context) (counters []models.Counter, err error) {
-	rows, err := s.pool.Query(ctx, "SELECT name, delta FROM metrics")
+	rows, err := s.pool.Query(ctx, "SELECT name, delta FROM metrics WHERE g_type = $1", handlers.Counter)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to query gauges: %w", err)
+		return nil, fmt.Errorf("failed to query counters: %w", err)
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var g models.Counter
@@ -207,7 +272,8 @@ func (s *PgStorage) Counters(ctx context.Context) (counters []models.Counter, er
 }
 
 func (s *PgStorage) Gauge(ctx context.Context, name string) (gauge models.Gauge, err error) {
-	row := s.pool.QueryRow(ctx, "SELECT name, g_value FROM metrics WHERE name = $1", name)
+	row := s.pool.QueryRow(ctx, "SELECT name, g_value FROM metrics WHERE name = $1 AND g_type = $2",
+		name, handlers.Gauge)
 	err = row.Scan(&gauge.Name, &gauge.Value)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -219,7 +285,8 @@ func (s *PgStorage) Gauge(ctx context.Context, name string) (gauge models.Gauge,
 }
 
 func (s *PgStorage) Counter(ctx context.Context, name string) (counter models.Counter, err error) {
-	row := s.pool.QueryRow(ctx, "SELECT name, delta FROM metrics WHERE name = $1", name)
+	row := s.pool.QueryRow(ctx, "SELECT name, delta FROM metrics WHERE name = $1 AND g_type = $2",
+		name, handlers.Counter)
 	err = row.Scan(&counter.Name, &counter.Value)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -230,6 +297,162 @@ func (s *PgStorage) Counter(ctx context.Context, name string) (counter models.Co
 	return counter, nil
 }
 
+func (s *PgStorage) DeleteMetric(ctx context.Context, mType string, name string) (err error) {
+	tag, err := s.pool.Exec(ctx, "DELETE FROM metrics WHERE name = $1 AND g_type = $2", name, mType)
+	if err != nil {
+		return fmt.Errorf("failed to execute delete querry: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return serrors.ErrNotFound
+	}
+	return nil
+}
+
+// ListMetrics возвращает отфильтрованные и отсортированные метрики.
+// Фильтрация, сортировка и постраничная выборка (по ключу последней метрики q.After) выполняются в БД.
+// Строки сравниваются побайтно (COLLATE "C"), чтобы порядок совпадал с остальными хранилищами.
+func (s *PgStorage) ListMetrics(ctx context.Context, q *models.ListQuery) (metrics []models.Metrics, err error) {
+	where := make([]string, 0)
+	args := make([]any, 0)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if q.MType != "" {
+		where = append(where, "g_type = "+arg(q.MType))
+	}
+	if q.Prefix != "" {
+		where = append(where, "name LIKE "+arg(glob.EscapeLike(q.Prefix)+"%")+` ESCAPE '\'`)
+	}
+	if q.Match != "" {
+		where = append(where, "name LIKE "+arg(glob.ToLike(q.Match))+` ESCAPE '\'`)
+	}
+
+	first, second := `name COLLATE "C"`, `g_type COLLATE "C"`
+	if q.Sort == models.SortByType || q.Sort == models.SortByTypeDesc {
+		first, second = second, first
+	}
+	direction, cmp := "ASC", ">"
+	if q.Sort == models.SortByNameDesc || q.Sort == models.SortByTypeDesc {
+		direction, cmp = "DESC", "<"
+	}
+
+	if q.After != nil {
+		firstKey, secondKey := q.After.ID, q.After.MType
+		if q.Sort == models.SortByType || q.Sort == models.SortByTypeDesc {
+			firstKey, secondKey = secondKey, firstKey
+		}
+		where = append(where, fmt.Sprintf("(%s, %s) %s (%s, %s)", first, second, cmp, arg(firstKey), arg(secondKey)))
+	}
+
+	query := "SELECT name, g_type, g_value, delta, histogram FROM metrics"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s, %s %s", first, direction, second, direction)
+	if q.Limit > 0 {
+		query += " LIMIT " + arg(q.Limit)
+	}
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metrics: %w", err)
+	}
+	defer rows.Close()
+
+	metrics = make([]models.Metrics, 0)
+	for rows.Next() {
+		m, err := scanMetric(rows)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to iterate through rows: %w", err)
+	}
+	return metrics, nil
+}
+
+// MetricsByKeys возвращает найденные метрики из переданного списка ключей одним запросом.
+// Отсутствующие метрики пропускаются.
+func (s *PgStorage) MetricsByKeys(ctx context.Context, keys []models.MetricKey) (metrics []models.Metrics, err error) {
+	names := make([]string, 0, len(keys))
+	wanted := make(map[models.MetricKey]struct{}, len(keys))
+	for _, k := range keys {
+		names = append(names, k.ID)
+		wanted[k] = struct{}{}
+	}
+
+	rows, err := s.pool.Query(ctx,
+		"SELECT name, g_type, g_value, delta, histogram FROM metrics WHERE name = ANY($1)", names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metrics: %w", err)
+	}
+	defer rows.Close()
+
+	metrics = make([]models.Metrics, 0, len(keys))
+	for rows.Next() {
+		m, err := scanMetric(rows)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := wanted[models.MetricKey{ID: m.ID, MType: m.MType}]; !ok {
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to iterate through rows: %w", err)
+	}
+	return metrics, nil
+}
+
+// scanMetric читает строку name, g_type, g_value, delta, histogram и заполняет значение по типу метрики.
+func scanMetric(rows pgx.Rows) (models.Metrics, error) {
+	var m models.Metrics
+	var value *float64
+	var delta *int64
+	var histogram []byte
+	if err := rows.Scan(&m.ID, &m.MType, &value, &delta, &histogram); err != nil {
+		return models.Metrics{}, fmt.Errorf("failed to scan row in rows: %w", err)
+	}
+	switch m.MType {
+	case handlers.Gauge:
+		m.Value = value
+	case handlers.Histogram:
+		m.Histogram = &models.Histogram{}
+		if err := json.Unmarshal(histogram, m.Histogram); err != nil {
+			return models.Metrics{}, fmt.Errorf("failed to unmarshal histogram %s: %w", m.ID, err)
+		}
+	default:
+		m.Delta = delta
+	}
+	return m, nil
+}
+
+// TokenRoles возвращает роли API токена по хэшу из таблицы api_tokens.
+func (s *PgStorage) TokenRoles(ctx context.Context, tokenHash string) (roles []string, err error) {
+	var list string
+	row := s.pool.QueryRow(ctx, "SELECT roles FROM api_tokens WHERE token_hash = $1", tokenHash)
+	err = row.Scan(&list)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, serrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to scan api token: %w", err)
+	}
+	for _, r := range strings.Split(list, ",") {
+		roles = append(roles, strings.TrimSpace(r))
+	}
+	return roles, nil
+}
+
 func (s *PgStorage) Ping(ctx context.Context) error {
 	err := s.pool.Ping(ctx)
 	if err != nil {
@@ -3,8 +3,10 @@ package serrors
 import "errors"
 
 var (
-	ErrGaugesTableNil   = errors.New("gauges table is not initialized")
-	ErrCountersTableNil = errors.New("counter table is not initialized")
-	ErrNotFound         = errors.New("gauge not found")
-	ErrURLExists        = errors.New("url exists")
+	ErrGaugesTableNil     = errors.New("gauges table is not initialized")
+	ErrCountersTableNil   = errors.New("counter table is not initialized")
+	ErrHistogramsTableNil = errors.New("histograms table is not initialized")
+	ErrNotFound           = errors.New("gauge not found")
+	ErrURLExists          = errors.New("url exists")
+	ErrTypeMismatch       = errors.New("metric is stored with another type")
 )
@@ -0,0 +1,235 @@
+// Package codec сериализует метрики в форматы, которые принимает сервер:
+// JSON (по умолчанию), protobuf (сообщения metricspb) и MessagePack.
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/proto/metricspb"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Типы содержимого поддерживаемых форматов.
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeMsgpack  = "application/msgpack"
+)
+
+var ErrUnknownCodec = errors.New("unknown codec")
+
+// Codec формат сериализации метрик.
+type Codec interface {
+	// Name название формата для сообщений об ошибках и конфигурации.
+	Name() string
+	ContentType() string
+	MarshalMetric(m *models.Metrics) ([]byte, error)
+	UnmarshalMetric(data []byte) (*models.Metrics, error)
+	MarshalMetrics(metrics []*models.Metrics) ([]byte, error)
+	UnmarshalMetrics(data []byte) ([]*models.Metrics, error)
+}
+
+var (
+	JSON     Codec = jsonCodec{}
+	Protobuf Codec = protobufCodec{}
+	Msgpack  Codec = msgpackCodec{}
+)
+
+// ByName возвращает формат по названию из конфигурации: json, protobuf или msgpack.
+func ByName(name string) (Codec, error) {
+	switch strings.ToLower(name) {
+	case "", "json":
+		return JSON, nil
+	case "protobuf", "proto":
+		return Protobuf, nil
+	case "msgpack", "messagepack":
+		return Msgpack, nil
+	default:
+		return nil, fmt.Errorf("%w: %q, expected one of json, protobuf, msgpack", ErrUnknownCodec, name)
+	}
+}
+
+// ForContentType возвращает формат по заголовку Content-Type.
+// Неизвестный или пустой тип считается JSON: агенты старых версий его не передают.
+func ForContentType(contentType string) Codec {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case ContentTypeProtobuf, "application/protobuf":
+		return Protobuf
+	case ContentTypeMsgpack, "application/x-msgpack", "application/vnd.msgpack":
+		return Msgpack
+	default:
+		return JSON
+	}
+}
+
+// Negotiate выбирает формат ответа по заголовку Accept: первый поддерживаемый тип из списка.
+// Если заголовок пуст или не содержит поддерживаемых типов, возвращается fallback.
+func Negotiate(accept string, fallback Codec) Codec {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case ContentTypeJSON:
+			return JSON
+		case ContentTypeProtobuf, "application/protobuf":
+			return Protobuf
+		case ContentTypeMsgpack, "application/x-msgpack", "application/vnd.msgpack":
+			return Msgpack
+		}
+	}
+	return fallback
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string        { return "JSON" }
+func (jsonCodec) ContentType() string { return ContentTypeJSON }
+
+func (jsonCodec) MarshalMetric(m *models.Metrics) ([]byte, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metric: %w", err)
+	}
+	return data, nil
+}
+
+func (jsonCodec) UnmarshalMetric(data []byte) (*models.Metrics, error) {
+	m := &models.Metrics{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal metric: %w", err)
+	}
+	return m, nil
+}
+
+func (jsonCodec) MarshalMetrics(metrics []*models.Metrics) ([]byte, error) {
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metrics: %w", err)
+	}
+	return data, nil
+}
+
+func (jsonCodec) UnmarshalMetrics(data []byte) ([]*models.Metrics, error) {
+	metrics := []*models.Metrics{}
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal metrics: %w", err)
+	}
+	return metrics, nil
+}
+
+// protobufCodec кодирует метрику сообщением metricspb.Metric, батч - metricspb.UpdateMetricsRequest.
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string        { return "protobuf" }
+func (protobufCodec) ContentType() string { return ContentTypeProtobuf }
+
+func (protobufCodec) MarshalMetric(m *models.Metrics) ([]byte, error) {
+	data, err := proto.Marshal(toProto(m))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metric: %w", err)
+	}
+	return data, nil
+}
+
+func (protobufCodec) UnmarshalMetric(data []byte) (*models.Metrics, error) {
+	pm := &metricspb.Metric{}
+	if err := proto.Unmarshal(data, pm); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal metric: %w", err)
+	}
+	return fromProto(pm), nil
+}
+
+func (protobufCodec) MarshalMetrics(metrics []*models.Metrics) ([]byte, error) {
+	req := &metricspb.UpdateMetricsRequest{Metrics: make([]*metricspb.Metric, 0, len(metrics))}
+	for _, m := range metrics {
+		req.Metrics = append(req.Metrics, toProto(m))
+	}
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metrics: %w", err)
+	}
+	return data, nil
+}
+
+func (protobufCodec) UnmarshalMetrics(data []byte) ([]*models.Metrics, error) {
+	req := &metricspb.UpdateMetricsRequest{}
+	if err := proto.Unmarshal(data, req); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal metrics: %w", err)
+	}
+	metrics := make([]*models.Metrics, 0, len(req.GetMetrics()))
+	for _, pm := range req.GetMetrics() {
+		metrics = append(metrics, fromProto(pm))
+	}
+	return metrics, nil
+}
+
+// toProto переводит метрику в сообщение metricspb. nil кодируется пустым сообщением.
+func toProto(m *models.Metrics) *metricspb.Metric {
+	if m == nil {
+		return &metricspb.Metric{}
+	}
+	return &metricspb.Metric{Id: m.ID, Type: m.MType, Delta: m.Delta, Value: m.Value}
+}
+
+func fromProto(pm *metricspb.Metric) *models.Metrics {
+	return &models.Metrics{ID: pm.GetId(), MType: pm.GetType(), Delta: pm.Delta, Value: pm.Value}
+}
+
+// msgpackCodec использует те же имена полей, что и JSON.
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string        { return "MessagePack" }
+func (msgpackCodec) ContentType() string { return ContentTypeMsgpack }
+
+func (msgpackCodec) MarshalMetric(m *models.Metrics) ([]byte, error) {
+	return msgpackMarshal(m)
+}
+
+func (msgpackCodec) UnmarshalMetric(data []byte) (*models.Metrics, error) {
+	m := &models.Metrics{}
+	if err := msgpackUnmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (msgpackCodec) MarshalMetrics(metrics []*models.Metrics) ([]byte, error) {
+	return msgpackMarshal(metrics)
+}
+
+func (msgpackCodec) UnmarshalMetrics(data []byte) ([]*models.Metrics, error) {
+	metrics := []*models.Metrics{}
+	if err := msgpackUnmarshal(data, &metrics); err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+func msgpackMarshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to marshal msgpack: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func msgpackUnmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("failed to unmarshal msgpack: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package codec_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VanGoghDev/practicum-metrics/internal/domain/models"
+	"github.com/VanGoghDev/practicum-metrics/internal/util/codec"
+)
+
+var codecs = []codec.Codec{codec.JSON, codec.Protobuf, codec.Msgpack}
+
+// agentBatch батч из 32 метрик, который агент отправляет раз в интервал отчета.
+func agentBatch() []*models.Metrics {
+	gauges := []string{
+		"Alloc", "BuckHashSys", "Frees", "GCCPUFraction", "GCSys", "HeapAlloc", "HeapIdle", "HeapInuse",
+		"HeapObjects", "HeapReleased", "HeapSys", "LastGC", "Lookups", "MCacheInuse", "MCacheSys", "MSpanSys",
+		"MSpanInuse", "Mallocs", "NextGC", "NumForcedGC", "NumGC", "OtherSys", "PauseTotalNs", "StackInuse",
+		"StackSys", "Sys", "TotalAlloc", "RandomValue", "TotalMemory", "FreeMemory", "CPUutilization1",
+	}
+	rnd := rand.New(rand.NewSource(1))
+	metrics := make([]*models.Metrics, 0, len(gauges)+1)
+	for _, name := range gauges {
+		v := float64(rnd.Int63n(1 << 32))
+		metrics = append(metrics, &models.Metrics{ID: name, MType: "gauge", Value: &v})
+	}
+	pollCount := int64(5)
+	return append(metrics, &models.Metrics{ID: "PollCount", MType: "counter", Delta: &pollCount})
+}
+
+func TestRoundTrip(t *testing.T) {
+	fraction := 0.015625
+	delta := int64(-3)
+	batch := []*models.Metrics{
+		{ID: "GCCPUFraction", MType: "gauge", Value: &fraction},
+		{ID: "PollCount", MType: "counter", Delta: &delta},
+		{ID: "Alloc", MType: "gauge"},
+	}
+
+	for _, c := range codecs {
+		t.Run(c.Name(), func(t *testing.T) {
+			data, err := c.MarshalMetrics(batch)
+			require.NoError(t, err)
+			got, err := c.UnmarshalMetrics(data)
+			require.NoError(t, err)
+			assert.Equal(t, batch, got)
+
+			data, err = c.MarshalMetric(batch[1])
+			require.NoError(t, err)
+			m, err := c.UnmarshalMetric(data)
+			require.NoError(t, err)
+			assert.Equal(t, batch[1], m)
+
+			_, err = c.UnmarshalMetrics([]byte{0xff, 0xff, 0xff})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNegotiation(t *testing.T) {
+	tests := []struct {
+		want        codec.Codec
+		fallback    codec.Codec
+		name        string
+		contentType string
+		accept      string
+	}{
+		{name: "no headers", want: codec.JSON, fallback: codec.JSON},
+		{name: "protobuf", contentType: "application/x-protobuf", want: codec.Protobuf},
+		{name: "msgpack with params", contentType: "application/msgpack; charset=binary", want: codec.Msgpack},
+		{name: "unknown type is json", contentType: "text/plain", want: codec.JSON},
+		{name: "accept wins", accept: "application/msgpack", fallback: codec.Protobuf, want: codec.Msgpack},
+		{name: "first supported accept", accept: "text/html, application/x-protobuf;q=0.9, application/json",
+			fallback: codec.JSON, want: codec.Protobuf},
+		{name: "any falls back", accept: "*/*", fallback: codec.Msgpack, want: codec.Msgpack},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.fallback == nil {
+				assert.Equal(t, tt.want, codec.ForContentType(tt.contentType))
+				return
+			}
+			assert.Equal(t, tt.want, codec.Negotiate(tt.accept, tt.fallback))
+		})
+	}
+}
+
+func TestByName(t *testing.T) {
+	for _, name := range []string{"", "json", "protobuf", "msgpack"} {
+		_, err := codec.ByName(name)
+		assert.NoError(t, err, name)
+	}
+	_, err := codec.ByName("xml")
+	assert.ErrorIs(t, err, codec.ErrUnknownCodec)
+}
+
+// BenchmarkMarshalMetrics сравнивает форматы на батче агента.
+// Кроме времени сериализации сообщает размер батча до и после gzip, с которым агент его отправляет.
+func BenchmarkMarshalMetrics(b *testing.B) {
+	batch := agentBatch()
+	for _, c := range codecs {
+		b.Run(c.Name(), func(b *testing.B) {
+			data, err := c.MarshalMetrics(batch)
+			require.NoError(b, err)
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			_, _ = zw.Write(data)
+			require.NoError(b, zw.Close())
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := c.MarshalMetrics(batch); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(data)), "payload_bytes")
+			b.ReportMetric(float64(buf.Len()), "gzip_bytes")
+		})
+	}
+}
+
+func BenchmarkUnmarshalMetrics(b *testing.B) {
+	batch := agentBatch()
+	for _, c := range codecs {
+		b.Run(c.Name(), func(b *testing.B) {
+			data, err := c.MarshalMetrics(batch)
+			require.NoError(b, err)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := c.UnmarshalMetrics(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+// Package encryption шифрует тела запросов агента открытым ключом сервера.
+//
+// Тело шифруется AES-256-GCM случайным ключом, а сам ключ передается зашифрованным
+// RSA-OAEP (SHA-256) или выводится из общего секрета X25519 с эфемерным ключом агента.
+// Схема передается в заголовке X-Encryption.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// HeaderScheme HTTP заголовок со схемой шифрования тела.
+const HeaderScheme = "X-Encryption"
+
+// Схемы шифрования.
+const (
+	SchemeRSA    = "rsa-oaep"
+	SchemeX25519 = "x25519"
+)
+
+const keyLen = 32
+
+var (
+	ErrUnsupportedKey = errors.New("unsupported crypto key")
+	ErrDecrypt        = errors.New("failed to decrypt body")
+)
+
+// hkdfInfo отделяет ключи этого протокола от других применений того же общего секрета.
+var hkdfInfo = []byte("practicum-metrics body encryption")
+
+// PublicKey открытый ключ сервера, которым агент шифрует тела запросов.
+type PublicKey struct {
+	rsa    *rsa.PublicKey
+	x25519 *ecdh.PublicKey
+}
+
+// PrivateKey закрытый ключ сервера, которым расшифровываются тела запросов.
+type PrivateKey struct {
+	rsa    *rsa.PrivateKey
+	x25519 *ecdh.PrivateKey
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read crypto key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: %s is not a PEM file", ErrUnsupportedKey, path)
+	}
+	return block, nil
+}
+
+// LoadPublicKey читает открытый ключ RSA или X25519 в формате PEM (PKIX или PKCS #1).
+func LoadPublicKey(path string) (*PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePublicKey(block)
+}
+
+func ParsePublicKey(block *pem.Block) (*PublicKey, error) {
+	if block.Type == "RSA PUBLIC KEY" {
+		k, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse rsa public key: %w", err)
+		}
+		return &PublicKey{rsa: k}, nil
+	}
+
+	k, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	switch k := k.(type) {
+	case *rsa.PublicKey:
+		return &PublicKey{rsa: k}, nil
+	case *ecdh.PublicKey:
+		if k.Curve() == ecdh.X25519() {
+			return &PublicKey{x25519: k}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, k)
+}
+
+// LoadPrivateKey читает закрытый ключ RSA или X25519 в формате PEM (PKCS #8 или PKCS #1).
+func LoadPrivateKey(path string) (*PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePrivateKey(block)
+}
+
+func ParsePrivateKey(block *pem.Block) (*PrivateKey, error) {
+	if block.Type == "RSA PRIVATE KEY" {
+		k, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse rsa private key: %w", err)
+		}
+		return &PrivateKey{rsa: k}, nil
+	}
+
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	switch k := k.(type) {
+	case *rsa.PrivateKey:
+		return &PrivateKey{rsa: k}, nil
+	case *ecdh.PrivateKey:
+		if k.Curve() == ecdh.X25519() {
+			return &PrivateKey{x25519: k}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, k)
+}
+
+// Scheme схема шифрования, соответствующая ключу.
+func (k *PublicKey) Scheme() string {
+	if k.rsa != nil {
+		return SchemeRSA
+	}
+	return SchemeX25519
+}
+
+// Encrypt шифрует plaintext.
+// Формат RSA: длина зашифрованного ключа (2 байта), зашифрованный ключ, nonce и шифротекст AES-GCM.
+// Формат X25519: эфемерный открытый ключ (32 байта), nonce и шифротекст AES-GCM.
+func (k *PublicKey) Encrypt(plaintext []byte) ([]byte, error) {
+	var header, key []byte
+	if k.rsa != nil {
+		key = make([]byte, keyLen)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate body key: %w", err)
+		}
+		wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, k.rsa, key, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt body key: %w", err)
+		}
+		header = binary.BigEndian.AppendUint16(nil, uint16(len(wrapped)))
+		header = append(header, wrapped...)
+	} else {
+		eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+		}
+		key, err = deriveKey(eph, k.x25519, eph.PublicKey(), k.x25519)
+		if err != nil {
+			return nil, err
+		}
+		header = eph.PublicKey().Bytes()
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	out := append(header, nonce...)
+	return aead.Seal(out, nonce, plaintext, []byte(k.Scheme())), nil
+}
+
+// Decrypt расшифровывает тело, зашифрованное по схеме scheme.
+func (k *PrivateKey) Decrypt(scheme string, data []byte) ([]byte, error) {
+	var key []byte
+	switch {
+	case scheme == SchemeRSA && k.rsa != nil:
+		if len(data) < 2 {
+			return nil, ErrDecrypt
+		}
+		n := int(binary.BigEndian.Uint16(data))
+		if len(data) < 2+n {
+			return nil, ErrDecrypt
+		}
+		var err error
+		key, err = rsa.DecryptOAEP(sha256.New(), nil, k.rsa, data[2:2+n], nil)
+		if err != nil || len(key) != keyLen {
+			return nil, ErrDecrypt
+		}
+		data = data[2+n:]
+	case scheme == SchemeX25519 && k.x25519 != nil:
+		if len(data) < keyLen {
+			return nil, ErrDecrypt
+		}
+		eph, err := ecdh.X25519().NewPublicKey(data[:keyLen])
+		if err != nil {
+			return nil, ErrDecrypt
+		}
+		key, err = deriveKey(k.x25519, eph, eph, k.x25519.PublicKey())
+		if err != nil {
+			return nil, ErrDecrypt
+		}
+		data = data[keyLen:]
+	default:
+		return nil, fmt.Errorf("%w: scheme %q does not match server key", ErrUnsupportedKey, scheme)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(scheme))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// deriveKey выводит ключ тела из общего секрета X25519, эфемерного ключа агента и ключа сервера.
+func deriveKey(priv *ecdh.PrivateKey, peer, ephemeral, recipient *ecdh.PublicKey) ([]byte, error) {
+	shared, err := priv.ECDH(peer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute shared secret: %w", err)
+	}
+	salt := append(ephemeral.Bytes(), recipient.Bytes()...)
+
+	key := make([]byte, keyLen)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, hkdfInfo), key); err != nil {
+		return nil, fmt.Errorf("failed to derive body key: %w", err)
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init gcm: %w", err)
+	}
+	return aead, nil
+}
@@ -0,0 +1,103 @@
+package encryption
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKeys генерирует пару ключей и сохраняет ее в PEM файлы.
+func writeKeys(t *testing.T, scheme string) (pubPath, privPath string) {
+	t.Helper()
+
+	var pub, priv any
+	switch scheme {
+	case SchemeRSA:
+		k, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		pub, priv = &k.PublicKey, k
+	case SchemeX25519:
+		k, err := ecdh.X25519().GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		pub, priv = k.PublicKey(), k
+	}
+
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	pubPath, privPath = filepath.Join(dir, "public.pem"), filepath.Join(dir, "private.pem")
+	require.NoError(t, os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0o600))
+	require.NoError(t, os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0o600))
+	return pubPath, privPath
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	plaintext := []byte(`[{"id":"PollCount","type":"counter","delta":1}]`)
+
+	for _, scheme := range []string{SchemeRSA, SchemeX25519} {
+		t.Run(scheme, func(t *testing.T) {
+			pubPath, privPath := writeKeys(t, scheme)
+			pub, err := LoadPublicKey(pubPath)
+			require.NoError(t, err)
+			priv, err := LoadPrivateKey(privPath)
+			require.NoError(t, err)
+			assert.Equal(t, scheme, pub.Scheme())
+
+			sealed, err := pub.Encrypt(plaintext)
+			require.NoError(t, err)
+			assert.NotContains(t, string(sealed), "PollCount")
+
+			got, err := priv.Decrypt(scheme, sealed)
+			require.NoError(t, err)
+			assert.Equal(t, plaintext, got)
+
+			tampered := append([]byte(nil), sealed...)
+			tampered[len(tampered)-1] ^= 1
+			_, err = priv.Decrypt(scheme, tampered)
+			require.ErrorIs(t, err, ErrDecrypt)
+
+			_, err = priv.Decrypt(scheme, sealed[:10])
+			require.ErrorIs(t, err, ErrDecrypt)
+
+			_, otherPath := writeKeys(t, scheme)
+			other, err := LoadPrivateKey(otherPath)
+			require.NoError(t, err)
+			_, err = other.Decrypt(scheme, sealed)
+			require.ErrorIs(t, err, ErrDecrypt)
+		})
+	}
+}
+
+func TestDecryptSchemeMismatch(t *testing.T) {
+	pubPath, _ := writeKeys(t, SchemeX25519)
+	_, privPath := writeKeys(t, SchemeRSA)
+	pub, err := LoadPublicKey(pubPath)
+	require.NoError(t, err)
+	priv, err := LoadPrivateKey(privPath)
+	require.NoError(t, err)
+
+	sealed, err := pub.Encrypt([]byte("data"))
+	require.NoError(t, err)
+	_, err = priv.Decrypt(pub.Scheme(), sealed)
+	require.ErrorIs(t, err, ErrUnsupportedKey)
+}
+
+func TestLoadKey_NotPEM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(path, []byte("not a key"), 0o600))
+	_, err := LoadPublicKey(path)
+	require.ErrorIs(t, err, ErrUnsupportedKey)
+	_, err = LoadPrivateKey(path)
+	require.ErrorIs(t, err, ErrUnsupportedKey)
+}
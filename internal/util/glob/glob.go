@@ -0,0 +1,63 @@
+package glob
+
+import (
+	"strings"
+)
+
+// Match сообщает, подходит ли строка s под шаблон pattern.
+// В шаблоне * означает любую (в том числе пустую) подстроку, ? - любой один символ.
+func Match(pattern, s string) bool {
+	p := []rune(pattern)
+	r := []rune(s)
+
+	// позиции для возврата при несовпадении после последней *.
+	star, backtrack := -1, 0
+	i, j := 0, 0
+	for j < len(r) {
+		switch {
+		case i < len(p) && (p[i] == '?' || p[i] == r[j]):
+			i++
+			j++
+		case i < len(p) && p[i] == '*':
+			star = i
+			backtrack = j
+			i++
+		case star >= 0:
+			i = star + 1
+			backtrack++
+			j = backtrack
+		default:
+			return false
+		}
+	}
+
+	for i < len(p) && p[i] == '*' {
+		i++
+	}
+	return i == len(p)
+}
+
+// ToLike переводит шаблон в выражение для SQL оператора LIKE с экранирующим символом \.
+func ToLike(pattern string) string {
+	var b strings.Builder
+	for _, c := range pattern {
+		switch c {
+		case '*':
+			b.WriteByte('%')
+		case '?':
+			b.WriteByte('_')
+		case '%', '_', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// EscapeLike экранирует спецсимволы LIKE, чтобы строка сравнивалась буквально.
+func EscapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
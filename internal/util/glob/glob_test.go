@@ -0,0 +1,36 @@
+package glob
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{pattern: "*", s: "", want: true},
+		{pattern: "Heap*", s: "HeapAlloc", want: true},
+		{pattern: "Heap*", s: "StackInuse", want: false},
+		{pattern: "*Sys", s: "MSpanSys", want: true},
+		{pattern: "*Inuse*", s: "MCacheInuse", want: true},
+		{pattern: "CPUutilization?", s: "CPUutilization1", want: true},
+		{pattern: "CPUutilization?", s: "CPUutilization12", want: false},
+		{pattern: "a*b*c", s: "aXbYbZc", want: true},
+		{pattern: "a*b*c", s: "aXbYbZ", want: false},
+		{pattern: "Alloc", s: "Alloc", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+"/"+tt.s, func(t *testing.T) {
+			assert.Equal(t, tt.want, Match(tt.pattern, tt.s))
+		})
+	}
+}
+
+func TestToLike(t *testing.T) {
+	assert.Equal(t, `Heap%`, ToLike("Heap*"))
+	assert.Equal(t, `cpu\_\%_`, ToLike("cpu_%?"))
+}
@@ -0,0 +1,26 @@
+// Package signing вычисляет подпись HMAC-SHA256, которой агент и сервер подписывают тела запросов.
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// HTTP заголовки подписи.
+const (
+	HeaderSignature = "HashSHA256"
+	HeaderTimestamp = "X-Signature-Timestamp" // время подписи, unix секунды
+	HeaderNonce     = "X-Signature-Nonce"     // случайная строка, уникальная для каждого запроса
+	HeaderKeyID     = "X-Signature-Key-Id"    // идентификатор ключа, которым подписан запрос
+)
+
+// Sign возвращает HMAC-SHA256 с ключом key от времени подписи, nonce и тела.
+// Без времени и nonce подписывается только тело, как у агентов старых версий.
+func Sign(key []byte, timestamp, nonce string, body []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	if timestamp != "" || nonce != "" {
+		h.Write([]byte(timestamp + "\n" + nonce + "\n"))
+	}
+	h.Write(body)
+	return h.Sum(nil)
+}
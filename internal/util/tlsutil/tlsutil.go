@@ -0,0 +1,115 @@
+// Package tlsutil собирает настройки TLS сервера и агента из путей к сертификатам.
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	ErrInvalidVersion = errors.New("invalid tls version")
+	ErrInvalidCA      = errors.New("invalid ca bundle")
+	ErrKeyPair        = errors.New("certificate and key must be set together")
+)
+
+var versions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseVersion разбирает версию TLS вида 1.2, пустая строка - TLS 1.2.
+func ParseVersion(v string) (uint16, error) {
+	if v == "" {
+		return tls.VersionTLS12, nil
+	}
+	version, ok := versions[v]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidVersion, v)
+	}
+	return version, nil
+}
+
+// LoadCertPool читает сертификаты удостоверяющих центров в формате PEM.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ca bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%w: no certificates in %s", ErrInvalidCA, path)
+	}
+	return pool, nil
+}
+
+func loadKeyPair(certFile, keyFile string) ([]tls.Certificate, error) {
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, ErrKeyPair
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	return []tls.Certificate{cert}, nil
+}
+
+// ServerConfig настройки TLS сервера с сертификатом certFile и ключом keyFile.
+// Если задан clientCA, сервер требует от клиента сертификат, подписанный одним из этих центров (mTLS).
+func ServerConfig(certFile, keyFile, minVersion, clientCA string) (*tls.Config, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, ErrKeyPair
+	}
+	certs, err := loadKeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	version, err := ParseVersion(minVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		Certificates: certs,
+		MinVersion:   version,
+	}
+	if clientCA != "" {
+		pool, err := LoadCertPool(clientCA)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// ClientConfig настройки TLS агента. ca - удостоверяющие центры, которым доверяет агент
+// вместо системных, certFile и keyFile - сертификат клиента для mTLS.
+// insecureSkipVerify отключает проверку сертификата сервера и нужен только для тестов.
+func ClientConfig(ca, certFile, keyFile string, insecureSkipVerify bool) (*tls.Config, error) {
+	certs, err := loadKeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		Certificates: certs,
+		MinVersion:   tls.VersionTLS12,
+		// включается только явным флагом для тестовых стендов.
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+	if ca != "" {
+		pool, err := LoadCertPool(ca)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
@@ -0,0 +1,154 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type certFiles struct {
+	cert, key string
+}
+
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	path string
+}
+
+// newCA генерирует удостоверяющий центр и сохраняет его сертификат в PEM файл.
+func newCA(t *testing.T, dir, name string) *authority {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, name+".pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	return &authority{cert: cert, key: key, path: path}
+}
+
+// issue выпускает сертификат сервера или клиента и сохраняет его вместе с ключом.
+func (ca *authority) issue(t *testing.T, dir, name string, usage x509.ExtKeyUsage) certFiles {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	files := certFiles{cert: filepath.Join(dir, name+".crt"), key: filepath.Join(dir, name+".key")}
+	require.NoError(t, os.WriteFile(files.cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(files.key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return files
+}
+
+func TestServerAndClientConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t, dir, "ca")
+	other := newCA(t, dir, "other")
+	server := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	client := ca.issue(t, dir, "client", x509.ExtKeyUsageClientAuth)
+	stranger := other.issue(t, dir, "stranger", x509.ExtKeyUsageClientAuth)
+
+	tests := []struct {
+		name       string
+		clientCA   string
+		minVersion string
+		rootCA     string
+		client     certFiles
+		maxVersion uint16
+		insecure   bool
+		wantErr    bool
+	}{
+		{name: "trusted ca", rootCA: ca.path},
+		{name: "untrusted ca", rootCA: other.path, wantErr: true},
+		{name: "system roots", wantErr: true},
+		{name: "insecure skip verify", insecure: true},
+		{name: "mtls with client certificate", clientCA: ca.path, rootCA: ca.path, client: client},
+		{name: "mtls without client certificate", clientCA: ca.path, rootCA: ca.path, wantErr: true},
+		{name: "mtls with foreign client certificate", clientCA: ca.path, rootCA: ca.path, client: stranger,
+			wantErr: true},
+		{name: "client below min version", minVersion: "1.3", rootCA: ca.path, maxVersion: tls.VersionTLS12,
+			wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srvCfg, err := ServerConfig(server.cert, server.key, tt.minVersion, tt.clientCA)
+			require.NoError(t, err)
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			srv.TLS = srvCfg
+			srv.StartTLS()
+			defer srv.Close()
+
+			cliCfg, err := ClientConfig(tt.rootCA, tt.client.cert, tt.client.key, tt.insecure)
+			require.NoError(t, err)
+			cliCfg.MaxVersion = tt.maxVersion
+			c := &http.Client{Transport: &http.Transport{TLSClientConfig: cliCfg}}
+
+			resp, err := c.Get(srv.URL)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		})
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t, dir, "ca")
+	server := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+
+	_, err := ServerConfig(server.cert, "", "", "")
+	require.ErrorIs(t, err, ErrKeyPair)
+	_, err = ServerConfig(server.cert, server.key, "1.1", "")
+	require.ErrorIs(t, err, ErrInvalidVersion)
+	_, err = ServerConfig(server.cert, server.key, "", server.key)
+	require.ErrorIs(t, err, ErrInvalidCA)
+	_, err = ClientConfig("", server.cert, "", false)
+	require.ErrorIs(t, err, ErrKeyPair)
+}